@@ -1,14 +1,17 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-migrate/migrate/v4"
@@ -16,152 +19,56 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/lib/pq"
 
+	"github.com/blagoweb/bbtg/internal/auth"
 	"github.com/blagoweb/bbtg/internal/db"
 	"github.com/blagoweb/bbtg/internal/handler"
+	"github.com/blagoweb/bbtg/internal/storage"
+	"github.com/blagoweb/bbtg/internal/storage/local"
+	"github.com/blagoweb/bbtg/internal/storage/memory"
 	r2storage "github.com/blagoweb/bbtg/internal/storage/r2"
 	"github.com/blagoweb/bbtg/internal/telegram"
 )
 
-// HandleLogin обрабатывает авторизацию через Telegram WebApp
-func HandleLogin(telegramToken, jwtSecret string) gin.HandlerFunc {
-	type loginReq struct {
-		InitData string `json:"initData" binding:"required"`
-	}
-	return func(c *gin.Context) {
-		var req loginReq
-		if err := c.ShouldBindJSON(&req); err != nil {
-			log.Printf("Login request binding error: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		// Проверяем подпись данных от Telegram
-		data, err := telegram.CheckAuthData(req.InitData, telegramToken)
-		if err != nil {
-			log.Printf("Telegram auth data validation failed: %v", err)
-			log.Printf("InitData received: %s", req.InitData)
-			log.Printf("Telegram token configured: %s", telegramToken)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid telegram data"})
-			return
-		}
-
-		// Извлекаем user_id и username
-		userID := data["user_id"]
-		username := data["username"]
-
-		// Если по какой-то причине CheckAuthData не положил user_id/username,
-		// попробуем достать их из поля user (JSON-строка)
-		if userID == "" || username == "" {
-			if raw := data["user"]; raw != "" {
-				var u struct {
-					ID        int64  `json:"id"`
-					Username  string `json:"username"`
-					FirstName string `json:"first_name"`
-					LastName  string `json:"last_name"`
-				}
-				_ = json.Unmarshal([]byte(raw), &u)
-				if userID == "" && u.ID != 0 {
-					userID = fmt.Sprint(u.ID)
-				}
-				if username == "" {
-					if u.Username != "" {
-						username = u.Username
-					} else if fn := (u.FirstName + " " + u.LastName); fn != " " {
-						username = fn
-					}
-				}
-			}
-		}
-
-		if userID == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing user_id in telegram data"})
-			return
-		}
-
-		// Генерируем JWT токен (HS256)
-		claims := jwt.MapClaims{
-			"user_id":  userID,
-			"username": username,
-			"exp":      time.Now().Add(24 * time.Hour).Unix(),
-			"iat":      time.Now().Unix(),
-		}
-		tok := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-		signed, err := tok.SignedString([]byte(jwtSecret))
-		if err != nil {
-			log.Printf("JWT generation failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
-			return
-		}
-
-		log.Printf("Login successful for user: %s", userID)
-		c.JSON(http.StatusOK, gin.H{"token": signed})
-	}
-}
-
-// AuthMiddleware проверяет JWT токен и добавляет user_id в контекст
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "no authorization header"})
-			c.Abort()
-			return
-		}
-
-		// Убираем "Bearer " префикс
-		tokenString := authHeader
-		if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-			tokenString = authHeader[7:]
-		}
-
-		// Парсим JWT токен
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-			c.Abort()
-			return
-		}
-
-		// Извлекаем user_id из токена
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			if userID, exists := claims["user_id"]; exists {
-				c.Set("user_id", fmt.Sprint(userID))
-				c.Next()
-				return
-			}
-		}
-
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
-		c.Abort()
-	}
-}
-
 func main() {
 	// 1. Env
 	dbDSN := os.Getenv("DB_DSN")
 	telegramToken := os.Getenv("TELEGRAM_BOT_TOKEN")
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "default-secret"
-	}
 	appPort := os.Getenv("APP_PORT")
 	if appPort == "" {
 		appPort = "8080"
 	}
 	corsOrigins := "*"
 
+	// Проверка initData: максимальный возраст, допуск по часам и одноразовость
+	initDataOpts := telegram.Options{
+		MaxAge:    envDuration("TELEGRAM_AUTH_MAX_AGE", 24*time.Hour),
+		ClockSkew: envDuration("TELEGRAM_AUTH_CLOCK_SKEW", time.Minute),
+	}
+	if os.Getenv("TELEGRAM_AUTH_ONE_TIME") == "true" {
+		initDataOpts.Replay = telegram.NewMemoryReplayCache()
+	}
+
+	tokens, err := loadTokenManager()
+	if err != nil {
+		log.Fatalf("jwt keys: %v", err)
+	}
+
+	authCfg := handler.AuthConfig{
+		TelegramToken: telegramToken,
+		Tokens:        tokens,
+		InitData:      initDataOpts,
+		VerifyEnabled: os.Getenv("AUTH_VERIFY_ENDPOINT") == "true",
+		AccessTTL:     envDuration("JWT_ACCESS_TTL", 15*time.Minute),
+		RefreshTTL:    envDuration("JWT_REFRESH_TTL", 30*24*time.Hour),
+	}
+
 	log.Printf("Environment variables:")
 	log.Printf("  DB_DSN: %s", dbDSN)
 	log.Printf("  TELEGRAM_BOT_TOKEN: %s", telegramToken)
-	log.Printf("  JWT_SECRET: %s", jwtSecret)
 	log.Printf("  APP_PORT: %s", appPort)
 	log.Printf("  CORS_ORIGINS: %s", corsOrigins)
+	log.Printf("  TELEGRAM_AUTH_MAX_AGE: %s", initDataOpts.MaxAge)
+	log.Printf("  TELEGRAM_AUTH_CLOCK_SKEW: %s", initDataOpts.ClockSkew)
 
 	// 2. DB
 	database, err := db.Connect(dbDSN)
@@ -177,21 +84,11 @@ func main() {
 		}
 	}
 
-	// 4. R2 (optional)
-	var r2client *r2storage.Client
-	r2Endpoint := os.Getenv("R2_ENDPOINT")
-	r2AccessKey := os.Getenv("R2_ACCESS_KEY")
-	r2SecretKey := os.Getenv("R2_SECRET_KEY")
-	r2Bucket := os.Getenv("R2_BUCKET")
-	if r2Endpoint != "" && r2AccessKey != "" && r2SecretKey != "" && r2Bucket != "" {
-		r2client, err = r2storage.NewClient(r2Endpoint, r2AccessKey, r2SecretKey, r2Bucket)
-		if err != nil {
-			log.Printf("r2 init error: %v", err)
-			r2client = nil
-		}
-	} else {
-		log.Printf("R2 credentials not provided, skipping R2 initialization")
-		r2client = nil
+	// 4. Хранилище файлов (optional)
+	store, files, err := openStorage(appPort)
+	if err != nil {
+		log.Printf("storage init error: %v", err)
+		store, files = nil, nil
 	}
 
 	// 5. Telegram Bot (optional)
@@ -239,31 +136,211 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"status": "ok", "timestamp": time.Now().Unix()})
 	})
 
+	// Файлы локального хранилища (R2 раздаёт их сам)
+	if files != nil {
+		for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPut} {
+			router.Handle(method, storageFilesPath+"/*key", gin.WrapH(files))
+		}
+	}
+
+	// Публичные страницы лендингов (без AuthMiddleware)
+	var clicks *handler.ClickRecorder
+	if database != nil {
+		clicks = handler.NewClickRecorder(database)
+	}
+	handler.RegisterPublicRoutes(&router.RouterGroup, database, tbot, clicks)
+
 	// Авторизация (без AuthMiddleware)
-	router.POST("/api/auth/login", HandleLogin(telegramToken, jwtSecret))
+	handler.RegisterAuthRoutes(router.Group("/api"), database, authCfg)
 
 	// API c авторизацией
 	api := router.Group("/api")
-	api.Use(AuthMiddleware(jwtSecret))
+	api.Use(handler.AuthMiddleware(database, authCfg))
 	{
-		handler.RegisterLandingRoutes(api, database, r2client)
+		handler.RegisterLandingRoutes(api, database, store)
 		handler.RegisterLinkRoutes(api, database)
 		handler.RegisterLeadRoutes(api, database, tbot)
 		handler.RegisterAnalyticsRoutes(api, database)
 		handler.RegisterPaymentRoutes(api, database)
 		handler.RegisterSubscriptionRoutes(api, database, nil)
+		handler.RegisterAPIKeyRoutes(api, database)
+		handler.RegisterWorkspaceRoutes(api, database, tbot)
+		handler.RegisterTemplateRoutes(api, database)
+		handler.RegisterMediaRoutes(api, database, store)
 	}
 
-	// 8. Run
+	// 8. Планировщик публикаций (на всех репликах, строки делятся через SKIP LOCKED)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if database != nil && os.Getenv("SCHEDULER_DISABLED") != "true" {
+		go handler.RunScheduler(ctx, database, tbot, envDuration("SCHEDULER_INTERVAL", 30*time.Second))
+	}
+
+	// Запись кликов останавливается после сервера, чтобы дописать последние
+	clicksCtx, stopClicks := context.WithCancel(context.Background())
+	clicksDone := make(chan struct{})
+	go func() {
+		defer close(clicksDone)
+		if clicks != nil {
+			clicks.Run(clicksCtx, envDuration("CLICKS_FLUSH_INTERVAL", time.Second))
+		}
+	}()
+
+	// 9. Run
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = appPort
 	}
 	addr := fmt.Sprintf(":%s", port)
-	log.Printf("Server running on %s", addr)
-	if err := router.Run(addr); err != nil {
-		log.Fatalf("server error: %v", err)
+	srv := &http.Server{Addr: addr, Handler: router}
+	go func() {
+		log.Printf("Server running on %s", addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("server error: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("server shutdown error: %v", err)
+	}
+	stopClicks()
+	<-clicksDone
+}
+
+// loadTokenManager собирает ключи подписи JWT из окружения:
+//
+//	JWT_KEYS       — HMAC-ключи "kid1:secret1,kid2:secret2"
+//	JWT_PEM_KEYS   — Ed25519/RSA ключи "kid1:/path/key1.pem,..."
+//	JWT_SECRET     — одиночный HMAC-ключ с kid "default" (старая настройка);
+//	                 секрет короче 32 байт пока принимается с предупреждением
+//	                 в логе, в следующем релизе запуск с ним будет падать
+//	JWT_ACTIVE_KEY — kid ключа для подписи новых токенов (по умолчанию первый)
+//	JWT_ISSUER, JWT_AUDIENCE — ожидаемые iss и aud
+func loadTokenManager() (*auth.Manager, error) {
+	keys, err := auth.ParseHMACKeys(os.Getenv("JWT_KEYS"))
+	if err != nil {
+		return nil, err
+	}
+	pemKeys, err := auth.LoadPEMKeys(os.Getenv("JWT_PEM_KEYS"))
+	if err != nil {
+		return nil, err
+	}
+	keys = append(keys, pemKeys...)
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		key, err := auth.NewHMACKey("default", []byte(secret))
+		if errors.Is(err, auth.ErrShortSecret) {
+			// до следующего релиза короткий секрет принимается с предупреждением
+			log.Printf("WARNING: JWT_SECRET is shorter than 32 bytes; this is deprecated and will fail startup in the next release, set a longer secret or JWT_KEYS")
+			key, err = auth.NewLegacyHMACKey("default", []byte(secret))
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing keys configured, set JWT_KEYS, JWT_PEM_KEYS or JWT_SECRET")
+	}
+
+	active := os.Getenv("JWT_ACTIVE_KEY")
+	if active == "" {
+		active = keys[0].ID
+	}
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		issuer = "bbtg"
+	}
+	audience := os.Getenv("JWT_AUDIENCE")
+	if audience == "" {
+		audience = "bbtg-api"
+	}
+	log.Printf("JWT: %d key(s), active %q, issuer %q, audience %q", len(keys), active, issuer, audience)
+	return auth.NewManager(keys, active, issuer, audience)
+}
+
+// storageFilesPath — путь, по которому сервер раздаёт файлы local и memory
+const storageFilesPath = "/files"
+
+// openStorage выбирает хранилище файлов по STORAGE_BACKEND:
+//
+//	r2     — Cloudflare R2: R2_ENDPOINT, R2_ACCESS_KEY, R2_SECRET_KEY, R2_BUCKET
+//	local  — каталог STORAGE_LOCAL_DIR (по умолчанию ./data/storage)
+//	memory — в памяти процесса, для разработки и тестов
+//
+// Без STORAGE_BACKEND используется R2, если заданы его ключи, иначе хранилища нет.
+// local и memory раздают файлы сами через возвращаемый http.Handler по STORAGE_PUBLIC_URL (по умолчанию
+// http://localhost:<port>/files) и подписывают временные ссылки
+// STORAGE_SIGNING_SECRET (без него — случайным ключом до перезапуска).
+func openStorage(port string) (storage.Backend, http.Handler, error) {
+	backend := os.Getenv("STORAGE_BACKEND")
+	r2Endpoint := os.Getenv("R2_ENDPOINT")
+	r2AccessKey := os.Getenv("R2_ACCESS_KEY")
+	r2SecretKey := os.Getenv("R2_SECRET_KEY")
+	r2Bucket := os.Getenv("R2_BUCKET")
+	if backend == "" {
+		if r2Endpoint == "" || r2AccessKey == "" || r2SecretKey == "" || r2Bucket == "" {
+			log.Printf("Storage not configured, skipping storage initialization")
+			return nil, nil, nil
+		}
+		backend = "r2"
+	}
+
+	baseURL := os.Getenv("STORAGE_PUBLIC_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:" + port + storageFilesPath
+	}
+	secret := []byte(os.Getenv("STORAGE_SIGNING_SECRET"))
+	if len(secret) == 0 && backend != "r2" {
+		log.Printf("STORAGE_SIGNING_SECRET is not set, presigned URLs are valid until restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, nil, err
+		}
+	}
+	signer := storage.NewSigner(secret)
+
+	log.Printf("Storage: %s", backend)
+	switch backend {
+	case "r2":
+		client, err := r2storage.NewClient(r2Endpoint, r2AccessKey, r2SecretKey, r2Bucket)
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, nil, nil
+	case "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "./data/storage"
+		}
+		store, err := local.New(dir, baseURL, signer)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, storage.Handler(store, signer, storageFilesPath), nil
+	case "memory":
+		store := memory.New(baseURL, signer)
+		return store, storage.Handler(store, signer, storageFilesPath), nil
+	}
+	return nil, nil, fmt.Errorf("unknown STORAGE_BACKEND %q, expected r2, local or memory", backend)
+}
+
+// envDuration читает длительность из переменной окружения ("24h", "90s"),
+// возвращая def, если переменная не задана или некорректна
+func envDuration(name string, def time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Printf("invalid %s=%q, using %s", name, raw, def)
+		return def
 	}
+	return d
 }
 
 // Пример функции запуска миграций через golang-migrate
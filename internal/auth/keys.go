@@ -0,0 +1,182 @@
+package auth
+
+import (
+    "crypto/ed25519"
+    "crypto/rsa"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/pem"
+    "errors"
+    "fmt"
+    "math/big"
+    "os"
+    "strings"
+
+    "github.com/golang-jwt/jwt/v5"
+)
+
+// Минимальная длина HMAC-секрета: короче его можно подобрать перебором
+const minSecretLength = 32
+
+// ErrShortSecret — HMAC-секрет короче minSecretLength
+var ErrShortSecret = fmt.Errorf("auth: secret must be at least %d bytes", minSecretLength)
+
+// Key — ключ подписи с идентификатором (kid). Для асимметричных ключей,
+// загруженных только из публичной части, signKey пустой: такой ключ годится
+// лишь для проверки токенов, выпущенных другими сервисами или до ротации.
+type Key struct {
+    ID        string
+    Method    jwt.SigningMethod
+    signKey   interface{}
+    verifyKey interface{}
+}
+
+// CanSign сообщает, можно ли подписывать этим ключом
+func (k *Key) CanSign() bool {
+    return k.signKey != nil
+}
+
+// NewHMACKey создаёт симметричный ключ HS256
+func NewHMACKey(id string, secret []byte) (*Key, error) {
+    if id == "" {
+        return nil, errors.New("auth: key id is required")
+    }
+    if len(secret) < minSecretLength {
+        return nil, fmt.Errorf("%w: key %q", ErrShortSecret, id)
+    }
+    return &Key{ID: id, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}, nil
+}
+
+// NewLegacyHMACKey создаёт ключ HS256 без проверки длины секрета. Нужен
+// только для старой настройки JWT_SECRET, чтобы обновление не роняло запуск.
+//
+// Deprecated: в следующем релизе короткий JWT_SECRET перестанет приниматься,
+// используйте NewHMACKey.
+func NewLegacyHMACKey(id string, secret []byte) (*Key, error) {
+    if id == "" || len(secret) == 0 {
+        return nil, errors.New("auth: key id and secret are required")
+    }
+    return &Key{ID: id, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}, nil
+}
+
+// NewEd25519Key создаёт ключ EdDSA из приватного ключа
+func NewEd25519Key(id string, priv ed25519.PrivateKey) *Key {
+    return &Key{ID: id, Method: jwt.SigningMethodEdDSA, signKey: priv, verifyKey: priv.Public()}
+}
+
+// NewRSAKey создаёт ключ RS256 из приватного ключа
+func NewRSAKey(id string, priv *rsa.PrivateKey) *Key {
+    return &Key{ID: id, Method: jwt.SigningMethodRS256, signKey: priv, verifyKey: &priv.PublicKey}
+}
+
+// ParsePEMKey разбирает PEM с приватным (PKCS#8/PKCS#1) или публичным
+// (PKIX) ключом Ed25519 или RSA
+func ParsePEMKey(id string, data []byte) (*Key, error) {
+    block, _ := pem.Decode(data)
+    if block == nil {
+        return nil, fmt.Errorf("auth: key %q is not PEM encoded", id)
+    }
+
+    switch block.Type {
+    case "PRIVATE KEY":
+        parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+        if err != nil {
+            return nil, fmt.Errorf("auth: key %q: %w", id, err)
+        }
+        switch k := parsed.(type) {
+        case ed25519.PrivateKey:
+            return NewEd25519Key(id, k), nil
+        case *rsa.PrivateKey:
+            return NewRSAKey(id, k), nil
+        }
+    case "RSA PRIVATE KEY":
+        k, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+        if err != nil {
+            return nil, fmt.Errorf("auth: key %q: %w", id, err)
+        }
+        return NewRSAKey(id, k), nil
+    case "PUBLIC KEY":
+        parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+        if err != nil {
+            return nil, fmt.Errorf("auth: key %q: %w", id, err)
+        }
+        switch k := parsed.(type) {
+        case ed25519.PublicKey:
+            return &Key{ID: id, Method: jwt.SigningMethodEdDSA, verifyKey: k}, nil
+        case *rsa.PublicKey:
+            return &Key{ID: id, Method: jwt.SigningMethodRS256, verifyKey: k}, nil
+        }
+    }
+    return nil, fmt.Errorf("auth: key %q has unsupported type %s", id, block.Type)
+}
+
+// ParseHMACKeys разбирает список "kid1:secret1,kid2:secret2"
+func ParseHMACKeys(spec string) ([]*Key, error) {
+    var keys []*Key
+    for _, item := range strings.Split(spec, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+        id, secret, found := strings.Cut(item, ":")
+        if !found {
+            return nil, fmt.Errorf("auth: invalid key spec %q, expected kid:secret", item)
+        }
+        key, err := NewHMACKey(strings.TrimSpace(id), []byte(secret))
+        if err != nil {
+            return nil, err
+        }
+        keys = append(keys, key)
+    }
+    return keys, nil
+}
+
+// LoadPEMKeys читает список "kid1:/path/key1.pem,kid2:/path/key2.pem"
+func LoadPEMKeys(spec string) ([]*Key, error) {
+    var keys []*Key
+    for _, item := range strings.Split(spec, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+        id, path, found := strings.Cut(item, ":")
+        if !found {
+            return nil, fmt.Errorf("auth: invalid key spec %q, expected kid:path", item)
+        }
+        data, err := os.ReadFile(strings.TrimSpace(path))
+        if err != nil {
+            return nil, fmt.Errorf("auth: key %q: %w", id, err)
+        }
+        key, err := ParsePEMKey(strings.TrimSpace(id), data)
+        if err != nil {
+            return nil, err
+        }
+        keys = append(keys, key)
+    }
+    return keys, nil
+}
+
+// jwk возвращает публичную часть ключа в формате JWK; для HMAC — nil
+func (k *Key) jwk() map[string]string {
+    switch pub := k.verifyKey.(type) {
+    case ed25519.PublicKey:
+        return map[string]string{
+            "kty": "OKP",
+            "crv": "Ed25519",
+            "x":   base64.RawURLEncoding.EncodeToString(pub),
+            "kid": k.ID,
+            "alg": k.Method.Alg(),
+            "use": "sig",
+        }
+    case *rsa.PublicKey:
+        return map[string]string{
+            "kty": "RSA",
+            "n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+            "e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+            "kid": k.ID,
+            "alg": k.Method.Alg(),
+            "use": "sig",
+        }
+    }
+    return nil
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+)
+
+var (
+    ErrInvalidToken = errors.New("auth: invalid token")
+    ErrUnknownKey   = errors.New("auth: unknown signing key")
+)
+
+// Claims — claims access-токена. ID (jti) — id сессии.
+type Claims struct {
+    UserID     int    `json:"user_id"`
+    TelegramID int64  `json:"telegram_id,omitempty"`
+    Username   string `json:"username,omitempty"`
+    jwt.RegisteredClaims
+}
+
+// Manager выпускает и проверяет токены. Подписывает активным ключом,
+// а проверяет любым из известных — по kid из заголовка токена. Так секрет
+// можно сменить, не разлогинивая пользователей: новый ключ делается
+// активным, старый остаётся в списке до истечения выданных им токенов.
+type Manager struct {
+    keys     map[string]*Key
+    order    []*Key
+    active   *Key
+    issuer   string
+    audience string
+}
+
+// NewManager создаёт Manager. activeID — kid ключа для подписи новых токенов.
+func NewManager(keys []*Key, activeID, issuer, audience string) (*Manager, error) {
+    if len(keys) == 0 {
+        return nil, errors.New("auth: at least one signing key is required")
+    }
+    m := &Manager{keys: make(map[string]*Key, len(keys)), issuer: issuer, audience: audience}
+    for _, k := range keys {
+        if _, dup := m.keys[k.ID]; dup {
+            return nil, fmt.Errorf("auth: duplicate key id %q", k.ID)
+        }
+        m.keys[k.ID] = k
+        m.order = append(m.order, k)
+    }
+
+    active, ok := m.keys[activeID]
+    if !ok {
+        return nil, fmt.Errorf("auth: active key %q not found", activeID)
+    }
+    if !active.CanSign() {
+        return nil, fmt.Errorf("auth: active key %q has no private part", activeID)
+    }
+    m.active = active
+    return m, nil
+}
+
+// Issue подписывает claims активным ключом; iss, aud, iat и exp проставляются здесь
+func (m *Manager) Issue(claims Claims, ttl time.Duration) (string, error) {
+    now := time.Now()
+    claims.Issuer = m.issuer
+    if m.audience != "" {
+        claims.Audience = jwt.ClaimStrings{m.audience}
+    }
+    claims.IssuedAt = jwt.NewNumericDate(now)
+    claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+
+    tok := jwt.NewWithClaims(m.active.Method, claims)
+    tok.Header["kid"] = m.active.ID
+    return tok.SignedString(m.active.signKey)
+}
+
+// Parse проверяет подпись, срок действия, издателя и аудиторию токена
+func (m *Manager) Parse(tokenString string) (*Claims, error) {
+    opts := []jwt.ParserOption{jwt.WithExpirationRequired(), jwt.WithIssuedAt()}
+    if m.issuer != "" {
+        opts = append(opts, jwt.WithIssuer(m.issuer))
+    }
+    if m.audience != "" {
+        opts = append(opts, jwt.WithAudience(m.audience))
+    }
+
+    var claims Claims
+    _, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
+        kid, _ := t.Header["kid"].(string)
+        key, ok := m.keys[kid]
+        if !ok {
+            return nil, ErrUnknownKey
+        }
+        // алгоритм берём из ключа, а не из заголовка токена
+        if t.Method.Alg() != key.Method.Alg() {
+            return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+        }
+        return key.verifyKey, nil
+    }, opts...)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+    }
+    return &claims, nil
+}
+
+// JWKS возвращает публичные ключи в формате JSON Web Key Set, чтобы другие
+// сервисы могли проверять наши токены. HMAC-ключи сюда не попадают.
+func (m *Manager) JWKS() map[string]interface{} {
+    keys := []map[string]string{}
+    for _, k := range m.order {
+        if jwk := k.jwk(); jwk != nil {
+            keys = append(keys, jwk)
+        }
+    }
+    return map[string]interface{}{"keys": keys}
+}
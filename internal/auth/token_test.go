@@ -0,0 +1,250 @@
+package auth
+
+import (
+    "crypto/ed25519"
+    "crypto/rand"
+    "crypto/rsa"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+)
+
+var (
+    oldSecret = []byte("old-secret-old-secret-old-secret")
+    newSecret = []byte("new-secret-new-secret-new-secret")
+)
+
+func mustHMACKey(t *testing.T, id string, secret []byte) *Key {
+    t.Helper()
+    key, err := NewHMACKey(id, secret)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return key
+}
+
+func TestManagerKeyRotation(t *testing.T) {
+    oldKey, newKey := mustHMACKey(t, "old", oldSecret), mustHMACKey(t, "new", newSecret)
+    before, err := NewManager([]*Key{oldKey}, "old", "bbtg", "bbtg-api")
+    if err != nil {
+        t.Fatal(err)
+    }
+    issued, err := before.Issue(Claims{UserID: 1}, time.Hour)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // после ротации новые токены подписываются новым ключом,
+    // а выданные старым продолжают приниматься
+    after, err := NewManager([]*Key{newKey, oldKey}, "new", "bbtg", "bbtg-api")
+    if err != nil {
+        t.Fatal(err)
+    }
+    claims, err := after.Parse(issued)
+    if err != nil {
+        t.Fatalf("token of old key rejected: %v", err)
+    }
+    if claims.UserID != 1 {
+        t.Errorf("UserID = %d, want 1", claims.UserID)
+    }
+    fresh, err := after.Issue(Claims{UserID: 2}, time.Hour)
+    if err != nil {
+        t.Fatal(err)
+    }
+    tok, _, err := jwt.NewParser().ParseUnverified(fresh, &Claims{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if tok.Header["kid"] != "new" {
+        t.Errorf("kid = %v, want new", tok.Header["kid"])
+    }
+
+    // старый ключ убран из списка — его токены больше не принимаются
+    dropped, err := NewManager([]*Key{newKey}, "new", "bbtg", "bbtg-api")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := dropped.Parse(issued); !errors.Is(err, ErrInvalidToken) {
+        t.Errorf("token of removed key: err = %v, want ErrInvalidToken", err)
+    }
+}
+
+func TestManagerRejectsUnknownKid(t *testing.T) {
+    m, err := NewManager([]*Key{mustHMACKey(t, "a", oldSecret)}, "a", "bbtg", "bbtg-api")
+    if err != nil {
+        t.Fatal(err)
+    }
+    claims := Claims{UserID: 1, RegisteredClaims: jwt.RegisteredClaims{
+        Issuer:    "bbtg",
+        Audience:  jwt.ClaimStrings{"bbtg-api"},
+        ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+    }}
+    for _, kid := range []interface{}{"b", nil} {
+        tok := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+        if kid != nil {
+            tok.Header["kid"] = kid
+        }
+        signed, err := tok.SignedString(oldSecret)
+        if err != nil {
+            t.Fatal(err)
+        }
+        _, err = m.Parse(signed)
+        if !errors.Is(err, ErrInvalidToken) || !strings.Contains(err.Error(), ErrUnknownKey.Error()) {
+            t.Errorf("kid %v: err = %v, want unknown key", kid, err)
+        }
+    }
+}
+
+func TestManagerPinsAlgorithm(t *testing.T) {
+    priv, err := rsa.GenerateKey(rand.Reader, 2048)
+    if err != nil {
+        t.Fatal(err)
+    }
+    m, err := NewManager([]*Key{NewRSAKey("rsa", priv)}, "rsa", "bbtg", "bbtg-api")
+    if err != nil {
+        t.Fatal(err)
+    }
+    // HS256-токен, подписанный публичным ключом RSA как секретом: без
+    // привязки алгоритма к ключу такую подделку можно было бы принять
+    claims := Claims{UserID: 1, RegisteredClaims: jwt.RegisteredClaims{
+        Issuer:    "bbtg",
+        Audience:  jwt.ClaimStrings{"bbtg-api"},
+        ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+    }}
+    tok := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+    tok.Header["kid"] = "rsa"
+    signed, err := tok.SignedString(priv.PublicKey.N.Bytes())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := m.Parse(signed); !errors.Is(err, ErrInvalidToken) {
+        t.Errorf("HS256 token for RSA key: err = %v, want ErrInvalidToken", err)
+    }
+
+    valid, err := m.Issue(Claims{UserID: 1}, time.Hour)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := m.Parse(valid); err != nil {
+        t.Errorf("RS256 token rejected: %v", err)
+    }
+}
+
+func TestManagerRequiredClaims(t *testing.T) {
+    m, err := NewManager([]*Key{mustHMACKey(t, "a", oldSecret)}, "a", "bbtg", "bbtg-api")
+    if err != nil {
+        t.Fatal(err)
+    }
+    exp := jwt.NewNumericDate(time.Now().Add(time.Hour))
+    tests := []struct {
+        name   string
+        claims jwt.RegisteredClaims
+    }{
+        {"no exp", jwt.RegisteredClaims{Issuer: "bbtg", Audience: jwt.ClaimStrings{"bbtg-api"}}},
+        {"expired", jwt.RegisteredClaims{Issuer: "bbtg", Audience: jwt.ClaimStrings{"bbtg-api"},
+            ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))}},
+        {"no iss", jwt.RegisteredClaims{Audience: jwt.ClaimStrings{"bbtg-api"}, ExpiresAt: exp}},
+        {"other iss", jwt.RegisteredClaims{Issuer: "evil", Audience: jwt.ClaimStrings{"bbtg-api"}, ExpiresAt: exp}},
+        {"no aud", jwt.RegisteredClaims{Issuer: "bbtg", ExpiresAt: exp}},
+        {"other aud", jwt.RegisteredClaims{Issuer: "bbtg", Audience: jwt.ClaimStrings{"other"}, ExpiresAt: exp}},
+        {"issued in future", jwt.RegisteredClaims{Issuer: "bbtg", Audience: jwt.ClaimStrings{"bbtg-api"},
+            ExpiresAt: exp, IssuedAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tok := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: 1, RegisteredClaims: tt.claims})
+            tok.Header["kid"] = "a"
+            signed, err := tok.SignedString(oldSecret)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if _, err := m.Parse(signed); !errors.Is(err, ErrInvalidToken) {
+                t.Errorf("err = %v, want ErrInvalidToken", err)
+            }
+        })
+    }
+}
+
+func TestManagerJWKS(t *testing.T) {
+    _, edPriv, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+    if err != nil {
+        t.Fatal(err)
+    }
+    m, err := NewManager([]*Key{
+        NewEd25519Key("ed", edPriv),
+        mustHMACKey(t, "hmac", oldSecret),
+        NewRSAKey("rsa", rsaPriv),
+    }, "ed", "bbtg", "bbtg-api")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    keys := m.JWKS()["keys"].([]map[string]string)
+    if len(keys) != 2 {
+        t.Fatalf("JWKS has %d keys, want 2 (HMAC must not be published)", len(keys))
+    }
+    if k := keys[0]; k["kid"] != "ed" || k["kty"] != "OKP" || k["crv"] != "Ed25519" || k["alg"] != "EdDSA" || k["x"] == "" {
+        t.Errorf("ed25519 jwk = %v", k)
+    }
+    if k := keys[1]; k["kid"] != "rsa" || k["kty"] != "RSA" || k["alg"] != "RS256" || k["e"] != "AQAB" || k["n"] == "" {
+        t.Errorf("rsa jwk = %v", k)
+    }
+    for _, k := range keys {
+        if _, ok := k["d"]; ok {
+            t.Errorf("jwk %s leaks private part", k["kid"])
+        }
+    }
+}
+
+func TestNewManagerValidation(t *testing.T) {
+    a := mustHMACKey(t, "a", oldSecret)
+    _, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+    verifyOnly := &Key{ID: "pub", Method: jwt.SigningMethodEdDSA, verifyKey: edPriv.Public()}
+    tests := []struct {
+        name   string
+        keys   []*Key
+        active string
+    }{
+        {"no keys", nil, "a"},
+        {"duplicate kid", []*Key{a, mustHMACKey(t, "a", newSecret)}, "a"},
+        {"missing active", []*Key{a}, "b"},
+        {"active without private part", []*Key{a, verifyOnly}, "pub"},
+    }
+    for _, tt := range tests {
+        if _, err := NewManager(tt.keys, tt.active, "bbtg", "bbtg-api"); err == nil {
+            t.Errorf("%s: NewManager succeeded", tt.name)
+        }
+    }
+}
+
+func TestShortSecret(t *testing.T) {
+    if _, err := NewHMACKey("a", []byte("short")); !errors.Is(err, ErrShortSecret) {
+        t.Errorf("NewHMACKey: err = %v, want ErrShortSecret", err)
+    }
+    if _, err := ParseHMACKeys("a:short"); !errors.Is(err, ErrShortSecret) {
+        t.Errorf("ParseHMACKeys: err = %v, want ErrShortSecret", err)
+    }
+    // старый JWT_SECRET принимается и подписывает токены
+    legacy, err := NewLegacyHMACKey("default", []byte("short"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    m, err := NewManager([]*Key{legacy}, "default", "bbtg", "bbtg-api")
+    if err != nil {
+        t.Fatal(err)
+    }
+    signed, err := m.Issue(Claims{UserID: 1}, time.Hour)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := m.Parse(signed); err != nil {
+        t.Errorf("legacy key token rejected: %v", err)
+    }
+}
@@ -12,18 +12,26 @@ type AnalyticsEvent struct {
     ID        int    `db:"id" json:"id"`
     LandingID int    `db:"landing_id" json:"landingId"`
     EventType string `db:"event_type" json:"eventType"`
-    GeoCountry string `db:"geo_country" json:"geoCountry"`
-    GeoCity    string `db:"geo_city" json:"geoCity"`
-    IPAddress  string `db:"ip_address" json:"ipAddress"`
-    UserAgent  string `db:"user_agent" json:"userAgent"`
-    CreatedAt  string `db:"created_at" json:"createdAt"`
+    // колонки без NOT NULL: клики пишут NULL, если гео или IP неизвестны
+    GeoCountry *string `db:"geo_country" json:"geoCountry"`
+    GeoCity    *string `db:"geo_city" json:"geoCity"`
+    IPAddress  *string `db:"ip_address" json:"ipAddress"`
+    UserAgent  *string `db:"user_agent" json:"userAgent"`
+    CreatedAt  string  `db:"created_at" json:"createdAt"`
+    LinkID      *int   `db:"link_id" json:"linkId"`
+    Referrer    string `db:"referrer" json:"referrer"`
+    UTMSource   string `db:"utm_source" json:"utmSource"`
+    UTMMedium   string `db:"utm_medium" json:"utmMedium"`
+    UTMCampaign string `db:"utm_campaign" json:"utmCampaign"`
+    UTMTerm     string `db:"utm_term" json:"utmTerm"`
+    UTMContent  string `db:"utm_content" json:"utmContent"`
 }
 
 // RegisterAnalyticsRoutes регистрирует маршруты для аналитики
 func RegisterAnalyticsRoutes(rg *gin.RouterGroup, db *sqlx.DB) {
     r := rg.Group("/analytics")
-    r.GET("", listAnalytics(db))
-    r.POST("", createAnalytics(db))
+    r.GET("", requireScope(ScopeAnalyticsRead), listAnalytics(db))
+    r.POST("", requireScope(ScopeAnalyticsWrite), createAnalytics(db))
 }
 
 // listAnalytics возвращает события аналитики для конкретного лендинга
@@ -35,6 +43,9 @@ func listAnalytics(db *sqlx.DB) gin.HandlerFunc {
             c.JSON(http.StatusBadRequest, gin.H{"error": "invalid landingId"})
             return
         }
+        if !authorizeLanding(c, db, landingID, RoleViewer) {
+            return
+        }
         var items []AnalyticsEvent
         query := `SELECT * FROM analytics WHERE landing_id=$1 ORDER BY created_at DESC`
         if err := db.Select(&items, query, landingID); err != nil {
@@ -61,9 +72,12 @@ func createAnalytics(db *sqlx.DB) gin.HandlerFunc {
             c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
             return
         }
+        if !authorizeLanding(c, db, req.LandingID, RoleEditor) {
+            return
+        }
         var evt AnalyticsEvent
         query := `INSERT INTO analytics (landing_id, event_type, geo_country, geo_city, ip_address, user_agent)
-                  VALUES ($1,$2,$3,$4,$5,$6) RETURNING *`
+                  VALUES ($1,$2,NULLIF($3,''),NULLIF($4,''),NULLIF($5,'')::inet,$6) RETURNING *`
         if err := db.Get(&evt, query,
             req.LandingID, req.EventType, req.GeoCountry, req.GeoCity, req.IPAddress, req.UserAgent); err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
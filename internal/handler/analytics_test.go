@@ -0,0 +1,60 @@
+package handler
+
+import (
+    "encoding/json"
+    "net/http"
+    "regexp"
+    "testing"
+    "time"
+
+    "github.com/DATA-DOG/go-sqlmock"
+    "github.com/gin-gonic/gin"
+)
+
+// analyticsColumns — колонки таблицы analytics в порядке миграций
+var analyticsColumns = []string{"id", "landing_id", "event_type", "geo_country", "geo_city", "ip_address",
+    "user_agent", "created_at", "link_id", "referrer", "utm_source", "utm_medium", "utm_campaign",
+    "utm_term", "utm_content"}
+
+func TestListAnalyticsAfterClick(t *testing.T) {
+    db, mock := newMockDB(t)
+    now := time.Now()
+
+    // клик без гео и IP пишется с NULL в geo_country, geo_city и ip_address
+    mock.ExpectBegin()
+    mock.ExpectExec(regexp.QuoteMeta("INSERT INTO analytics")).
+        WithArgs(1, 7, "", "", "Mozilla/5.0", "", "", "", "", "", "", now).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectExec(regexp.QuoteMeta("UPDATE links SET click_count")).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectCommit()
+    NewClickRecorder(db).flush([]clickEvent{{LandingID: 1, LinkID: 7, UserAgent: "Mozilla/5.0", CreatedAt: now}})
+
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT g.workspace_id, m.role FROM landings g")).
+        WithArgs(1, 1).
+        WillReturnRows(sqlmock.NewRows([]string{"workspace_id", "role"}).AddRow(1, RoleViewer))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM analytics WHERE landing_id=$1")).
+        WithArgs(1).
+        WillReturnRows(sqlmock.NewRows(analyticsColumns).
+            AddRow(1, 1, "click", nil, nil, nil, "Mozilla/5.0", now, 7, "", "", "", "", "", ""))
+
+    router := newTestRouter(1, func(api *gin.RouterGroup) { RegisterAnalyticsRoutes(api, db) })
+    w := doRequest(router, http.MethodGet, "/api/analytics?landingId=1", "")
+    if w.Code != http.StatusOK {
+        t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+    }
+    var items []AnalyticsEvent
+    if err := json.Unmarshal(w.Body.Bytes(), &items); err != nil {
+        t.Fatal(err)
+    }
+    if len(items) != 1 {
+        t.Fatalf("got %d events, want 1", len(items))
+    }
+    evt := items[0]
+    if evt.GeoCountry != nil || evt.GeoCity != nil || evt.IPAddress != nil {
+        t.Errorf("unknown geo/ip must stay null, got %+v", evt)
+    }
+    if evt.LinkID == nil || *evt.LinkID != 7 {
+        t.Errorf("linkId = %v, want 7", evt.LinkID)
+    }
+}
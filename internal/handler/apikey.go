@@ -0,0 +1,253 @@
+package handler
+
+import (
+    "crypto/rand"
+    "crypto/subtle"
+    "database/sql"
+    "encoding/hex"
+    "errors"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+    "github.com/lib/pq"
+)
+
+// Права API-ключей. Сессии пользователя (JWT) имеют все права.
+const (
+    ScopeLandingsRead      = "landings:read"
+    ScopeLandingsWrite     = "landings:write"
+    ScopeLinksRead         = "links:read"
+    ScopeLinksWrite        = "links:write"
+    ScopeLeadsRead         = "leads:read"
+    ScopeLeadsWrite        = "leads:write"
+    ScopeAnalyticsRead     = "analytics:read"
+    ScopeAnalyticsWrite    = "analytics:write"
+    ScopePaymentsRead      = "payments:read"
+    ScopeSubscriptionsRead = "subscriptions:read"
+)
+
+var allScopes = []string{
+    ScopeLandingsRead, ScopeLandingsWrite,
+    ScopeLinksRead, ScopeLinksWrite,
+    ScopeLeadsRead, ScopeLeadsWrite,
+    ScopeAnalyticsRead, ScopeAnalyticsWrite,
+    ScopePaymentsRead, ScopeSubscriptionsRead,
+}
+
+// apiKeyPrefix отличает API-ключ от JWT в заголовке Authorization
+const apiKeyPrefix = "bbtg_"
+
+// ctxScopes — ключ контекста с правами API-ключа; у JWT-сессий его нет
+const ctxScopes = "scopes"
+
+var errInvalidAPIKey = errors.New("invalid api key")
+
+// APIKey — ключ для программного доступа к API. Сам ключ хранится только
+// в виде хэша и показывается один раз при создании.
+type APIKey struct {
+    ID         int            `db:"id" json:"id"`
+    UserID     int            `db:"user_id" json:"userId"`
+    Name       string         `db:"name" json:"name"`
+    Prefix     string         `db:"prefix" json:"prefix"`
+    KeyHash    string         `db:"key_hash" json:"-"`
+    Scopes     pq.StringArray `db:"scopes" json:"scopes"`
+    ExpiresAt  *time.Time     `db:"expires_at" json:"expiresAt"`
+    LastUsedAt *time.Time     `db:"last_used_at" json:"lastUsedAt"`
+    RevokedAt  *time.Time     `db:"revoked_at" json:"revokedAt"`
+    CreatedAt  time.Time      `db:"created_at" json:"createdAt"`
+}
+
+// RegisterAPIKeyRoutes регистрирует управление API-ключами. Доступно только
+// из пользовательской сессии: ключом нельзя выпустить другой ключ.
+func RegisterAPIKeyRoutes(rg *gin.RouterGroup, db *sqlx.DB) {
+    r := rg.Group("/api-keys")
+    r.Use(requireSession())
+    r.GET("", listAPIKeys(db))
+    r.GET("/scopes", func(c *gin.Context) {
+        c.JSON(http.StatusOK, allScopes)
+    })
+    r.POST("", createAPIKey(db))
+    r.DELETE("/:id", revokeAPIKey(db))
+}
+
+// requireScope пропускает API-ключи только с нужным правом
+func requireScope(scope string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        v, isKey := c.Get(ctxScopes)
+        if !isKey {
+            c.Next()
+            return
+        }
+        for _, s := range v.([]string) {
+            if s == scope {
+                c.Next()
+                return
+            }
+        }
+        c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient scope", "scope": scope})
+    }
+}
+
+// requireSession пропускает только запросы с JWT пользовательской сессии
+func requireSession() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if _, isKey := c.Get(ctxScopes); isKey {
+            c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not allowed for api keys"})
+            return
+        }
+        c.Next()
+    }
+}
+
+// authenticateAPIKey находит действующий ключ по его открытой части и
+// сверяет хэш; заодно отмечает время последнего использования
+func authenticateAPIKey(db *sqlx.DB, key string) (APIKey, error) {
+    var k APIKey
+    rest := strings.TrimPrefix(key, apiKeyPrefix)
+    prefix, _, found := strings.Cut(rest, "_")
+    if !found {
+        return k, errInvalidAPIKey
+    }
+
+    err := db.Get(&k, `SELECT * FROM api_keys WHERE prefix=$1`, apiKeyPrefix+prefix)
+    if err == sql.ErrNoRows {
+        return k, errInvalidAPIKey
+    }
+    if err != nil {
+        return k, err
+    }
+    if subtle.ConstantTimeCompare([]byte(hashToken(key)), []byte(k.KeyHash)) != 1 {
+        return k, errInvalidAPIKey
+    }
+    if k.RevokedAt != nil || (k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)) {
+        return k, errInvalidAPIKey
+    }
+
+    // не чаще раза в минуту, чтобы не писать в БД на каждый запрос
+    _, err = db.Exec(`UPDATE api_keys SET last_used_at=NOW()
+                      WHERE id=$1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`, k.ID)
+    return k, err
+}
+
+// listAPIKeys возвращает ключи текущего пользователя (без самих ключей)
+func listAPIKeys(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+        var items []APIKey
+        if err := db.Select(&items,
+            "SELECT * FROM api_keys WHERE user_id=$1 ORDER BY created_at DESC", uid); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusOK, items)
+    }
+}
+
+// createAPIKey выпускает новый ключ; полный ключ возвращается только здесь
+func createAPIKey(db *sqlx.DB) gin.HandlerFunc {
+    type request struct {
+        Name      string     `json:"name" binding:"required"`
+        Scopes    []string   `json:"scopes" binding:"required"`
+        ExpiresAt *time.Time `json:"expiresAt"`
+    }
+    return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+
+        errs := fieldErrors{}
+        if len(req.Scopes) == 0 {
+            errs["scopes"] = "at least one scope is required"
+        }
+        for _, s := range req.Scopes {
+            if !knownScope(s) {
+                errs["scopes"] = "unknown scope " + s
+            }
+        }
+        if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+            errs["expiresAt"] = "must be in the future"
+        }
+        if len(errs) > 0 {
+            respondFieldErrors(c, errs)
+            return
+        }
+
+        key, prefix, err := newAPIKey()
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+
+        var item APIKey
+        query := `INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+                  VALUES ($1,$2,$3,$4,$5,$6) RETURNING *`
+        if err := db.Get(&item, query, uid, req.Name, prefix, hashToken(key),
+            pq.StringArray(req.Scopes), req.ExpiresAt); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusCreated, gin.H{"apiKey": item, "key": key})
+    }
+}
+
+// revokeAPIKey отзывает ключ текущего пользователя
+func revokeAPIKey(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+        id, err := strconv.Atoi(c.Param("id"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+            return
+        }
+        res, err := db.Exec(`UPDATE api_keys SET revoked_at=NOW()
+                             WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL`, id, uid)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if n, _ := res.RowsAffected(); n == 0 {
+            c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+        c.Status(http.StatusNoContent)
+    }
+}
+
+func knownScope(scope string) bool {
+    for _, s := range allScopes {
+        if s == scope {
+            return true
+        }
+    }
+    return false
+}
+
+// newAPIKey генерирует ключ вида bbtg_<prefix>_<secret> и его открытую часть
+func newAPIKey() (key, prefix string, err error) {
+    buf := make([]byte, 6)
+    if _, err := rand.Read(buf); err != nil {
+        return "", "", err
+    }
+    prefix = apiKeyPrefix + hex.EncodeToString(buf)
+    secret, err := randomToken(32)
+    if err != nil {
+        return "", "", err
+    }
+    return prefix + "_" + secret, prefix, nil
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "regexp"
+    "testing"
+    "time"
+
+    "github.com/DATA-DOG/go-sqlmock"
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+    "github.com/lib/pq"
+)
+
+const testAPIKey = "bbtg_abcd_secret"
+
+var apiKeyColumns = []string{"id", "user_id", "name", "prefix", "key_hash", "scopes",
+    "expires_at", "last_used_at", "revoked_at", "created_at"}
+
+// newAPIKeyRouter собирает /api с AuthMiddleware, как в main
+func newAPIKeyRouter(db *sqlx.DB) *gin.Engine {
+    gin.SetMode(gin.TestMode)
+    router := gin.New()
+    api := router.Group("/api")
+    api.Use(AuthMiddleware(db, AuthConfig{}))
+    RegisterAnalyticsRoutes(api, db)
+    RegisterAPIKeyRoutes(api, db)
+    RegisterWorkspaceRoutes(api, db, nil)
+    return router
+}
+
+func doKeyRequest(router *gin.Engine, method, target string) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(method, target, nil)
+    req.Header.Set("Authorization", "Bearer "+testAPIKey)
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, req)
+    return w
+}
+
+func expectAPIKey(mock sqlmock.Sqlmock, scopes []string, expiresAt, revokedAt interface{}) {
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM api_keys WHERE prefix=$1")).
+        WithArgs("bbtg_abcd").
+        WillReturnRows(sqlmock.NewRows(apiKeyColumns).
+            AddRow(1, 1, "ci", "bbtg_abcd", hashToken(testAPIKey), pq.StringArray(scopes),
+                expiresAt, nil, revokedAt, time.Now()))
+}
+
+func TestAPIKeyMissingScope(t *testing.T) {
+    db, mock := newMockDB(t)
+    expectAPIKey(mock, []string{ScopeAnalyticsRead}, nil, nil)
+    mock.ExpectExec(regexp.QuoteMeta("UPDATE api_keys SET last_used_at=NOW()")).
+        WithArgs(1).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+
+    // право на чтение не даёт писать
+    w := doKeyRequest(newAPIKeyRouter(db), http.MethodPost, "/api/analytics")
+    if w.Code != http.StatusForbidden {
+        t.Errorf("status = %d, want 403, body = %s", w.Code, w.Body)
+    }
+}
+
+func TestAPIKeyRejected(t *testing.T) {
+    past := time.Now().Add(-time.Hour)
+    tests := []struct {
+        name      string
+        expiresAt interface{}
+        revokedAt interface{}
+    }{
+        {"revoked", nil, past},
+        {"expired", past, nil},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            db, mock := newMockDB(t)
+            // last_used_at не обновляется
+            expectAPIKey(mock, allScopes, tt.expiresAt, tt.revokedAt)
+
+            w := doKeyRequest(newAPIKeyRouter(db), http.MethodGet, "/api/analytics?landingId=1")
+            if w.Code != http.StatusUnauthorized {
+                t.Errorf("status = %d, want 401, body = %s", w.Code, w.Body)
+            }
+        })
+    }
+}
+
+func TestAPIKeyWrongSecret(t *testing.T) {
+    db, mock := newMockDB(t)
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM api_keys WHERE prefix=$1")).
+        WithArgs("bbtg_abcd").
+        WillReturnRows(sqlmock.NewRows(apiKeyColumns).
+            AddRow(1, 1, "ci", "bbtg_abcd", hashToken("bbtg_abcd_other"), pq.StringArray(allScopes),
+                nil, nil, nil, time.Now()))
+
+    w := doKeyRequest(newAPIKeyRouter(db), http.MethodGet, "/api/analytics?landingId=1")
+    if w.Code != http.StatusUnauthorized {
+        t.Errorf("status = %d, want 401, body = %s", w.Code, w.Body)
+    }
+}
+
+func TestAPIKeyCannotReachSessionRoutes(t *testing.T) {
+    // даже ключ со всеми правами не управляет ключами и пространствами
+    targets := []struct{ method, path string }{
+        {http.MethodGet, "/api/api-keys"},
+        {http.MethodPost, "/api/api-keys"},
+        {http.MethodDelete, "/api/api-keys/1"},
+        {http.MethodGet, "/api/workspaces"},
+    }
+    for _, tt := range targets {
+        t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+            db, mock := newMockDB(t)
+            expectAPIKey(mock, allScopes, nil, nil)
+            mock.ExpectExec(regexp.QuoteMeta("UPDATE api_keys SET last_used_at=NOW()")).
+                WithArgs(1).
+                WillReturnResult(sqlmock.NewResult(0, 1))
+
+            w := doKeyRequest(newAPIKeyRouter(db), tt.method, tt.path)
+            if w.Code != http.StatusForbidden {
+                t.Errorf("status = %d, want 403, body = %s", w.Code, w.Body)
+            }
+        })
+    }
+}
+
+func TestRequireScopeSkipsSessions(t *testing.T) {
+    // у JWT-сессии нет ctxScopes — ей доступно всё
+    router := newTestRouter(1, func(api *gin.RouterGroup) {
+        api.GET("/x", requireScope(ScopeLeadsWrite), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+    })
+    if w := doRequest(router, http.MethodGet, "/api/x", ""); w.Code != http.StatusNoContent {
+        t.Errorf("status = %d, want 204", w.Code)
+    }
+}
@@ -0,0 +1,437 @@
+package handler
+
+import (
+    "archive/zip"
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "image"
+    "io"
+    "log"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+    "unicode/utf8"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+
+    "github.com/blagoweb/bbtg/internal/storage"
+)
+
+// Формат архива лендинга: zip с manifest.json и файлами media/<sha256>.<ext>
+const (
+    archiveFormat  = "bbtg.landing"
+    archiveVersion = 1 // увеличивается при несовместимых изменениях манифеста
+
+    maxArchiveSize  = 50 << 20 // весь архив
+    maxArchiveMedia = 100      // файлов в архиве
+    maxArchiveFile  = 10 << 20 // один файл media
+    archiveManifest = "manifest.json"
+
+    maxImportDescription = 1000 // символов в описании импортируемого лендинга
+)
+
+// archiveManifestV1 — содержимое manifest.json
+type archiveManifestV1 struct {
+    Format     string         `json:"format"`
+    Version    int            `json:"version"`
+    ExportedAt time.Time      `json:"exportedAt"`
+    Landing    archiveLanding `json:"landing"`
+    Media      []archiveMedia `json:"media"`
+}
+
+// archiveLanding — содержимое и настройки лендинга
+type archiveLanding struct {
+    Slug     string          `json:"slug"`
+    Snapshot LandingSnapshot `json:"snapshot"`
+}
+
+// archiveMedia связывает исходный URL с файлом внутри архива
+type archiveMedia struct {
+    URL         string `json:"url"`
+    File        string `json:"file"`
+    ContentType string `json:"contentType"`
+    Size        int    `json:"size"`
+    SHA256      string `json:"sha256"`
+}
+
+// registerArchiveRoutes регистрирует экспорт и импорт лендингов
+func registerArchiveRoutes(r *gin.RouterGroup, db *sqlx.DB, store storage.Backend) {
+    read, write := requireScope(ScopeLandingsRead), requireScope(ScopeLandingsWrite)
+    r.GET("/:id/export", read, requireLandingRole(db, "id", RoleViewer), exportLanding(db, store))
+    r.POST("/import", write, importLanding(db, store))
+}
+
+// mapMediaURLs применяет fn ко всем ссылкам на медиа в снимке: аватару,
+// фону темы и картинкам в блоках
+func mapMediaURLs(snap *LandingSnapshot, fn func(string) string) error {
+    if snap.AvatarURL != "" {
+        snap.AvatarURL = fn(snap.AvatarURL)
+    }
+    if bg := snap.Theme.Background; bg != nil && bg.ImageURL != "" {
+        copied := *bg
+        copied.ImageURL = fn(bg.ImageURL)
+        snap.Theme.Background = &copied
+    }
+    blocks := make(Blocks, len(snap.Blocks))
+    for i, b := range snap.Blocks {
+        if b.Type == BlockImage {
+            var data map[string]interface{}
+            if err := json.Unmarshal(b.Data, &data); err != nil {
+                return err
+            }
+            if u, ok := data["url"].(string); ok && u != "" {
+                data["url"] = fn(u)
+            }
+            raw, err := json.Marshal(data)
+            if err != nil {
+                return err
+            }
+            b.Data = raw
+        }
+        blocks[i] = b
+    }
+    snap.Blocks = blocks
+    return nil
+}
+
+// exportLanding отдаёт черновик лендинга zip-архивом. Медиа из нашего
+// хранилища кладутся в архив, внешние ссылки остаются как есть.
+func exportLanding(db *sqlx.DB, store storage.Backend) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var landing Landing
+        if err := db.Get(&landing, "SELECT * FROM landings WHERE id=$1", c.GetInt(ctxLandingID)); err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+        snap, err := captureSnapshot(db, landing)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+
+        manifest := archiveManifestV1{
+            Format:     archiveFormat,
+            Version:    archiveVersion,
+            ExportedAt: time.Now().UTC(),
+            Landing:    archiveLanding{Slug: landing.Slug, Snapshot: blueprintOf(snap)},
+            Media:      []archiveMedia{},
+        }
+        files := map[string][]byte{}
+        seen := map[string]bool{}
+        var fetchErr error
+        mapErr := mapMediaURLs(&manifest.Landing.Snapshot, func(u string) string {
+            if store == nil || seen[u] || fetchErr != nil {
+                return u
+            }
+            key, ok := store.KeyFromURL(u)
+            if !ok {
+                return u
+            }
+            seen[u] = true
+            data, err := store.Get(key)
+            if err != nil {
+                fetchErr = fmt.Errorf("%s: %w", u, err)
+                return u
+            }
+            sum := sha256.Sum256(data)
+            contentType := http.DetectContentType(data)
+            if _, ok := mediaExtensions[contentType]; !ok {
+                return u
+            }
+            name := "media/" + hex.EncodeToString(sum[:]) + mediaExtensions[contentType]
+            files[name] = data
+            manifest.Media = append(manifest.Media, archiveMedia{
+                URL: u, File: name, ContentType: contentType, Size: len(data), SHA256: hex.EncodeToString(sum[:]),
+            })
+            return u
+        })
+        if mapErr != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": mapErr.Error()})
+            return
+        }
+        if fetchErr != nil {
+            c.JSON(http.StatusBadGateway, gin.H{"error": "failed to fetch media: " + fetchErr.Error()})
+            return
+        }
+
+        var buf bytes.Buffer
+        zw := zip.NewWriter(&buf)
+        mw, err := zw.Create(archiveManifest)
+        if err == nil {
+            enc := json.NewEncoder(mw)
+            enc.SetIndent("", "  ")
+            err = enc.Encode(manifest)
+        }
+        written := map[string]bool{}
+        for _, m := range manifest.Media {
+            if err != nil {
+                break
+            }
+            if written[m.File] {
+                continue
+            }
+            written[m.File] = true
+            var fw io.Writer
+            if fw, err = zw.Create(m.File); err == nil {
+                _, err = fw.Write(files[m.File])
+            }
+        }
+        if err == nil {
+            err = zw.Close()
+        }
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+
+        filename := fmt.Sprintf("landing-%s-%s.zip", landing.Slug, manifest.ExportedAt.Format("20060102"))
+        c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+        c.Data(http.StatusOK, "application/zip", buf.Bytes())
+    }
+}
+
+var errArchiveInvalid = errors.New("invalid archive")
+
+// readArchive разбирает и проверяет архив: формат, версию манифеста,
+// размеры и контрольные суммы файлов
+func readArchive(data []byte) (archiveManifestV1, map[string][]byte, error) {
+    var manifest archiveManifestV1
+    zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+    if err != nil {
+        return manifest, nil, fmt.Errorf("%w: %v", errArchiveInvalid, err)
+    }
+    entries := map[string]*zip.File{}
+    for _, f := range zr.File {
+        entries[f.Name] = f
+    }
+    readEntry := func(name string, limit int64) ([]byte, error) {
+        f, ok := entries[name]
+        if !ok {
+            return nil, fmt.Errorf("%w: %s is missing", errArchiveInvalid, name)
+        }
+        rc, err := f.Open()
+        if err != nil {
+            return nil, fmt.Errorf("%w: %s: %v", errArchiveInvalid, name, err)
+        }
+        defer rc.Close()
+        // не доверяем размеру из заголовка zip: читаем с ограничением
+        b, err := io.ReadAll(io.LimitReader(rc, limit+1))
+        if err != nil {
+            return nil, fmt.Errorf("%w: %s: %v", errArchiveInvalid, name, err)
+        }
+        if int64(len(b)) > limit {
+            return nil, fmt.Errorf("%w: %s is too large", errArchiveInvalid, name)
+        }
+        return b, nil
+    }
+
+    raw, err := readEntry(archiveManifest, 5<<20)
+    if err != nil {
+        return manifest, nil, err
+    }
+    if err := json.Unmarshal(raw, &manifest); err != nil {
+        return manifest, nil, fmt.Errorf("%w: manifest: %v", errArchiveInvalid, err)
+    }
+    if manifest.Format != archiveFormat {
+        return manifest, nil, fmt.Errorf("%w: unknown format %q", errArchiveInvalid, manifest.Format)
+    }
+    if manifest.Version < 1 || manifest.Version > archiveVersion {
+        return manifest, nil, fmt.Errorf("%w: unsupported manifest version %d (supported up to %d)",
+            errArchiveInvalid, manifest.Version, archiveVersion)
+    }
+    if len(manifest.Media) > maxArchiveMedia {
+        return manifest, nil, fmt.Errorf("%w: too many media files", errArchiveInvalid)
+    }
+
+    files := map[string][]byte{}
+    for _, m := range manifest.Media {
+        b, err := readEntry(m.File, maxArchiveFile)
+        if err != nil {
+            return manifest, nil, err
+        }
+        sum := sha256.Sum256(b)
+        if hex.EncodeToString(sum[:]) != m.SHA256 {
+            return manifest, nil, fmt.Errorf("%w: %s checksum mismatch", errArchiveInvalid, m.File)
+        }
+        if _, ok := mediaExtensions[http.DetectContentType(b)]; !ok {
+            return manifest, nil, fmt.Errorf("%w: %s has unsupported type", errArchiveInvalid, m.File)
+        }
+        files[m.File] = b
+    }
+    return manifest, files, nil
+}
+
+// validateImportedLanding проверяет поля лендинга из архива и его ссылки
+// (ключи ошибок ссылок — links.N.*); ссылки нормализуются на месте
+func validateImportedLanding(snap *LandingSnapshot) fieldErrors {
+    errs := validateBlueprintLinks(snap.Links)
+    if strings.TrimSpace(snap.Title) == "" {
+        errs["title"] = "is required"
+    } else if utf8.RuneCountInString(snap.Title) > 255 {
+        errs["title"] = "must be at most 255 characters"
+    }
+    if utf8.RuneCountInString(snap.Description) > maxImportDescription {
+        errs["description"] = fmt.Sprintf("must be at most %d characters", maxImportDescription)
+    }
+    if snap.AvatarURL != "" {
+        if u, err := url.Parse(snap.AvatarURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            errs["avatarUrl"] = "must be a valid http(s) URL"
+        }
+    }
+    return errs
+}
+
+// importLanding создаёт новый лендинг из архива (multipart, поле archive).
+// Медиа заново загружаются в хранилище, id лендинга, ссылок и блоков
+// выдаются новые; всё содержимое создаётся в одной транзакции.
+func importLanding(db *sqlx.DB, store storage.Backend) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+        c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxArchiveSize+1<<20)
+        fh, err := c.FormFile("archive")
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "archive file is required"})
+            return
+        }
+        if fh.Size > maxArchiveSize {
+            c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "archive is too large"})
+            return
+        }
+        f, err := fh.Open()
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        data, err := io.ReadAll(f)
+        f.Close()
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+
+        manifest, files, err := readArchive(data)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        snap := manifest.Landing.Snapshot
+        if errs := validateBlocks(snap.Blocks); errs != nil {
+            respondFieldErrors(c, errs)
+            return
+        }
+        if errs := validateTheme(snap.Theme); errs != nil {
+            respondFieldErrors(c, errs)
+            return
+        }
+        if errs := validateImportedLanding(&snap); len(errs) > 0 {
+            respondFieldErrors(c, errs)
+            return
+        }
+        if len(files) > 0 && store == nil {
+            c.JSON(http.StatusServiceUnavailable, gin.H{"error": "media storage is not configured"})
+            return
+        }
+
+        workspaceID := 0
+        if raw := c.PostForm("workspaceId"); raw != "" {
+            if workspaceID, err = strconv.Atoi(raw); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workspaceId"})
+                return
+            }
+        }
+        if workspaceID == 0 {
+            if workspaceID, err = personalWorkspaceID(db, uid); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                return
+            }
+        }
+        if !authorizeWorkspace(c, db, workspaceID, RoleEditor) {
+            return
+        }
+
+        // загружаем медиа до транзакции: ключи адресуются содержимым, поэтому
+        // повторный импорт или откат не оставляют дубликатов
+        urls := map[string]string{}
+        for _, m := range manifest.Media {
+            b := files[m.File]
+            cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
+            if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%v: %s is not a valid image", errArchiveInvalid, m.File)})
+                return
+            }
+            item, err := storeMedia(db, store, Media{
+                WorkspaceID: workspaceID, Kind: MediaImage, UploadedBy: &uid,
+                ContentType: http.DetectContentType(b), Width: cfg.Width, Height: cfg.Height,
+            }, b)
+            if err != nil {
+                log.Printf("import media %s error: %v", m.File, err)
+                c.JSON(http.StatusBadGateway, gin.H{"error": "failed to upload media"})
+                return
+            }
+            urls[m.URL] = item.URL
+        }
+        if err := mapMediaURLs(&snap, func(u string) string {
+            if n, ok := urls[u]; ok {
+                return n
+            }
+            return u
+        }); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+
+        tx, err := db.Beginx()
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        defer tx.Rollback()
+
+        // slug из архива берём, если он свободен; иначе — новый
+        slug, err := checkSlug(tx, firstNonEmpty(c.PostForm("slug"), manifest.Landing.Slug), 0)
+        if err != nil {
+            if c.PostForm("slug") != "" {
+                respondSlugError(c, err)
+                return
+            }
+            if slug, err = generateSlug(tx); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                return
+            }
+        }
+
+        item, err := instantiateBlueprint(tx, uid, workspaceID, slug, blueprintOf(snap))
+        if err != nil {
+            if isUniqueViolation(err) {
+                respondSlugError(c, errSlugTaken)
+                return
+            }
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if err := tx.Commit(); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusCreated, item)
+    }
+}
+
+func firstNonEmpty(values ...string) string {
+    for _, v := range values {
+        if v != "" {
+            return v
+        }
+    }
+    return ""
+}
@@ -0,0 +1,310 @@
+package handler
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+
+    "github.com/blagoweb/bbtg/internal/auth"
+    "github.com/blagoweb/bbtg/internal/telegram"
+)
+
+// AuthConfig — настройки авторизации
+type AuthConfig struct {
+    TelegramToken string           // токен бота, которым подписан initData
+    Tokens        *auth.Manager    // выпуск и проверка JWT
+    InitData      telegram.Options // проверка свежести и повторов initData
+    VerifyEnabled bool             // включить /auth/verify для партнёрских сервисов
+    AccessTTL     time.Duration    // время жизни access-токена
+    RefreshTTL    time.Duration    // время жизни сессии без обновления
+}
+
+// ctxSessionID — ключ контекста с id сессии (jti) текущего запроса
+const ctxSessionID = "session_id"
+
+// RegisterAuthRoutes регистрирует маршруты авторизации (без AuthMiddleware)
+func RegisterAuthRoutes(rg *gin.RouterGroup, db *sqlx.DB, cfg AuthConfig) {
+    r := rg.Group("/auth")
+    r.POST("/login", HandleLogin(db, cfg))
+    r.POST("/widget", HandleWidgetLogin(db, cfg))
+    r.POST("/refresh", refreshSession(db, cfg))
+    r.GET("/jwks.json", func(c *gin.Context) {
+        c.JSON(http.StatusOK, cfg.Tokens.JWKS())
+    })
+    r.POST("/logout", AuthMiddleware(db, cfg), requireSession(), logout(db))
+    r.POST("/logout-all", AuthMiddleware(db, cfg), requireSession(), logoutAll(db))
+    if cfg.VerifyEnabled {
+        r.POST("/verify", verifyInitData(cfg))
+    }
+}
+
+// verifyInitData проверяет initData по Ed25519-подписи Telegram: так
+// партнёрские сервисы без нашего токена бота могут проверить сессию Mini App
+func verifyInitData(cfg AuthConfig) gin.HandlerFunc {
+    type request struct {
+        InitData    string `json:"initData" binding:"required"`
+        BotID       int64  `json:"botId"`
+        Environment string `json:"environment"`
+    }
+    return func(c *gin.Context) {
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+
+        // по умолчанию проверяем для нашего бота
+        botID := req.BotID
+        if botID == 0 {
+            id, err := telegram.BotIDFromToken(cfg.TelegramToken)
+            if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "botId is required"})
+                return
+            }
+            botID = id
+        }
+        env := telegram.EnvProduction
+        if req.Environment != "" {
+            env = telegram.Environment(req.Environment)
+        }
+
+        // проверка не должна «расходовать» initData, поэтому без кэша повторов
+        opts := cfg.InitData
+        opts.Replay = nil
+        data, err := telegram.CheckSignature(req.InitData, botID, env, opts)
+        if err != nil {
+            c.JSON(http.StatusUnauthorized, gin.H{"valid": false, "error": initDataErrorMessage(err)})
+            return
+        }
+        c.JSON(http.StatusOK, gin.H{
+            "valid":      true,
+            "botId":      botID,
+            "user":       data.User,
+            "chat":       data.Chat,
+            "startParam": data.StartParam,
+            "authDate":   data.AuthDate.Unix(),
+        })
+    }
+}
+
+// HandleLogin обрабатывает авторизацию через Telegram WebApp
+func HandleLogin(db *sqlx.DB, cfg AuthConfig) gin.HandlerFunc {
+    type loginReq struct {
+        InitData string `json:"initData" binding:"required"`
+    }
+    return func(c *gin.Context) {
+        var req loginReq
+        if err := c.ShouldBindJSON(&req); err != nil {
+            log.Printf("Login request binding error: %v", err)
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+
+        // Проверяем подпись данных от Telegram
+        data, err := telegram.CheckAuthData(req.InitData, cfg.TelegramToken, cfg.InitData)
+        if err != nil {
+            log.Printf("Telegram auth data validation failed: %v", err)
+            c.JSON(http.StatusUnauthorized, gin.H{"error": initDataErrorMessage(err)})
+            return
+        }
+        if data.User == nil || data.User.ID == 0 {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "missing user_id in telegram data"})
+            return
+        }
+        loginTelegramUser(c, db, cfg, profileFromWebApp(data.User))
+    }
+}
+
+// HandleWidgetLogin обрабатывает вход через Telegram Login Widget (веб-кабинет).
+// Тело запроса — объект, который виджет передаёт в onauth-колбэк.
+func HandleWidgetLogin(db *sqlx.DB, cfg AuthConfig) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var raw map[string]interface{}
+        dec := json.NewDecoder(c.Request.Body)
+        dec.UseNumber()
+        if err := dec.Decode(&raw); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+            return
+        }
+        // в подписи участвуют строковые значения полей
+        fields := make(map[string]string, len(raw))
+        for k, v := range raw {
+            fields[k] = fmt.Sprint(v)
+        }
+
+        wu, err := telegram.CheckWidgetData(fields, cfg.TelegramToken, cfg.InitData)
+        if err != nil {
+            log.Printf("Telegram widget data validation failed: %v", err)
+            c.JSON(http.StatusUnauthorized, gin.H{"error": initDataErrorMessage(err)})
+            return
+        }
+
+        loginTelegramUser(c, db, cfg, TelegramProfile{
+            ID:        wu.ID,
+            Username:  wu.Username,
+            FirstName: wu.FirstName,
+            LastName:  wu.LastName,
+            PhotoURL:  wu.PhotoURL,
+        })
+    }
+}
+
+// loginTelegramUser заводит (или обновляет) пользователя по профилю Telegram,
+// открывает сессию и отдаёт токены — общий хвост для всех способов входа
+func loginTelegramUser(c *gin.Context, db *sqlx.DB, cfg AuthConfig, profile TelegramProfile) {
+    // Дальше везде используется внутренний id пользователя
+    user, err := upsertTelegramUser(db, profile)
+    if err != nil {
+        log.Printf("User upsert failed for telegram id %d: %v", profile.ID, err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save user"})
+        return
+    }
+
+    if err := ensurePersonalWorkspace(db, user); err != nil {
+        log.Printf("Personal workspace creation failed for user %d: %v", user.ID, err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save user"})
+        return
+    }
+
+    session, refresh, err := startSession(db, user.ID, c, cfg.RefreshTTL)
+    if err != nil {
+        log.Printf("Session creation failed for user %d: %v", user.ID, err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+        return
+    }
+
+    log.Printf("Login successful for user: %d (telegram %d)", user.ID, user.TelegramID)
+    respondTokens(c, user, session, refresh, cfg)
+}
+
+// respondTokens отдаёт клиенту access- и refresh-токены сессии
+func respondTokens(c *gin.Context, user User, session Session, refresh string, cfg AuthConfig) {
+    signed, err := issueToken(user, session.ID, cfg)
+    if err != nil {
+        log.Printf("JWT generation failed: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "token":        signed,
+        "expiresIn":    int(cfg.AccessTTL.Seconds()),
+        "refreshToken": refresh,
+        "user":         user,
+    })
+}
+
+// initDataErrorMessage — текст ошибки для клиента по типу ошибки проверки
+func initDataErrorMessage(err error) string {
+    switch {
+    case errors.Is(err, telegram.ErrExpired):
+        return "telegram data expired"
+    case errors.Is(err, telegram.ErrReplayed):
+        return "telegram data already used"
+    case errors.Is(err, telegram.ErrBadSignature):
+        return "invalid telegram signature"
+    case errors.Is(err, telegram.ErrMalformed):
+        return "malformed telegram data"
+    default:
+        return "invalid telegram data"
+    }
+}
+
+// profileFromWebApp переводит пользователя Mini App в профиль для upsert
+func profileFromWebApp(u *telegram.WebAppUser) TelegramProfile {
+    return TelegramProfile{
+        ID:           u.ID,
+        Username:     u.Username,
+        FirstName:    u.FirstName,
+        LastName:     u.LastName,
+        LanguageCode: u.LanguageCode,
+        IsPremium:    &u.IsPremium,
+        PhotoURL:     u.PhotoURL,
+    }
+}
+
+// issueToken генерирует JWT токен с внутренним id пользователя и id сессии в jti
+func issueToken(user User, sessionID string, cfg AuthConfig) (string, error) {
+    claims := auth.Claims{
+        UserID:     user.ID,
+        TelegramID: user.TelegramID,
+        Username:   user.DisplayName(),
+    }
+    claims.ID = sessionID
+    return cfg.Tokens.Issue(claims, cfg.AccessTTL)
+}
+
+// AuthMiddleware проверяет JWT токен (и что его сессия не отозвана) или
+// API-ключ и добавляет user_id в контекст. Для API-ключа в контекст
+// попадают и его права — их проверяет requireScope.
+func AuthMiddleware(db *sqlx.DB, cfg AuthConfig) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        authHeader := c.GetHeader("Authorization")
+        if authHeader == "" {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "no authorization header"})
+            c.Abort()
+            return
+        }
+
+        // Убираем "Bearer " префикс
+        tokenString := authHeader
+        if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+            tokenString = authHeader[7:]
+        }
+
+        if strings.HasPrefix(tokenString, apiKeyPrefix) {
+            key, err := authenticateAPIKey(db, tokenString)
+            if err != nil {
+                if err == errInvalidAPIKey {
+                    c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+                } else {
+                    c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                }
+                c.Abort()
+                return
+            }
+            c.Set("user_id", strconv.Itoa(key.UserID))
+            c.Set(ctxScopes, []string(key.Scopes))
+            c.Next()
+            return
+        }
+
+        // Проверяем подпись, срок, издателя и аудиторию токена
+        claims, err := cfg.Tokens.Parse(tokenString)
+        if err != nil {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+            c.Abort()
+            return
+        }
+
+        // Токены без jti не привязаны к сессии и не могут быть отозваны —
+        // такие не принимаем
+        if claims.UserID != 0 && claims.ID != "" {
+            active, err := sessionActive(db, claims.ID, claims.UserID)
+            if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                c.Abort()
+                return
+            }
+            if !active {
+                c.JSON(http.StatusUnauthorized, gin.H{"error": "session revoked"})
+                c.Abort()
+                return
+            }
+            c.Set("user_id", strconv.Itoa(claims.UserID))
+            c.Set(ctxSessionID, claims.ID)
+            c.Next()
+            return
+        }
+
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
+        c.Abort()
+    }
+}
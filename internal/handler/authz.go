@@ -0,0 +1,216 @@
+package handler
+
+import (
+    "database/sql"
+    "fmt"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+)
+
+// Роли участников рабочего пространства
+const (
+    RoleOwner  = "owner"  // управляет участниками и пространством
+    RoleEditor = "editor" // редактирует лендинги
+    RoleViewer = "viewer" // только просмотр
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// roleAtLeast сообщает, что роль role не ниже min
+func roleAtLeast(role, min string) bool {
+    return role != "" && roleRank[role] >= roleRank[min]
+}
+
+// Ключи контекста, которые выставляют загрузчики ресурсов ниже
+const (
+    ctxLandingID   = "landing_id"
+    ctxLinkID      = "link_id"
+    ctxWorkspaceID = "workspace_id"
+    ctxRole        = "role"
+)
+
+// currentUserID достаёт внутренний id пользователя, положенный AuthMiddleware.
+// При ошибке сам отвечает 401.
+func currentUserID(c *gin.Context) (int, bool) {
+    uidI, exists := c.Get("user_id")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id not found"})
+        return 0, false
+    }
+    uid, err := strconv.Atoi(fmt.Sprint(uidI))
+    if err != nil {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user_id"})
+        return 0, false
+    }
+    return uid, true
+}
+
+// workspaceRole возвращает роль пользователя в пространстве ("" — не участник)
+func workspaceRole(q sqlx.Queryer, workspaceID, userID int) (string, error) {
+    var role string
+    err := sqlx.Get(q, &role,
+        `SELECT role FROM workspace_members WHERE workspace_id=$1 AND user_id=$2`, workspaceID, userID)
+    if err == sql.ErrNoRows {
+        return "", nil
+    }
+    return role, err
+}
+
+// landingRole возвращает пространство лендинга и роль пользователя в нём
+// ("" — лендинга нет или пользователь не состоит в его пространстве)
+func landingRole(q sqlx.Queryer, landingID, userID int) (int, string, error) {
+    var row struct {
+        WorkspaceID int    `db:"workspace_id"`
+        Role        string `db:"role"`
+    }
+    query := `SELECT g.workspace_id, m.role FROM landings g
+              JOIN workspace_members m ON m.workspace_id = g.workspace_id AND m.user_id = $2
+              WHERE g.id=$1`
+    err := sqlx.Get(q, &row, query, landingID, userID)
+    if err == sql.ErrNoRows {
+        return 0, "", nil
+    }
+    return row.WorkspaceID, row.Role, err
+}
+
+// linkRole возвращает лендинг ссылки и роль пользователя в его пространстве
+func linkRole(q sqlx.Queryer, linkID, userID int) (int, string, error) {
+    var row struct {
+        LandingID int    `db:"landing_id"`
+        Role      string `db:"role"`
+    }
+    query := `SELECT k.landing_id, m.role FROM links k
+              JOIN landings g ON g.id = k.landing_id
+              JOIN workspace_members m ON m.workspace_id = g.workspace_id AND m.user_id = $2
+              WHERE k.id=$1`
+    err := sqlx.Get(q, &row, query, linkID, userID)
+    if err == sql.ErrNoRows {
+        return 0, "", nil
+    }
+    return row.LandingID, row.Role, err
+}
+
+// checkRole отвечает клиенту, если роли недостаточно. Ресурс чужого
+// пространства неотличим от несуществующего: 404; участнику с ролью
+// ниже нужной — 403.
+func checkRole(c *gin.Context, role, min string) bool {
+    if role == "" {
+        c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+        return false
+    }
+    if !roleAtLeast(role, min) {
+        c.JSON(http.StatusForbidden, gin.H{"error": "insufficient role", "role": role})
+        return false
+    }
+    return true
+}
+
+// authorizeLanding проверяет, что текущий пользователь состоит в пространстве
+// лендинга с ролью не ниже min
+func authorizeLanding(c *gin.Context, db sqlx.Queryer, landingID int, min string) bool {
+    uid, ok := currentUserID(c)
+    if !ok {
+        return false
+    }
+    _, role, err := landingRole(db, landingID, uid)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return false
+    }
+    return checkRole(c, role, min)
+}
+
+// authorizeWorkspace проверяет роль текущего пользователя в пространстве
+func authorizeWorkspace(c *gin.Context, db sqlx.Queryer, workspaceID int, min string) bool {
+    uid, ok := currentUserID(c)
+    if !ok {
+        return false
+    }
+    role, err := workspaceRole(db, workspaceID, uid)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return false
+    }
+    return checkRole(c, role, min)
+}
+
+// requireLandingRole — middleware для маршрутов вида /landings/:id.
+// Пропускает запрос дальше, только если у пользователя есть роль не ниже min
+// в пространстве лендинга.
+func requireLandingRole(db *sqlx.DB, param, min string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        id, err := strconv.Atoi(c.Param(param))
+        if err != nil {
+            c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+            return
+        }
+        uid, ok := currentUserID(c)
+        if !ok {
+            c.Abort()
+            return
+        }
+        workspaceID, role, err := landingRole(db, id, uid)
+        if err != nil {
+            c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if !checkRole(c, role, min) {
+            c.Abort()
+            return
+        }
+        c.Set(ctxLandingID, id)
+        c.Set(ctxWorkspaceID, workspaceID)
+        c.Set(ctxRole, role)
+        c.Next()
+    }
+}
+
+// requireLinkRole — middleware для маршрутов вида /links/:id.
+// Доступ к ссылке определяется через пространство её лендинга.
+func requireLinkRole(db *sqlx.DB, param, min string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        id, err := strconv.Atoi(c.Param(param))
+        if err != nil {
+            c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+            return
+        }
+        uid, ok := currentUserID(c)
+        if !ok {
+            c.Abort()
+            return
+        }
+        landingID, role, err := linkRole(db, id, uid)
+        if err != nil {
+            c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if !checkRole(c, role, min) {
+            c.Abort()
+            return
+        }
+        c.Set(ctxLinkID, id)
+        c.Set(ctxLandingID, landingID)
+        c.Set(ctxRole, role)
+        c.Next()
+    }
+}
+
+// requireWorkspaceRole — middleware для маршрутов вида /workspaces/:id
+func requireWorkspaceRole(db *sqlx.DB, param, min string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        id, err := strconv.Atoi(c.Param(param))
+        if err != nil {
+            c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+            return
+        }
+        if !authorizeWorkspace(c, db, id, min) {
+            c.Abort()
+            return
+        }
+        c.Set(ctxWorkspaceID, id)
+        c.Next()
+    }
+}
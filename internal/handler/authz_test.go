@@ -0,0 +1,123 @@
+package handler
+
+import (
+    "net/http"
+    "regexp"
+    "testing"
+
+    "github.com/DATA-DOG/go-sqlmock"
+    "github.com/gin-gonic/gin"
+)
+
+// В тестах ниже лендинг 1, ссылка 1 и подписка 5 принадлежат пользователю A,
+// а запросы приходят от пользователя B, который либо не состоит в его
+// пространстве, либо состоит там зрителем.
+const foreignUser = 2
+
+// roleLookup — запрос роли, которым маршрут проверяет доступ
+type roleLookup struct {
+    query   string
+    columns []string
+}
+
+var (
+    landingRoleQuery = roleLookup{regexp.QuoteMeta("SELECT g.workspace_id, m.role FROM landings g"), []string{"workspace_id", "role"}}
+    linkRoleQuery    = roleLookup{regexp.QuoteMeta("SELECT k.landing_id, m.role FROM links k"), []string{"landing_id", "role"}}
+)
+
+// crossTenantRouter регистрирует все маршруты, которые проверяются на изоляцию
+func crossTenantRouter(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+    db, mock := newMockDB(t)
+    router := newTestRouter(foreignUser, func(api *gin.RouterGroup) {
+        RegisterLandingRoutes(api, db, nil)
+        RegisterLinkRoutes(api, db)
+        RegisterLeadRoutes(api, db, nil)
+        RegisterAnalyticsRoutes(api, db)
+        RegisterSubscriptionRoutes(api, db, nil)
+    })
+    return router, mock
+}
+
+func TestForeignUserGetsNotFound(t *testing.T) {
+    const link = `{"landingId":1,"type":"url","title":"x","url":"https://example.com"}`
+    tests := []struct {
+        method, target, body string
+        roleQuery            roleLookup
+    }{
+        {http.MethodGet, "/api/landings/1", "", landingRoleQuery},
+        {http.MethodPut, "/api/landings/1", `{"title":"x"}`, landingRoleQuery},
+        {http.MethodDelete, "/api/landings/1", "", landingRoleQuery},
+        {http.MethodPost, "/api/landings/1/duplicate", "", landingRoleQuery},
+        {http.MethodGet, "/api/links?landingId=1", "", landingRoleQuery},
+        {http.MethodPost, "/api/links", link, landingRoleQuery},
+        {http.MethodGet, "/api/links/1", "", linkRoleQuery},
+        {http.MethodPut, "/api/links/1", link, linkRoleQuery},
+        {http.MethodDelete, "/api/links/1", "", linkRoleQuery},
+        {http.MethodPost, "/api/leads", `{"landingId":1,"name":"x"}`, landingRoleQuery},
+        {http.MethodGet, "/api/analytics?landingId=1", "", landingRoleQuery},
+        {http.MethodPost, "/api/analytics", `{"landingId":1,"eventType":"view"}`, landingRoleQuery},
+    }
+    for _, tt := range tests {
+        t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+            router, mock := crossTenantRouter(t)
+            mock.ExpectQuery(tt.roleQuery.query).
+                WithArgs(1, foreignUser).
+                WillReturnRows(sqlmock.NewRows(tt.roleQuery.columns))
+            w := doRequest(router, tt.method, tt.target, tt.body)
+            if w.Code != http.StatusNotFound {
+                t.Errorf("status = %d, want 404, body = %s", w.Code, w.Body)
+            }
+        })
+    }
+}
+
+func TestViewerCannotModify(t *testing.T) {
+    const link = `{"landingId":1,"type":"url","title":"x","url":"https://example.com"}`
+    tests := []struct {
+        method, target, body string
+        roleQuery            roleLookup
+    }{
+        {http.MethodPut, "/api/landings/1", `{"title":"x"}`, landingRoleQuery},
+        {http.MethodDelete, "/api/landings/1", "", landingRoleQuery},
+        {http.MethodPost, "/api/landings/1/duplicate", "", landingRoleQuery},
+        {http.MethodPost, "/api/links", link, landingRoleQuery},
+        {http.MethodPut, "/api/links/1", link, linkRoleQuery},
+        {http.MethodDelete, "/api/links/1", "", linkRoleQuery},
+        {http.MethodPost, "/api/leads", `{"landingId":1,"name":"x"}`, landingRoleQuery},
+        {http.MethodPost, "/api/analytics", `{"landingId":1,"eventType":"view"}`, landingRoleQuery},
+    }
+    for _, tt := range tests {
+        t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+            router, mock := crossTenantRouter(t)
+            mock.ExpectQuery(tt.roleQuery.query).
+                WithArgs(1, foreignUser).
+                WillReturnRows(sqlmock.NewRows(tt.roleQuery.columns).AddRow(1, RoleViewer))
+            w := doRequest(router, tt.method, tt.target, tt.body)
+            if w.Code != http.StatusForbidden {
+                t.Errorf("status = %d, want 403, body = %s", w.Code, w.Body)
+            }
+        })
+    }
+}
+
+func TestForeignUserListsOnlyOwnLeads(t *testing.T) {
+    router, mock := crossTenantRouter(t)
+    mock.ExpectQuery(regexp.QuoteMeta("WHERE m.user_id = $1")).
+        WithArgs(foreignUser).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "landing_id", "name", "email", "phone", "message"}))
+    w := doRequest(router, http.MethodGet, "/api/leads", "")
+    if w.Code != http.StatusOK {
+        t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+    }
+}
+
+func TestForeignUserCannotCancelSubscription(t *testing.T) {
+    router, mock := crossTenantRouter(t)
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM subscriptions WHERE id=$1 AND user_id=$2")).
+        WithArgs("5", foreignUser).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}))
+    w := doRequest(router, http.MethodDelete, "/api/subscriptions/5", "")
+    if w.Code != http.StatusNotFound {
+        t.Errorf("status = %d, want 404, body = %s", w.Code, w.Body)
+    }
+}
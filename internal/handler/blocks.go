@@ -0,0 +1,595 @@
+package handler
+
+import (
+    "bytes"
+    "database/sql/driver"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+    "unicode/utf8"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+)
+
+// Типы блоков лендинга
+const (
+    BlockHeader     = "header"
+    BlockText       = "text"
+    BlockImage      = "image"
+    BlockLinkButton = "link_button"
+    BlockSocialRow  = "social_row"
+    BlockVideo      = "video_embed"
+    BlockDivider    = "divider"
+    BlockLeadForm   = "lead_form"
+    BlockCountdown  = "countdown"
+    BlockMap        = "map"
+)
+
+// maxBlocks — ограничение на число блоков одного лендинга
+const maxBlocks = 100
+
+// Block — элемент содержимого лендинга. Data зависит от Type и проверяется
+// схемой соответствующего типа (см. blockSchemas).
+type Block struct {
+    ID   string          `json:"id"`
+    Type string          `json:"type"`
+    Data json.RawMessage `json:"data"`
+}
+
+// Blocks хранится в landings.blocks как JSONB
+type Blocks []Block
+
+// Value реализует driver.Valuer
+func (b Blocks) Value() (driver.Value, error) {
+    if b == nil {
+        return []byte("[]"), nil
+    }
+    return json.Marshal(b)
+}
+
+// Scan реализует sql.Scanner
+func (b *Blocks) Scan(src interface{}) error {
+    var data []byte
+    switch v := src.(type) {
+    case []byte:
+        data = v
+    case string:
+        data = []byte(v)
+    case nil:
+        *b = Blocks{}
+        return nil
+    default:
+        return fmt.Errorf("blocks: unsupported type %T", src)
+    }
+    return json.Unmarshal(data, b)
+}
+
+type (
+    headerBlock struct {
+        Text  string `json:"text"`
+        Level int    `json:"level"` // 1..3
+        Align string `json:"align"`
+    }
+    textBlock struct {
+        Text  string `json:"text"`
+        Align string `json:"align"`
+    }
+    imageBlock struct {
+        URL  string `json:"url"`
+        Alt  string `json:"alt"`
+        Link string `json:"link"`
+    }
+    linkButtonBlock struct {
+        Title string `json:"title"`
+        URL   string `json:"url"`
+    }
+    socialItem struct {
+        Network string `json:"network"`
+        URL     string `json:"url"`
+    }
+    socialRowBlock struct {
+        Items []socialItem `json:"items"`
+    }
+    videoBlock struct {
+        URL string `json:"url"`
+    }
+    dividerBlock struct {
+        Style string `json:"style"` // line, space, dots
+    }
+    leadFormBlock struct {
+        Title          string   `json:"title"`
+        Fields         []string `json:"fields"` // name, email, phone, message
+        ButtonText     string   `json:"buttonText"`
+        SuccessMessage string   `json:"successMessage"`
+    }
+    countdownBlock struct {
+        Title       string    `json:"title"`
+        EndsAt      time.Time `json:"endsAt"`
+        ExpiredText string    `json:"expiredText"`
+    }
+    mapBlock struct {
+        Address string  `json:"address"`
+        Lat     float64 `json:"lat"`
+        Lng     float64 `json:"lng"`
+        Zoom    int     `json:"zoom"`
+    }
+)
+
+// blockSchema разбирает data блока в типизированную структуру, проверяет её
+// и возвращает с проставленными значениями по умолчанию
+type blockSchema func(data json.RawMessage) (interface{}, fieldErrors)
+
+var blockSchemas = map[string]blockSchema{
+    BlockHeader: func(data json.RawMessage) (interface{}, fieldErrors) {
+        var b headerBlock
+        errs := decodeBlockData(data, &b)
+        requireText(errs, "text", b.Text, 200)
+        if b.Level == 0 {
+            b.Level = 2
+        }
+        if b.Level < 1 || b.Level > 3 {
+            errs["level"] = "must be between 1 and 3"
+        }
+        checkAlign(errs, &b.Align)
+        return b, errs
+    },
+    BlockText: func(data json.RawMessage) (interface{}, fieldErrors) {
+        var b textBlock
+        errs := decodeBlockData(data, &b)
+        requireText(errs, "text", b.Text, 5000)
+        checkAlign(errs, &b.Align)
+        return b, errs
+    },
+    BlockImage: func(data json.RawMessage) (interface{}, fieldErrors) {
+        var b imageBlock
+        errs := decodeBlockData(data, &b)
+        checkURL(errs, "url", b.URL, true)
+        checkURL(errs, "link", b.Link, false)
+        if utf8.RuneCountInString(b.Alt) > 300 {
+            errs["alt"] = "must be at most 300 characters"
+        }
+        return b, errs
+    },
+    BlockLinkButton: func(data json.RawMessage) (interface{}, fieldErrors) {
+        var b linkButtonBlock
+        errs := decodeBlockData(data, &b)
+        requireText(errs, "title", b.Title, 255)
+        checkURL(errs, "url", b.URL, true)
+        return b, errs
+    },
+    BlockSocialRow: func(data json.RawMessage) (interface{}, fieldErrors) {
+        var b socialRowBlock
+        errs := decodeBlockData(data, &b)
+        if len(b.Items) == 0 || len(b.Items) > 12 {
+            errs["items"] = "must contain from 1 to 12 items"
+        }
+        for i, item := range b.Items {
+            if !socialNetworks[item.Network] {
+                errs[fmt.Sprintf("items[%d].network", i)] = "unsupported network"
+            }
+            checkURL(errs, fmt.Sprintf("items[%d].url", i), item.URL, true)
+        }
+        return b, errs
+    },
+    BlockVideo: func(data json.RawMessage) (interface{}, fieldErrors) {
+        var b videoBlock
+        errs := decodeBlockData(data, &b)
+        if _, err := videoEmbedURL(b.URL); err != nil {
+            errs["url"] = err.Error()
+        }
+        return b, errs
+    },
+    BlockDivider: func(data json.RawMessage) (interface{}, fieldErrors) {
+        var b dividerBlock
+        errs := decodeBlockData(data, &b)
+        switch b.Style {
+        case "":
+            b.Style = "line"
+        case "line", "space", "dots":
+        default:
+            errs["style"] = "must be one of line, space, dots"
+        }
+        return b, errs
+    },
+    BlockLeadForm: func(data json.RawMessage) (interface{}, fieldErrors) {
+        var b leadFormBlock
+        errs := decodeBlockData(data, &b)
+        if utf8.RuneCountInString(b.Title) > 200 {
+            errs["title"] = "must be at most 200 characters"
+        }
+        if len(b.Fields) == 0 {
+            errs["fields"] = "at least one field is required"
+        }
+        seen := map[string]bool{}
+        for _, f := range b.Fields {
+            if !leadFormFields[f] || seen[f] {
+                errs["fields"] = "fields must be distinct values of name, email, phone, message"
+            }
+            seen[f] = true
+        }
+        if b.ButtonText == "" {
+            b.ButtonText = "Отправить"
+        }
+        if b.SuccessMessage == "" {
+            b.SuccessMessage = "Спасибо! Мы свяжемся с вами."
+        }
+        return b, errs
+    },
+    BlockCountdown: func(data json.RawMessage) (interface{}, fieldErrors) {
+        var b countdownBlock
+        errs := decodeBlockData(data, &b)
+        if b.EndsAt.IsZero() {
+            errs["endsAt"] = "is required"
+        }
+        if utf8.RuneCountInString(b.Title) > 200 {
+            errs["title"] = "must be at most 200 characters"
+        }
+        return b, errs
+    },
+    BlockMap: func(data json.RawMessage) (interface{}, fieldErrors) {
+        var b mapBlock
+        errs := decodeBlockData(data, &b)
+        if b.Lat < -90 || b.Lat > 90 {
+            errs["lat"] = "must be between -90 and 90"
+        }
+        if b.Lng < -180 || b.Lng > 180 {
+            errs["lng"] = "must be between -180 and 180"
+        }
+        if b.Zoom == 0 {
+            b.Zoom = 15
+        }
+        if b.Zoom < 1 || b.Zoom > 19 {
+            errs["zoom"] = "must be between 1 and 19"
+        }
+        if utf8.RuneCountInString(b.Address) > 300 {
+            errs["address"] = "must be at most 300 characters"
+        }
+        return b, errs
+    },
+}
+
+var socialNetworks = map[string]bool{
+    "telegram": true, "whatsapp": true, "instagram": true, "youtube": true,
+    "tiktok": true, "vk": true, "facebook": true, "x": true,
+    "linkedin": true, "github": true, "email": true, "website": true,
+}
+
+var leadFormFields = map[string]bool{"name": true, "email": true, "phone": true, "message": true}
+
+// decodeBlockData строго разбирает data: неизвестные поля — ошибка
+func decodeBlockData(data json.RawMessage, dst interface{}) fieldErrors {
+    errs := fieldErrors{}
+    if len(data) == 0 {
+        data = json.RawMessage("{}")
+    }
+    dec := json.NewDecoder(bytes.NewReader(data))
+    dec.DisallowUnknownFields()
+    if err := dec.Decode(dst); err != nil {
+        errs["data"] = err.Error()
+    }
+    return errs
+}
+
+func requireText(errs fieldErrors, field, value string, max int) {
+    if strings.TrimSpace(value) == "" {
+        errs[field] = "is required"
+    } else if utf8.RuneCountInString(value) > max {
+        errs[field] = fmt.Sprintf("must be at most %d characters", max)
+    }
+}
+
+func checkAlign(errs fieldErrors, align *string) {
+    switch *align {
+    case "":
+        *align = "center"
+    case "left", "center", "right":
+    default:
+        errs["align"] = "must be one of left, center, right"
+    }
+}
+
+// checkURL проверяет абсолютный http(s)/mailto/tel URL
+func checkURL(errs fieldErrors, field, value string, required bool) {
+    if value == "" {
+        if required {
+            errs[field] = "is required"
+        }
+        return
+    }
+    u, err := url.Parse(value)
+    if err != nil {
+        errs[field] = "must be a valid URL"
+        return
+    }
+    switch u.Scheme {
+    case "http", "https":
+        if u.Host == "" {
+            errs[field] = "must be a valid URL"
+        }
+    case "mailto", "tel", "tg":
+    default:
+        errs[field] = "must be an http(s), mailto, tel or tg URL"
+    }
+}
+
+// videoEmbedURL переводит ссылку на видео в адрес для iframe
+func videoEmbedURL(raw string) (string, error) {
+    u, err := url.Parse(raw)
+    if err != nil || u.Host == "" {
+        return "", errors.New("must be a valid URL")
+    }
+    host := strings.TrimPrefix(u.Host, "www.")
+    switch host {
+    case "youtube.com", "m.youtube.com":
+        if id := u.Query().Get("v"); id != "" {
+            return "https://www.youtube-nocookie.com/embed/" + url.PathEscape(id), nil
+        }
+        if rest, ok := strings.CutPrefix(u.Path, "/shorts/"); ok && rest != "" {
+            return "https://www.youtube-nocookie.com/embed/" + url.PathEscape(rest), nil
+        }
+    case "youtu.be":
+        if id := strings.Trim(u.Path, "/"); id != "" {
+            return "https://www.youtube-nocookie.com/embed/" + url.PathEscape(id), nil
+        }
+    case "vimeo.com":
+        if id := strings.Trim(u.Path, "/"); id != "" && !strings.Contains(id, "/") {
+            return "https://player.vimeo.com/video/" + url.PathEscape(id), nil
+        }
+    case "rutube.ru":
+        if id, ok := strings.CutPrefix(u.Path, "/video/"); ok && strings.Trim(id, "/") != "" {
+            return "https://rutube.ru/play/embed/" + url.PathEscape(strings.Trim(id, "/")), nil
+        }
+    }
+    return "", errors.New("must be a YouTube, Vimeo or Rutube video URL")
+}
+
+// validateBlock проверяет блок по схеме его типа и нормализует data
+func validateBlock(b *Block) fieldErrors {
+    schema, ok := blockSchemas[b.Type]
+    if !ok {
+        return fieldErrors{"type": "unknown block type"}
+    }
+    value, errs := schema(b.Data)
+    if len(errs) > 0 {
+        return errs
+    }
+    normalized, err := json.Marshal(value)
+    if err != nil {
+        return fieldErrors{"data": err.Error()}
+    }
+    b.Data = normalized
+    return nil
+}
+
+// validateBlocks проверяет список блоков; ключи ошибок — "blocks[i].<поле>"
+func validateBlocks(blocks Blocks) fieldErrors {
+    errs := fieldErrors{}
+    if len(blocks) > maxBlocks {
+        errs["blocks"] = fmt.Sprintf("at most %d blocks are allowed", maxBlocks)
+        return errs
+    }
+    seen := map[string]bool{}
+    for i := range blocks {
+        if blocks[i].ID == "" {
+            id, err := newBlockID()
+            if err != nil {
+                errs["blocks"] = err.Error()
+                return errs
+            }
+            blocks[i].ID = id
+        }
+        if seen[blocks[i].ID] {
+            errs[fmt.Sprintf("blocks[%d].id", i)] = "duplicate id"
+        }
+        seen[blocks[i].ID] = true
+        for k, v := range validateBlock(&blocks[i]) {
+            errs[fmt.Sprintf("blocks[%d].%s", i, k)] = v
+        }
+    }
+    if len(errs) > 0 {
+        return errs
+    }
+    return nil
+}
+
+// newBlockID генерирует короткий id блока
+func newBlockID() (string, error) {
+    return randomToken(9)
+}
+
+// errBlockNotFound — блока с таким id нет на лендинге
+var errBlockNotFound = errors.New("block not found")
+
+// registerBlockRoutes регистрирует CRUD блоков внутри /landings/:id
+func registerBlockRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+    read, write := requireScope(ScopeLandingsRead), requireScope(ScopeLandingsWrite)
+    r.GET("/:id/blocks", read, requireLandingRole(db, "id", RoleViewer), listBlocks(db))
+    r.POST("/:id/blocks", write, requireLandingRole(db, "id", RoleEditor), createBlock(db))
+    r.PUT("/:id/blocks/order", write, requireLandingRole(db, "id", RoleEditor), reorderBlocks(db))
+    r.PUT("/:id/blocks/:blockId", write, requireLandingRole(db, "id", RoleEditor), updateBlock(db))
+    r.DELETE("/:id/blocks/:blockId", write, requireLandingRole(db, "id", RoleEditor), deleteBlock(db))
+}
+
+// mutateBlocks меняет блоки лендинга в транзакции под блокировкой строки.
+// fn возвращает новый список или ошибки полей / errBlockNotFound.
+func mutateBlocks(db *sqlx.DB, landingID int, fn func(Blocks) (Blocks, fieldErrors, error)) (Blocks, fieldErrors, error) {
+    tx, err := db.Beginx()
+    if err != nil {
+        return nil, nil, err
+    }
+    defer tx.Rollback()
+
+    var blocks Blocks
+    if err := tx.Get(&blocks, "SELECT blocks FROM landings WHERE id=$1 FOR UPDATE", landingID); err != nil {
+        return nil, nil, err
+    }
+    next, errs, err := fn(blocks)
+    if err != nil || len(errs) > 0 {
+        return nil, errs, err
+    }
+    if len(next) > maxBlocks {
+        return nil, fieldErrors{"blocks": fmt.Sprintf("at most %d blocks are allowed", maxBlocks)}, nil
+    }
+    if _, err := tx.Exec("UPDATE landings SET blocks=$1, updated_at=NOW() WHERE id=$2", next, landingID); err != nil {
+        return nil, nil, err
+    }
+    return next, nil, tx.Commit()
+}
+
+// respondBlocks отвечает результатом mutateBlocks
+func respondBlocks(c *gin.Context, status int, body interface{}, errs fieldErrors, err error) {
+    switch {
+    case err == errBlockNotFound:
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    case err != nil:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    case len(errs) > 0:
+        respondFieldErrors(c, errs)
+    default:
+        c.JSON(status, body)
+    }
+}
+
+func findBlock(blocks Blocks, id string) int {
+    for i := range blocks {
+        if blocks[i].ID == id {
+            return i
+        }
+    }
+    return -1
+}
+
+// listBlocks возвращает блоки лендинга по порядку
+func listBlocks(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var blocks Blocks
+        if err := db.Get(&blocks, "SELECT blocks FROM landings WHERE id=$1", c.GetInt(ctxLandingID)); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusOK, blocks)
+    }
+}
+
+// createBlock добавляет блок; без position — в конец
+func createBlock(db *sqlx.DB) gin.HandlerFunc {
+    type request struct {
+        Type     string          `json:"type" binding:"required"`
+        Data     json.RawMessage `json:"data"`
+        Position *int            `json:"position"`
+    }
+    return func(c *gin.Context) {
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        id, err := newBlockID()
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        block := Block{ID: id, Type: req.Type, Data: req.Data}
+        if errs := validateBlock(&block); errs != nil {
+            respondFieldErrors(c, errs)
+            return
+        }
+
+        _, errs, err := mutateBlocks(db, c.GetInt(ctxLandingID), func(blocks Blocks) (Blocks, fieldErrors, error) {
+            pos := len(blocks)
+            if req.Position != nil {
+                if *req.Position < 0 || *req.Position > len(blocks) {
+                    return nil, fieldErrors{"position": "out of range"}, nil
+                }
+                pos = *req.Position
+            }
+            next := make(Blocks, 0, len(blocks)+1)
+            next = append(next, blocks[:pos]...)
+            next = append(next, block)
+            return append(next, blocks[pos:]...), nil, nil
+        })
+        respondBlocks(c, http.StatusCreated, block, errs, err)
+    }
+}
+
+// updateBlock заменяет data блока (тип блока не меняется)
+func updateBlock(db *sqlx.DB) gin.HandlerFunc {
+    type request struct {
+        Data json.RawMessage `json:"data" binding:"required"`
+    }
+    return func(c *gin.Context) {
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        var updated Block
+        _, errs, err := mutateBlocks(db, c.GetInt(ctxLandingID), func(blocks Blocks) (Blocks, fieldErrors, error) {
+            i := findBlock(blocks, c.Param("blockId"))
+            if i < 0 {
+                return nil, nil, errBlockNotFound
+            }
+            updated = Block{ID: blocks[i].ID, Type: blocks[i].Type, Data: req.Data}
+            if errs := validateBlock(&updated); errs != nil {
+                return nil, errs, nil
+            }
+            blocks[i] = updated
+            return blocks, nil, nil
+        })
+        respondBlocks(c, http.StatusOK, updated, errs, err)
+    }
+}
+
+// deleteBlock удаляет блок
+func deleteBlock(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        _, errs, err := mutateBlocks(db, c.GetInt(ctxLandingID), func(blocks Blocks) (Blocks, fieldErrors, error) {
+            i := findBlock(blocks, c.Param("blockId"))
+            if i < 0 {
+                return nil, nil, errBlockNotFound
+            }
+            return append(blocks[:i], blocks[i+1:]...), nil, nil
+        })
+        if err == nil && len(errs) == 0 {
+            c.Status(http.StatusNoContent)
+            return
+        }
+        respondBlocks(c, http.StatusNoContent, nil, errs, err)
+    }
+}
+
+// reorderBlocks задаёт новый порядок блоков; ids — перестановка текущих id
+func reorderBlocks(db *sqlx.DB) gin.HandlerFunc {
+    type request struct {
+        IDs []string `json:"ids" binding:"required"`
+    }
+    return func(c *gin.Context) {
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        blocks, errs, err := mutateBlocks(db, c.GetInt(ctxLandingID), func(blocks Blocks) (Blocks, fieldErrors, error) {
+            if len(req.IDs) != len(blocks) {
+                return nil, fieldErrors{"ids": "must list every block exactly once"}, nil
+            }
+            next := make(Blocks, 0, len(blocks))
+            used := map[string]bool{}
+            for _, id := range req.IDs {
+                i := findBlock(blocks, id)
+                if i < 0 || used[id] {
+                    return nil, fieldErrors{"ids": "must list every block exactly once"}, nil
+                }
+                used[id] = true
+                next = append(next, blocks[i])
+            }
+            return next, nil, nil
+        })
+        respondBlocks(c, http.StatusOK, blocks, errs, err)
+    }
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+    "encoding/json"
+    "strings"
+    "testing"
+)
+
+func TestValidateBlockCountsCharacters(t *testing.T) {
+    // 200 символов кириллицы — 400 байт, но в лимит заголовка укладываются
+    text := strings.Repeat("я", 200)
+    data, _ := json.Marshal(map[string]string{"text": text})
+    if errs := validateBlock(&Block{Type: BlockHeader, Data: data}); len(errs) > 0 {
+        t.Errorf("200 characters rejected: %v", errs)
+    }
+
+    data, _ = json.Marshal(map[string]string{"text": text + "я"})
+    if errs := validateBlock(&Block{Type: BlockHeader, Data: data}); errs["text"] == "" {
+        t.Errorf("201 characters accepted: %v", errs)
+    }
+}
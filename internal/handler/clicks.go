@@ -0,0 +1,369 @@
+package handler
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+    "github.com/lib/pq"
+)
+
+// clickEvent — клик по ссылке, ожидающий записи в analytics
+type clickEvent struct {
+    LandingID   int
+    LinkID      int // 0 — ссылки уже нет в черновике
+    Country     string
+    IPAddress   string
+    UserAgent   string
+    Referrer    string
+    UTMSource   string
+    UTMMedium   string
+    UTMCampaign string
+    UTMTerm     string
+    UTMContent  string
+    CreatedAt   time.Time
+    Counted     bool // click_count уже увеличен при редиректе (ссылки с лимитом)
+}
+
+// Ограничения очереди кликов
+const (
+    clickQueueSize = 10000 // сколько кликов ждут записи, остальные отбрасываются
+    clickBatchSize = 500   // сколько кликов пишется одним INSERT
+)
+
+// ClickRecorder копит клики в памяти и пишет их в analytics пачками, чтобы
+// редирект не ждал Postgres. При переполнении очереди клики теряются
+// (это статистика, а не деньги) — потери видны в логе.
+type ClickRecorder struct {
+    db      *sqlx.DB
+    queue   chan clickEvent
+    dropped atomic.Int64
+}
+
+// NewClickRecorder создаёт очередь кликов; писать её начинает Run
+func NewClickRecorder(db *sqlx.DB) *ClickRecorder {
+    return &ClickRecorder{db: db, queue: make(chan clickEvent, clickQueueSize)}
+}
+
+// record ставит клик в очередь, не блокируясь
+func (r *ClickRecorder) record(evt clickEvent) {
+    select {
+    case r.queue <- evt:
+    default:
+        r.dropped.Add(1)
+    }
+}
+
+// Run пишет накопленные клики раз в interval или по заполнении пачки.
+// После отмены ctx дописывает очередь и возвращается — вызывать стоит
+// после остановки HTTP-сервера, чтобы не потерять последние клики.
+func (r *ClickRecorder) Run(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    batch := make([]clickEvent, 0, clickBatchSize)
+    for {
+        select {
+        case evt := <-r.queue:
+            batch = append(batch, evt)
+            if len(batch) >= clickBatchSize {
+                batch = r.flush(batch)
+            }
+        case <-ticker.C:
+            batch = r.flush(batch)
+        case <-ctx.Done():
+            for {
+                select {
+                case evt := <-r.queue:
+                    batch = append(batch, evt)
+                    if len(batch) >= clickBatchSize {
+                        batch = r.flush(batch)
+                    }
+                default:
+                    r.flush(batch)
+                    return
+                }
+            }
+        }
+    }
+}
+
+// flush пишет пачку одной транзакцией и возвращает её обнулённой для переиспользования
+func (r *ClickRecorder) flush(batch []clickEvent) []clickEvent {
+    if n := r.dropped.Swap(0); n > 0 {
+        log.Printf("click recorder: queue full, dropped %d clicks", n)
+    }
+    if len(batch) == 0 {
+        return batch
+    }
+    // клики пишутся через SELECT с JOIN: пока они ждали в очереди, лендинг
+    // или ссылку могли удалить, и одно нарушение внешнего ключа не должно
+    // терять всю пачку. Клики удалённых лендингов отбрасываются, у удалённых
+    // ссылок link_id становится NULL.
+    const columns = 12
+    var sb strings.Builder
+    sb.WriteString(`INSERT INTO analytics (landing_id, event_type, link_id, geo_country, ip_address, user_agent, referrer,
+        utm_source, utm_medium, utm_campaign, utm_term, utm_content, created_at)
+        SELECT v.landing_id, 'click', k.id, NULLIF(v.country, ''), NULLIF(v.ip, '')::inet, v.user_agent, v.referrer,
+            v.utm_source, v.utm_medium, v.utm_campaign, v.utm_term, v.utm_content, v.created_at
+        FROM (VALUES `)
+    args := make([]interface{}, 0, len(batch)*columns)
+    for i, e := range batch {
+        if i > 0 {
+            sb.WriteString(",")
+        }
+        n := i * columns
+        fmt.Fprintf(&sb, "($%d::int,$%d::int,$%d::text,$%d::text,$%d::text,$%d::text,$%d::text,$%d::text,$%d::text,$%d::text,$%d::text,$%d::timestamptz)",
+            n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12)
+        args = append(args, e.LandingID, e.LinkID, e.Country, e.IPAddress, e.UserAgent, e.Referrer,
+            e.UTMSource, e.UTMMedium, e.UTMCampaign, e.UTMTerm, e.UTMContent, e.CreatedAt)
+    }
+    sb.WriteString(`) AS v(landing_id, link_id, country, ip, user_agent, referrer,
+            utm_source, utm_medium, utm_campaign, utm_term, utm_content, created_at)
+        JOIN landings l ON l.id = v.landing_id
+        LEFT JOIN links k ON k.id = v.link_id AND k.landing_id = v.landing_id`)
+
+    counts := map[int]int64{}
+    for _, e := range batch {
+        if !e.Counted && e.LinkID != 0 {
+            counts[e.LinkID]++
+        }
+    }
+    // события и счётчики пишутся в одной транзакции, чтобы не расходиться
+    if err := r.write(sb.String(), args, counts); err != nil {
+        log.Printf("click recorder: failed to write %d clicks: %v", len(batch), err)
+    }
+    return batch[:0]
+}
+
+// write вставляет пачку событий и увеличивает click_count ссылок
+func (r *ClickRecorder) write(insert string, args []interface{}, counts map[int]int64) error {
+    tx, err := r.db.Beginx()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+    if _, err := tx.Exec(insert, args...); err != nil {
+        return err
+    }
+    if len(counts) > 0 {
+        ids, ns := make([]int64, 0, len(counts)), make([]int64, 0, len(counts))
+        for id, n := range counts {
+            ids, ns = append(ids, int64(id)), append(ns, n)
+        }
+        query := `UPDATE links SET click_count = links.click_count + v.n
+                  FROM (SELECT unnest($1::int[]) AS id, unnest($2::int[]) AS n) v
+                  WHERE links.id = v.id`
+        if _, err := tx.Exec(query, pq.Int64Array(ids), pq.Int64Array(ns)); err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+// redirectLink записывает клик и перенаправляет на адрес ссылки из
+// опубликованной ревизии. UTM-метки берутся из query (/r/5?utm_source=tg).
+// Адрес выбирается правилами ссылки (см. resolveLinkURL). Ссылка вне окна
+// показа не открывается; после max_clicks ведёт на fallback_url, а без него
+// тоже не открывается.
+func redirectLink(db *sqlx.DB, clicks *ClickRecorder) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        linkID, err := strconv.Atoi(c.Param("linkId"))
+        if err != nil {
+            c.String(http.StatusNotFound, "not found")
+            return
+        }
+        link, inDraft, err := publishedLink(db, linkID)
+        if err == sql.ErrNoRows {
+            c.String(http.StatusNotFound, "not found")
+            return
+        }
+        if err != nil {
+            log.Printf("redirect link %d error: %v", linkID, err)
+            c.String(http.StatusInternalServerError, "internal error")
+            return
+        }
+
+        v := visitorFromRequest(c.Request)
+        target, _ := resolveLinkURL(link, v)
+        counted := false
+        switch linkState(link, time.Now()) {
+        case LinkUpcoming:
+            c.String(http.StatusNotFound, "not found")
+            return
+        case LinkExpired:
+            c.String(http.StatusGone, "link has expired")
+            return
+        }
+        if link.MaxClicks != nil {
+            // лимит проверяется и списывается атомарно, иначе при наплыве
+            // посетителей (раздача, распродажа) кликов будет больше лимита.
+            // Без строки в черновике счётчика нет — считаем лимит исчерпанным.
+            var n int
+            query := `UPDATE links SET click_count = click_count + 1
+                      WHERE id=$1 AND click_count < $2 RETURNING click_count`
+            err := sql.ErrNoRows
+            if inDraft {
+                err = db.Get(&n, query, link.ID, *link.MaxClicks)
+            }
+            if err != nil && err != sql.ErrNoRows {
+                log.Printf("redirect link %d click count error: %v", linkID, err)
+                c.String(http.StatusInternalServerError, "internal error")
+                return
+            }
+            if err == sql.ErrNoRows {
+                if link.FallbackURL == "" {
+                    c.String(http.StatusGone, "link is no longer available")
+                    return
+                }
+                target = link.FallbackURL
+            }
+            counted = true
+        }
+
+        if clicks != nil {
+            // клик по удалённой из черновика ссылке пишется без link_id:
+            // analytics ссылается на links
+            linkRef := link.ID
+            if !inDraft {
+                linkRef = 0
+            }
+            clicks.record(clickEvent{
+                LandingID:   link.LandingID,
+                LinkID:      linkRef,
+                Country:     v.Country,
+                IPAddress:   c.ClientIP(),
+                UserAgent:   truncate(c.Request.UserAgent(), 1000),
+                Referrer:    truncate(c.Request.Referer(), 2000),
+                UTMSource:   truncate(c.Query("utm_source"), 255),
+                UTMMedium:   truncate(c.Query("utm_medium"), 255),
+                UTMCampaign: truncate(c.Query("utm_campaign"), 255),
+                UTMTerm:     truncate(c.Query("utm_term"), 255),
+                UTMContent:  truncate(c.Query("utm_content"), 255),
+                CreatedAt:   time.Now(),
+                Counted:     counted,
+            })
+        }
+        c.Header("Cache-Control", "no-store")
+        c.Header("Referrer-Policy", "no-referrer-when-downgrade")
+        c.Redirect(http.StatusFound, target)
+    }
+}
+
+// publishedLinkCache хранит ссылки, найденные в опубликованных ревизиях.
+// Ревизия неизменна, поэтому запись верна, пока у лендинга тот же
+// published_revision_id: на повторный клик хватает чтения строки лендинга
+// по первичному ключу вместо поиска по GIN-индексу и разбора всего снимка.
+type publishedLinkCache struct {
+    mu      sync.Mutex
+    entries map[int]publishedLinkEntry
+}
+
+type publishedLinkEntry struct {
+    RevisionID int
+    Link       Link
+}
+
+// publishedLinkCacheSize ограничивает память кэша; при переполнении он
+// очищается целиком — горячие ссылки быстро вернутся
+const publishedLinkCacheSize = 10000
+
+var publishedLinks = &publishedLinkCache{entries: map[int]publishedLinkEntry{}}
+
+func (c *publishedLinkCache) get(linkID int) (publishedLinkEntry, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    e, ok := c.entries[linkID]
+    return e, ok
+}
+
+func (c *publishedLinkCache) put(linkID int, e publishedLinkEntry) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if len(c.entries) >= publishedLinkCacheSize {
+        c.entries = map[int]publishedLinkEntry{}
+    }
+    c.entries[linkID] = e
+}
+
+// publishedLink ищет ссылку в опубликованной ревизии лендинга: посетитель
+// попадает туда же, куда ведёт кнопка на странице, даже если черновик изменён
+// или ссылка из него уже удалена. inDraft сообщает, что строка links ещё
+// есть — только тогда у ссылки есть счётчик кликов.
+func publishedLink(db *sqlx.DB, linkID int) (link Link, inDraft bool, err error) {
+    var row struct {
+        RevisionID  *int       `db:"revision_id"`
+        UnpublishAt *time.Time `db:"unpublish_at"`
+        ClickCount  *int       `db:"click_count"`
+    }
+    if cached, ok := publishedLinks.get(linkID); ok {
+        query := `SELECT l.published_revision_id AS revision_id, l.unpublish_at, k.click_count FROM landings l
+                  LEFT JOIN links k ON k.id = $2 AND k.landing_id = l.id
+                  WHERE l.id = $1`
+        err := db.Get(&row, query, cached.Link.LandingID, linkID)
+        if err != nil && err != sql.ErrNoRows {
+            return Link{}, false, err
+        }
+        if err == nil && row.RevisionID != nil && *row.RevisionID == cached.RevisionID {
+            return finishPublishedLink(cached.Link, row.UnpublishAt, row.ClickCount)
+        }
+        // лендинг переопубликован или удалён — ищем заново
+    }
+
+    var full struct {
+        LandingID   int             `db:"landing_id"`
+        RevisionID  int             `db:"revision_id"`
+        UnpublishAt *time.Time      `db:"unpublish_at"`
+        Snapshot    LandingSnapshot `db:"snapshot"`
+        ClickCount  *int            `db:"click_count"`
+    }
+    query := `SELECT l.id AS landing_id, r.id AS revision_id, l.unpublish_at, r.snapshot, k.click_count FROM landings l
+              JOIN landing_revisions r ON r.id = l.published_revision_id
+              LEFT JOIN links k ON k.id = $1 AND k.landing_id = l.id
+              WHERE r.snapshot->'links' @> jsonb_build_array(jsonb_build_object('id', $1::int))`
+    if err := db.Get(&full, query, linkID); err != nil {
+        return Link{}, false, err
+    }
+    for _, l := range full.Snapshot.Links {
+        if l.ID == linkID {
+            l.LandingID = full.LandingID
+            publishedLinks.put(linkID, publishedLinkEntry{RevisionID: full.RevisionID, Link: l})
+            return finishPublishedLink(l, full.UnpublishAt, full.ClickCount)
+        }
+    }
+    return Link{}, false, sql.ErrNoRows
+}
+
+// finishPublishedLink проверяет снятие с публикации и подставляет счётчик из черновика
+func finishPublishedLink(l Link, unpublishAt *time.Time, clickCount *int) (Link, bool, error) {
+    if unpublishAt != nil && !unpublishAt.After(time.Now()) {
+        return Link{}, false, sql.ErrNoRows
+    }
+    if clickCount != nil {
+        l.ClickCount = *clickCount
+    }
+    return l, clickCount != nil, nil
+}
+
+// truncate обрезает строку до n символов, не разрывая символ UTF-8
+func truncate(s string, n int) string {
+    if len(s) <= n {
+        return s
+    }
+    count := 0
+    for i := range s {
+        if count == n {
+            return s[:i]
+        }
+        count++
+    }
+    return s
+}
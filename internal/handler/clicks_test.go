@@ -0,0 +1,136 @@
+package handler
+
+import (
+    "database/sql"
+    "errors"
+    "net/http"
+    "regexp"
+    "testing"
+    "time"
+
+    "github.com/DATA-DOG/go-sqlmock"
+    "github.com/gin-gonic/gin"
+)
+
+const publishedLinkQuery = "WHERE r.snapshot->'links' @>"
+
+var publishedLinkColumns = []string{"landing_id", "revision_id", "unpublish_at", "snapshot", "click_count"}
+
+// resetPublishedLinks очищает кэш ссылок, чтобы тесты не видели чужих записей
+func resetPublishedLinks(t *testing.T) {
+    t.Helper()
+    publishedLinks = &publishedLinkCache{entries: map[int]publishedLinkEntry{}}
+    t.Cleanup(func() { publishedLinks = &publishedLinkCache{entries: map[int]publishedLinkEntry{}} })
+}
+
+func TestRedirectLinkDeletedFromDraft(t *testing.T) {
+    resetPublishedLinks(t)
+    db, mock := newMockDB(t)
+    snapshot := `{"title":"A","links":[{"id":9,"type":"url","title":"Site","url":"https://example.com/"}]}`
+    // строки links уже нет: LEFT JOIN отдаёт NULL в click_count
+    mock.ExpectQuery(regexp.QuoteMeta(publishedLinkQuery)).
+        WithArgs(9).
+        WillReturnRows(sqlmock.NewRows(publishedLinkColumns).
+            AddRow(1, 3, nil, []byte(snapshot), nil))
+
+    gin.SetMode(gin.TestMode)
+    router := gin.New()
+    RegisterPublicRoutes(&router.RouterGroup, db, nil, nil)
+    w := doRequest(router, http.MethodGet, "/r/9", "")
+    if w.Code != http.StatusFound {
+        t.Fatalf("status = %d, want 302, body = %s", w.Code, w.Body)
+    }
+    if loc := w.Header().Get("Location"); loc != "https://example.com/" {
+        t.Errorf("Location = %q", loc)
+    }
+}
+
+func TestPublishedLinkCache(t *testing.T) {
+    resetPublishedLinks(t)
+    db, mock := newMockDB(t)
+    snapshot := func(url string) []byte {
+        return []byte(`{"title":"A","links":[{"id":9,"type":"url","title":"Site","url":"` + url + `"}]}`)
+    }
+    revisionColumns := []string{"revision_id", "unpublish_at", "click_count"}
+
+    // первый клик ищет ссылку по снимку
+    mock.ExpectQuery(regexp.QuoteMeta(publishedLinkQuery)).
+        WithArgs(9).
+        WillReturnRows(sqlmock.NewRows(publishedLinkColumns).
+            AddRow(1, 3, nil, snapshot("https://a.example/"), 5))
+    // второй — только сверяет ревизию лендинга
+    mock.ExpectQuery(regexp.QuoteMeta("WHERE l.id = $1")).
+        WithArgs(1, 9).
+        WillReturnRows(sqlmock.NewRows(revisionColumns).AddRow(3, nil, 6))
+    // после переопубликации кэш устаревает
+    mock.ExpectQuery(regexp.QuoteMeta("WHERE l.id = $1")).
+        WithArgs(1, 9).
+        WillReturnRows(sqlmock.NewRows(revisionColumns).AddRow(4, nil, 6))
+    mock.ExpectQuery(regexp.QuoteMeta(publishedLinkQuery)).
+        WithArgs(9).
+        WillReturnRows(sqlmock.NewRows(publishedLinkColumns).
+            AddRow(1, 4, nil, snapshot("https://b.example/"), 6))
+
+    want := []struct {
+        url        string
+        clickCount int
+    }{
+        {"https://a.example/", 5},
+        {"https://a.example/", 6},
+        {"https://b.example/", 6},
+    }
+    for i, w := range want {
+        link, inDraft, err := publishedLink(db, 9)
+        if err != nil {
+            t.Fatalf("call %d: %v", i, err)
+        }
+        if link.URL != w.url || link.ClickCount != w.clickCount || link.LandingID != 1 || !inDraft {
+            t.Errorf("call %d: link = %+v, inDraft = %v", i, link, inDraft)
+        }
+    }
+}
+
+func TestPublishedLinkCacheUnpublished(t *testing.T) {
+    resetPublishedLinks(t)
+    db, mock := newMockDB(t)
+    publishedLinks.put(9, publishedLinkEntry{RevisionID: 3, Link: Link{ID: 9, LandingID: 1, URL: "https://a.example/"}})
+
+    // ревизия та же, но лендинг уже снят с публикации
+    mock.ExpectQuery(regexp.QuoteMeta("WHERE l.id = $1")).
+        WithArgs(1, 9).
+        WillReturnRows(sqlmock.NewRows([]string{"revision_id", "unpublish_at", "click_count"}).
+            AddRow(3, time.Now().Add(-time.Minute), nil))
+    if _, _, err := publishedLink(db, 9); err != sql.ErrNoRows {
+        t.Errorf("err = %v, want sql.ErrNoRows", err)
+    }
+}
+
+func TestFlushSkipsCountsWhenInsertFails(t *testing.T) {
+    db, mock := newMockDB(t)
+    // счётчик не обновляется, если события не записались
+    mock.ExpectBegin()
+    mock.ExpectExec(regexp.QuoteMeta("INSERT INTO analytics")).
+        WillReturnError(errors.New("connection reset"))
+    mock.ExpectRollback()
+
+    batch := NewClickRecorder(db).flush([]clickEvent{{LandingID: 1, LinkID: 7, CreatedAt: time.Now()}})
+    if len(batch) != 0 {
+        t.Errorf("batch len = %d, want 0", len(batch))
+    }
+}
+
+func TestFlushSkipsDeletedLandings(t *testing.T) {
+    db, mock := newMockDB(t)
+    // строки удалённых лендингов и ссылок отсекаются JOIN, а не внешним ключом
+    mock.ExpectBegin()
+    mock.ExpectExec(regexp.QuoteMeta("JOIN landings l ON l.id = v.landing_id")).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectExec(regexp.QuoteMeta("UPDATE links SET click_count")).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectCommit()
+
+    NewClickRecorder(db).flush([]clickEvent{
+        {LandingID: 1, LinkID: 7, CreatedAt: time.Now()},
+        {LandingID: 2, LinkID: 8, CreatedAt: time.Now()},
+    })
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "strings"
+    "testing"
+
+    "github.com/DATA-DOG/go-sqlmock"
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+)
+
+// newMockDB возвращает sqlx.DB поверх sqlmock. Запросы сравниваются как
+// регулярные выражения, невыполненные ожидания проваливают тест.
+func newMockDB(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock) {
+    t.Helper()
+    mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() {
+        if err := mock.ExpectationsWereMet(); err != nil {
+            t.Error(err)
+        }
+        mockDB.Close()
+    })
+    return sqlx.NewDb(mockDB, "postgres"), mock
+}
+
+// newTestRouter собирает движок с группой /api, все запросы в которой
+// выполняются от имени userID — так же, как после AuthMiddleware
+func newTestRouter(userID int, register func(api *gin.RouterGroup)) *gin.Engine {
+    gin.SetMode(gin.TestMode)
+    router := gin.New()
+    api := router.Group("/api", func(c *gin.Context) {
+        c.Set("user_id", strconv.Itoa(userID))
+        c.Next()
+    })
+    register(api)
+    return router
+}
+
+// doRequest выполняет запрос к router и возвращает записанный ответ
+func doRequest(router http.Handler, method, target, body string) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(method, target, strings.NewReader(body))
+    if body != "" {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, req)
+    return w
+}
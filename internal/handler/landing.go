@@ -8,31 +8,53 @@ import (
 
     "github.com/gin-gonic/gin"
     "github.com/jmoiron/sqlx"
-    "github.com/blagoweb/bbtg/internal/storage/r2"
+    "github.com/blagoweb/bbtg/internal/storage"
 )
 
-// Landing представляет лендинг-страницу пользователя
+// Landing представляет лендинг-страницу пользователя. Поля содержимого — это
+// черновик; посетители видят снимок из опубликованной ревизии.
 type Landing struct {
     ID          int       `db:"id" json:"id"`
-    UserID      int       `db:"user_id" json:"userId"`
+    UserID      int       `db:"user_id" json:"userId"`           // автор лендинга
+    WorkspaceID int       `db:"workspace_id" json:"workspaceId"`
+    Slug        string    `db:"slug" json:"slug"`
     Title       string    `db:"title" json:"title"`
     Description string    `db:"description" json:"description"`
     AvatarURL   string    `db:"avatar_url" json:"avatarUrl"`
+    Blocks      Blocks    `db:"blocks" json:"blocks"`
+    Theme       Theme     `db:"theme" json:"theme"`
     CreatedAt   time.Time `db:"created_at" json:"createdAt"`
     UpdatedAt   time.Time `db:"updated_at" json:"updatedAt"`
+
+    PublishedRevisionID *int       `db:"published_revision_id" json:"publishedRevisionId"` // nil — не опубликован
+    PublishedAt         *time.Time `db:"published_at" json:"publishedAt"`
+    PublishAt           *time.Time `db:"publish_at" json:"publishAt"`     // запланированная публикация
+    UnpublishAt         *time.Time `db:"unpublish_at" json:"unpublishAt"` // запланированное снятие
 }
 
 // RegisterLandingRoutes регистрирует CRUD-эндпоинты для лендингов
-func RegisterLandingRoutes(rg *gin.RouterGroup, db *sqlx.DB, _ *r2.Client) {
+func RegisterLandingRoutes(rg *gin.RouterGroup, db *sqlx.DB, store storage.Backend) {
     r := rg.Group("/landings")
-    r.GET("", listLandings(db))
-    r.POST("", createLanding(db))
-    r.GET(":id", getLanding(db))
-    r.PUT(":id", updateLanding(db))
-    r.DELETE(":id", deleteLanding(db))
+    read, write := requireScope(ScopeLandingsRead), requireScope(ScopeLandingsWrite)
+    r.GET("", read, listLandings(db))
+    r.POST("", write, createLanding(db))
+    r.GET("/slug-check", read, checkSlugAvailability(db))
+    r.GET(":id", read, requireLandingRole(db, "id", RoleViewer), getLanding(db))
+    r.PUT(":id", write, requireLandingRole(db, "id", RoleEditor), updateLanding(db))
+    r.DELETE(":id", write, requireLandingRole(db, "id", RoleEditor), deleteLanding(db))
+    r.POST(":id/transfer", write, requireSession(), requireLandingRole(db, "id", RoleOwner), transferLanding(db))
+    r.POST(":id/duplicate", write, requireLandingRole(db, "id", RoleEditor), duplicateLanding(db))
+    r.GET(":id/preview", read, requireLandingRole(db, "id", RoleViewer), previewLanding(db))
+    registerBlockRoutes(r, db)
+    registerRevisionRoutes(r, db)
+    registerThemeRoutes(r, db)
+    registerArchiveRoutes(r, db, store)
+    registerAvatarRoutes(r, db, store)
+    rg.GET("/themes/presets", read, listThemePresets)
 }
 
-// listLandings возвращает все лендинги пользователя
+// listLandings возвращает лендинги всех пространств пользователя
+// (или одного, если передан ?workspaceId=)
 func listLandings(db *sqlx.DB) gin.HandlerFunc {
     return func(c *gin.Context) {
         uidI, exists := c.Get("user_id")
@@ -46,8 +68,20 @@ func listLandings(db *sqlx.DB) gin.HandlerFunc {
             return
         }
 
+        workspaceID := 0
+        if raw := c.Query("workspaceId"); raw != "" {
+            if workspaceID, err = strconv.Atoi(raw); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workspaceId"})
+                return
+            }
+        }
+
         var items []Landing
-        if err := db.Select(&items, "SELECT * FROM landings WHERE user_id=$1 ORDER BY created_at DESC", uid); err != nil {
+        query := `SELECT g.* FROM landings g
+                  JOIN workspace_members m ON m.workspace_id = g.workspace_id
+                  WHERE m.user_id=$1 AND ($2=0 OR g.workspace_id=$2)
+                  ORDER BY g.created_at DESC`
+        if err := db.Select(&items, query, uid, workspaceID); err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
@@ -59,8 +93,12 @@ func listLandings(db *sqlx.DB) gin.HandlerFunc {
 func createLanding(db *sqlx.DB) gin.HandlerFunc {
     type request struct {
         Title       string `json:"title" binding:"required"`
+        Slug        string `json:"slug"`
         Description string `json:"description"`
         AvatarURL   string `json:"avatarUrl"`
+        WorkspaceID int    `json:"workspaceId"`
+        Blocks      Blocks `json:"blocks"`
+        Theme       Theme  `json:"theme"`
     }
     return func(c *gin.Context) {
         uidI, exists := c.Get("user_id")
@@ -80,10 +118,51 @@ func createLanding(db *sqlx.DB) gin.HandlerFunc {
             return
         }
 
+        if errs := validateBlocks(req.Blocks); errs != nil {
+            respondFieldErrors(c, errs)
+            return
+        }
+        if errs := validateTheme(req.Theme); errs != nil {
+            prefixed := fieldErrors{}
+            for k, v := range errs {
+                prefixed["theme."+k] = v
+            }
+            respondFieldErrors(c, prefixed)
+            return
+        }
+
+        // без workspaceId лендинг создаётся в личном пространстве
+        workspaceID := req.WorkspaceID
+        if workspaceID == 0 {
+            if workspaceID, err = personalWorkspaceID(db, uid); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                return
+            }
+        }
+        if !authorizeWorkspace(c, db, workspaceID, RoleEditor) {
+            return
+        }
+
+        // slug необязателен: без него подбираем случайный адрес
+        var slug string
+        if req.Slug != "" {
+            slug, err = checkSlug(db, req.Slug, 0)
+        } else {
+            slug, err = generateSlug(db)
+        }
+        if err != nil {
+            respondSlugError(c, err)
+            return
+        }
+
         var item Landing
-        query := `INSERT INTO landings(user_id, title, description, avatar_url, created_at, updated_at)
-                  VALUES($1,$2,$3,$4,NOW(),NOW()) RETURNING *`
-        if err := db.Get(&item, query, uid, req.Title, req.Description, req.AvatarURL); err != nil {
+        query := `INSERT INTO landings(user_id, workspace_id, slug, title, description, avatar_url, blocks, theme, created_at, updated_at)
+                  VALUES($1,$2,$3,$4,$5,$6,$7,$8,NOW(),NOW()) RETURNING *`
+        if err := db.Get(&item, query, uid, workspaceID, slug, req.Title, req.Description, req.AvatarURL, req.Blocks, req.Theme); err != nil {
+            if isUniqueViolation(err) {
+                respondSlugError(c, errSlugTaken)
+                return
+            }
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
@@ -114,6 +193,7 @@ func getLanding(db *sqlx.DB) gin.HandlerFunc {
 func updateLanding(db *sqlx.DB) gin.HandlerFunc {
     type request struct {
         Title       string `json:"title" binding:"required"`
+        Slug        string `json:"slug"`
         Description string `json:"description"`
         AvatarURL   string `json:"avatarUrl"`
     }
@@ -131,9 +211,45 @@ func updateLanding(db *sqlx.DB) gin.HandlerFunc {
             return
         }
 
-        query := `UPDATE landings SET title=$1, description=$2, avatar_url=$3, updated_at=NOW() WHERE id=$4 RETURNING *`
+        tx, err := db.Beginx()
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        defer tx.Rollback()
+
+        var current Landing
+        if err := tx.Get(&current, "SELECT * FROM landings WHERE id=$1 FOR UPDATE", id); err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+
+        // пустой slug в запросе означает «оставить прежний»
+        slug := current.Slug
+        if req.Slug != "" {
+            if slug, err = checkSlug(tx, req.Slug, id); err != nil {
+                respondSlugError(c, err)
+                return
+            }
+        }
+        if slug != normalizeSlug(current.Slug) {
+            if err := renameSlug(tx, id, current.Slug, slug); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                return
+            }
+        }
+
+        query := `UPDATE landings SET slug=$1, title=$2, description=$3, avatar_url=$4, updated_at=NOW() WHERE id=$5 RETURNING *`
         var item Landing
-        if err := db.Get(&item, query, req.Title, req.Description, req.AvatarURL, id); err != nil {
+        if err := tx.Get(&item, query, slug, req.Title, req.Description, req.AvatarURL, id); err != nil {
+            if isUniqueViolation(err) {
+                respondSlugError(c, errSlugTaken)
+                return
+            }
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if err := tx.Commit(); err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
@@ -157,4 +273,30 @@ func deleteLanding(db *sqlx.DB) gin.HandlerFunc {
         }
         c.Status(http.StatusNoContent)
     }
-}
\ No newline at end of file
+}
+
+// transferLanding переносит лендинг в другое пространство. Нужны права
+// владельца в исходном пространстве и редактора — в целевом.
+func transferLanding(db *sqlx.DB) gin.HandlerFunc {
+    type request struct {
+        WorkspaceID int `json:"workspaceId" binding:"required"`
+    }
+    return func(c *gin.Context) {
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        if !authorizeWorkspace(c, db, req.WorkspaceID, RoleEditor) {
+            return
+        }
+
+        var item Landing
+        query := `UPDATE landings SET workspace_id=$1, updated_at=NOW() WHERE id=$2 RETURNING *`
+        if err := db.Get(&item, query, req.WorkspaceID, c.GetInt(ctxLandingID)); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusOK, item)
+    }
+}
@@ -23,8 +23,8 @@ type Lead struct {
 // RegisterLeadRoutes регистрирует маршруты для работы с лидами
 func RegisterLeadRoutes(rg *gin.RouterGroup, db *sqlx.DB, bot *telegram.Bot) {
     r := rg.Group("/leads")
-    r.GET("", listLeads(db))
-    r.POST("", createLead(db, bot))
+    r.GET("", requireScope(ScopeLeadsRead), listLeads(db))
+    r.POST("", requireScope(ScopeLeadsWrite), createLead(db, bot))
 }
 
 // listLeads возвращает все лиды всех лендингов текущего пользователя
@@ -41,10 +41,11 @@ func listLeads(db *sqlx.DB) gin.HandlerFunc {
             c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user_id"})
             return
         }
-        // выбираем лиды по всем лендингам пользователя
+        // выбираем лиды по всем лендингам пространств пользователя
         query := `SELECT l.* FROM leads l
                   JOIN landings g ON g.id = l.landing_id
-                  WHERE g.user_id = $1
+                  JOIN workspace_members m ON m.workspace_id = g.workspace_id
+                  WHERE m.user_id = $1
                   ORDER BY l.created_at DESC`
         var items []Lead
         if err := db.Select(&items, query, uid); err != nil {
@@ -70,23 +71,44 @@ func createLead(db *sqlx.DB, bot *telegram.Bot) gin.HandlerFunc {
             c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
             return
         }
-        // сохраняем в БД
-        var lead Lead
-        sql := `INSERT INTO leads (landing_id, name, email, phone, message)
-                VALUES ($1,$2,$3,$4,$5)
-                RETURNING id, landing_id, name, email, phone, message`
-        if err := db.Get(&lead, sql,
-            req.LandingID, req.Name, req.Email, req.Phone, req.Message); err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        if !authorizeLanding(c, db, req.LandingID, RoleEditor) {
             return
         }
-        // отправляем уведомление ботом
-        text := fmt.Sprintf("Новая заявка:\nЛендинг: %d\nИмя: %s\nEmail: %s\nТелефон: %s\nСообщение: %s",
-            lead.LandingID, lead.Name, lead.Email, lead.Phone, lead.Message)
-        if err := bot.SendNotification(text); err != nil {
-            // логируем, но не мешаем пользователю
-            fmt.Printf("bot send error: %v", err)
+        lead, err := saveLead(db, bot, Lead{
+            LandingID: req.LandingID,
+            Name:      req.Name,
+            Email:     req.Email,
+            Phone:     req.Phone,
+            Message:   req.Message,
+        })
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
         }
         c.JSON(http.StatusCreated, lead)
     }
+}
+
+// saveLead сохраняет лид и отправляет уведомление в Telegram
+func saveLead(db *sqlx.DB, bot *telegram.Bot, in Lead) (Lead, error) {
+    // сохраняем в БД
+    var lead Lead
+    sql := `INSERT INTO leads (landing_id, name, email, phone, message)
+            VALUES ($1,$2,$3,$4,$5)
+            RETURNING id, landing_id, name, email, phone, message`
+    if err := db.Get(&lead, sql,
+        in.LandingID, in.Name, in.Email, in.Phone, in.Message); err != nil {
+        return lead, err
+    }
+    if bot == nil {
+        return lead, nil
+    }
+    // отправляем уведомление ботом
+    text := fmt.Sprintf("Новая заявка:\nЛендинг: %d\nИмя: %s\nEmail: %s\nТелефон: %s\nСообщение: %s",
+        lead.LandingID, lead.Name, lead.Email, lead.Phone, lead.Message)
+    if err := bot.SendNotification(text); err != nil {
+        // логируем, но не мешаем пользователю
+        fmt.Printf("bot send error: %v", err)
+    }
+    return lead, nil
 }
\ No newline at end of file
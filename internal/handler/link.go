@@ -3,6 +3,7 @@ package handler
 import (
     "net/http"
     "strconv"
+    "time"
 
     "github.com/gin-gonic/gin"
     "github.com/jmoiron/sqlx"
@@ -10,22 +11,74 @@ import (
 
 // Link представляет кнопку или ссылку на лендинге
 type Link struct {
-    ID        int    `db:"id" json:"id"`
-    LandingID int    `db:"landing_id" json:"landingId"`
-    Type      string `db:"type" json:"type"`
-    Title     string `db:"title" json:"title"`
-    URL       string `db:"url" json:"url"`
-    Position  int    `db:"position" json:"position"`
+    ID        int       `db:"id" json:"id"`
+    LandingID int       `db:"landing_id" json:"landingId"`
+    Type      string    `db:"type" json:"type"`
+    Title     string    `db:"title" json:"title"`
+    URL       string    `db:"url" json:"url"`
+    Position  int       `db:"position" json:"position"`
+    CreatedAt time.Time `db:"created_at" json:"createdAt"`
+    UpdatedAt time.Time `db:"updated_at" json:"updatedAt"`
+
+    VisibleFrom  *time.Time `db:"visible_from" json:"visibleFrom"`
+    VisibleUntil *time.Time `db:"visible_until" json:"visibleUntil"`
+    MaxClicks    *int       `db:"max_clicks" json:"maxClicks"`
+    FallbackURL  string     `db:"fallback_url" json:"fallbackUrl"` // куда вести после max_clicks
+    ClickCount   int        `db:"click_count" json:"clickCount"`
+    Rules        LinkRules  `db:"rules" json:"rules"` // см. resolveLinkURL
+    State        string     `db:"-" json:"state,omitempty"` // см. linkState
+}
+
+// Состояния ссылки по окну показа и лимиту кликов
+const (
+    LinkUpcoming  = "upcoming"  // visible_from ещё не наступил
+    LinkLive      = "live"      // показывается
+    LinkExpired   = "expired"   // visible_until прошёл
+    LinkExhausted = "exhausted" // клики закончились
+)
+
+// linkState вычисляет состояние ссылки на момент now
+func linkState(l Link, now time.Time) string {
+    switch {
+    case l.VisibleFrom != nil && now.Before(*l.VisibleFrom):
+        return LinkUpcoming
+    case l.VisibleUntil != nil && !now.Before(*l.VisibleUntil):
+        return LinkExpired
+    case l.MaxClicks != nil && l.ClickCount >= *l.MaxClicks:
+        return LinkExhausted
+    }
+    return LinkLive
+}
+
+// validateLinkLimits проверяет окно показа, лимит кликов и fallback URL
+func validateLinkLimits(from, until *time.Time, maxClicks *int, fallbackURL string) fieldErrors {
+    errs := fieldErrors{}
+    if from != nil && until != nil && !until.After(*from) {
+        errs["visibleUntil"] = "must be after visibleFrom"
+    }
+    if maxClicks != nil && *maxClicks < 1 {
+        errs["maxClicks"] = "must be at least 1"
+    }
+    checkURL(errs, "fallbackUrl", fallbackURL, false)
+    if fallbackURL != "" && maxClicks == nil {
+        errs["fallbackUrl"] = "requires maxClicks"
+    }
+    return errs
 }
 
 // RegisterLinkRoutes регистрирует CRUD-эндпоинты для ссылок
 func RegisterLinkRoutes(rg *gin.RouterGroup, db *sqlx.DB) {
     r := rg.Group("/links")
-    r.GET("", listLinks(db))
-    r.POST("", createLink(db))
-    r.GET("/:id", getLink(db))
-    r.PUT("/:id", updateLink(db))
-    r.DELETE("/:id", deleteLink(db))
+    read, write := requireScope(ScopeLinksRead), requireScope(ScopeLinksWrite)
+    r.GET("", read, listLinks(db))
+    r.GET("/types", read, listLinkTypes)
+    r.POST("", write, createLink(db))
+    r.PUT("/order", write, reorderLinks(db))
+    r.POST("/batch", write, batchLinks(db))
+    r.GET("/:id", read, requireLinkRole(db, "id", RoleViewer), getLink(db))
+    r.PUT("/:id", write, requireLinkRole(db, "id", RoleEditor), updateLink(db))
+    r.DELETE("/:id", write, requireLinkRole(db, "id", RoleEditor), deleteLink(db))
+    r.POST("/:id/rules/test", read, requireLinkRole(db, "id", RoleViewer), testLinkRules(db))
 }
 
 func listLinks(db *sqlx.DB) gin.HandlerFunc {
@@ -36,23 +89,62 @@ func listLinks(db *sqlx.DB) gin.HandlerFunc {
             c.JSON(http.StatusBadRequest, gin.H{"error": "invalid landingId"})
             return
         }
+        if !authorizeLanding(c, db, landingID, RoleViewer) {
+            return
+        }
         var items []Link
         if err := db.Select(&items,
             "SELECT * FROM links WHERE landing_id=$1 ORDER BY position", landingID); err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
+        now := time.Now()
+        for i := range items {
+            items[i].State = linkState(items[i], now)
+        }
         c.JSON(http.StatusOK, items)
     }
 }
 
+// linkInput — редактируемые поля ссылки; общий для создания, изменения и batch
+type linkInput struct {
+    Type     string `json:"type"`
+    Title    string `json:"title"`
+    URL      string `json:"url"`
+    Position *int   `json:"position"` // индекс в списке; без него — в конец (или на прежнее место)
+
+    VisibleFrom  *time.Time `json:"visibleFrom"`
+    VisibleUntil *time.Time `json:"visibleUntil"`
+    MaxClicks    *int       `json:"maxClicks"`
+    FallbackURL  string     `json:"fallbackUrl"`
+    Rules        LinkRules  `json:"rules"`
+}
+
+// validate проверяет поля, нормализует адрес по типу (см. normalizeLink) и
+// правила; ключи ошибок — с prefix
+func (in *linkInput) validate(prefix string) fieldErrors {
+    errs := normalizeLink(in)
+    if in.Position != nil && *in.Position < 0 {
+        errs["position"] = "must not be negative"
+    }
+    for k, v := range validateLinkLimits(in.VisibleFrom, in.VisibleUntil, in.MaxClicks, in.FallbackURL) {
+        errs[k] = v
+    }
+    in.Rules, errs = mergeRuleErrors(in.Rules, errs)
+    if prefix == "" {
+        return errs
+    }
+    prefixed := fieldErrors{}
+    for k, v := range errs {
+        prefixed[prefix+k] = v
+    }
+    return prefixed
+}
+
 func createLink(db *sqlx.DB) gin.HandlerFunc {
     type request struct {
-        LandingID int    `json:"landingId" binding:"required"`
-        Type      string `json:"type"      binding:"required"`
-        Title     string `json:"title"     binding:"required"`
-        URL       string `json:"url"       binding:"required"`
-        Position  int    `json:"position"`
+        LandingID int `json:"landingId" binding:"required"`
+        linkInput
     }
     return func(c *gin.Context) {
         var req request
@@ -60,16 +152,24 @@ func createLink(db *sqlx.DB) gin.HandlerFunc {
             c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
             return
         }
+        if errs := req.validate(""); len(errs) > 0 {
+            respondFieldErrors(c, errs)
+            return
+        }
+        if !authorizeLanding(c, db, req.LandingID, RoleEditor) {
+            return
+        }
         var item Link
-        query := `
-            INSERT INTO links (landing_id, type, title, url, position)
-            VALUES ($1,$2,$3,$4,$5)
-            RETURNING *`
-        if err := db.Get(&item, query,
-            req.LandingID, req.Type, req.Title, req.URL, req.Position); err != nil {
+        err := mutateLinks(db, req.LandingID, func(tx *sqlx.Tx) error {
+            var err error
+            item, err = insertLink(tx, req.LandingID, req.linkInput)
+            return err
+        })
+        if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
+        item.State = linkState(item, time.Now())
         c.JSON(http.StatusCreated, item)
     }
 }
@@ -86,54 +186,151 @@ func getLink(db *sqlx.DB) gin.HandlerFunc {
             c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
             return
         }
+        item.State = linkState(item, time.Now())
         c.JSON(http.StatusOK, item)
     }
 }
 
 func updateLink(db *sqlx.DB) gin.HandlerFunc {
-    type request struct {
-        Type     string `json:"type"     binding:"required"`
-        Title    string `json:"title"    binding:"required"`
-        URL      string `json:"url"      binding:"required"`
-        Position int    `json:"position"`
-    }
     return func(c *gin.Context) {
-        id, err := strconv.Atoi(c.Param("id"))
-        if err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
-            return
-        }
-        var req request
+        var req linkInput
         if err := c.ShouldBindJSON(&req); err != nil {
             c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
             return
         }
+        if errs := req.validate(""); len(errs) > 0 {
+            respondFieldErrors(c, errs)
+            return
+        }
         var item Link
-        query := `
-            UPDATE links
-               SET type=$1, title=$2, url=$3, position=$4
-             WHERE id=$5
-          RETURNING *`
-        if err := db.Get(&item, query,
-            req.Type, req.Title, req.URL, req.Position, id); err != nil {
+        err := mutateLinks(db, c.GetInt(ctxLandingID), func(tx *sqlx.Tx) error {
+            var err error
+            item, err = updateLinkTx(tx, c.GetInt(ctxLinkID), req)
+            return err
+        })
+        if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
+        item.State = linkState(item, time.Now())
         c.JSON(http.StatusOK, item)
     }
 }
 
 func deleteLink(db *sqlx.DB) gin.HandlerFunc {
     return func(c *gin.Context) {
-        id, err := strconv.Atoi(c.Param("id"))
+        err := mutateLinks(db, c.GetInt(ctxLandingID), func(tx *sqlx.Tx) error {
+            return deleteLinkTx(tx, c.GetInt(ctxLinkID))
+        })
         if err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
-            return
-        }
-        if _, err := db.Exec("DELETE FROM links WHERE id=$1", id); err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
         c.Status(http.StatusNoContent)
     }
-}
\ No newline at end of file
+}
+
+// Позиции ссылок лендинга всегда идут подряд с нуля: уникальность
+// (landing_id, position) проверяется в конце транзакции (DEFERRABLE), а
+// функции ниже сдвигают соседей при вставке, перемещении и удалении.
+
+// mutateLinks меняет ссылки лендинга в транзакции под блокировкой строки
+// лендинга, чтобы правки его ссылок шли по очереди. Ошибка fn откатывает всё.
+func mutateLinks(db *sqlx.DB, landingID int, fn func(tx *sqlx.Tx) error) error {
+    tx, err := db.Beginx()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    var id int
+    if err := tx.Get(&id, "SELECT id FROM landings WHERE id=$1 FOR UPDATE", landingID); err != nil {
+        return err
+    }
+    if err := fn(tx); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// clampPosition ограничивает желаемую позицию диапазоном [0, max]
+func clampPosition(p *int, max int) int {
+    if p == nil || *p > max {
+        return max
+    }
+    return *p
+}
+
+// insertLink вставляет ссылку на позицию in.Position (по умолчанию в конец)
+func insertLink(tx *sqlx.Tx, landingID int, in linkInput) (Link, error) {
+    var item Link
+    var count int
+    if err := tx.Get(&count, "SELECT COUNT(*) FROM links WHERE landing_id=$1", landingID); err != nil {
+        return item, err
+    }
+    pos := clampPosition(in.Position, count)
+    if _, err := tx.Exec("UPDATE links SET position=position+1 WHERE landing_id=$1 AND position>=$2", landingID, pos); err != nil {
+        return item, err
+    }
+    query := `
+        INSERT INTO links (landing_id, type, title, url, position, visible_from, visible_until, max_clicks, fallback_url, rules)
+        VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+        RETURNING *`
+    err := tx.Get(&item, query, landingID, in.Type, in.Title, in.URL, pos,
+        in.VisibleFrom, in.VisibleUntil, in.MaxClicks, in.FallbackURL, in.Rules)
+    return item, err
+}
+
+// updateLinkTx переписывает поля ссылки и, если задана позиция, перемещает её
+func updateLinkTx(tx *sqlx.Tx, id int, in linkInput) (Link, error) {
+    var cur Link
+    if err := tx.Get(&cur, "SELECT * FROM links WHERE id=$1", id); err != nil {
+        return cur, err
+    }
+    pos := cur.Position
+    if in.Position != nil {
+        var count int
+        if err := tx.Get(&count, "SELECT COUNT(*) FROM links WHERE landing_id=$1", cur.LandingID); err != nil {
+            return cur, err
+        }
+        pos = clampPosition(in.Position, count-1)
+        if err := shiftLinks(tx, cur.LandingID, cur.Position, pos); err != nil {
+            return cur, err
+        }
+    }
+    var item Link
+    query := `
+        UPDATE links
+           SET type=$1, title=$2, url=$3, position=$4,
+               visible_from=$5, visible_until=$6, max_clicks=$7, fallback_url=$8, rules=$9, updated_at=NOW()
+         WHERE id=$10
+      RETURNING *`
+    err := tx.Get(&item, query, in.Type, in.Title, in.URL, pos,
+        in.VisibleFrom, in.VisibleUntil, in.MaxClicks, in.FallbackURL, in.Rules, id)
+    return item, err
+}
+
+// shiftLinks освобождает позицию to для ссылки, переезжающей с from
+func shiftLinks(tx *sqlx.Tx, landingID, from, to int) error {
+    var err error
+    switch {
+    case to < from:
+        _, err = tx.Exec("UPDATE links SET position=position+1 WHERE landing_id=$1 AND position>=$2 AND position<$3", landingID, to, from)
+    case to > from:
+        _, err = tx.Exec("UPDATE links SET position=position-1 WHERE landing_id=$1 AND position>$2 AND position<=$3", landingID, from, to)
+    }
+    return err
+}
+
+// deleteLinkTx удаляет ссылку и сдвигает следующие за ней на её место
+func deleteLinkTx(tx *sqlx.Tx, id int) error {
+    var row struct {
+        LandingID int `db:"landing_id"`
+        Position  int `db:"position"`
+    }
+    if err := tx.Get(&row, "DELETE FROM links WHERE id=$1 RETURNING landing_id, position", id); err != nil {
+        return err
+    }
+    _, err := tx.Exec("UPDATE links SET position=position-1 WHERE landing_id=$1 AND position>$2", row.LandingID, row.Position)
+    return err
+}
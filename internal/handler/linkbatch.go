@@ -0,0 +1,198 @@
+package handler
+
+import (
+    "database/sql"
+    "errors"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+    "github.com/lib/pq"
+)
+
+// maxLinkBatch — ограничение на число операций в одном batch-запросе
+const maxLinkBatch = 200
+
+// errLinkBatch — операция batch ссылается на чужую или несуществующую ссылку
+type errLinkBatch struct{ fields fieldErrors }
+
+func (e errLinkBatch) Error() string { return "invalid batch" }
+
+// reorderLinks задаёт порядок всех ссылок лендинга одним запросом:
+// ids должен перечислять каждую ссылку ровно один раз
+func reorderLinks(db *sqlx.DB) gin.HandlerFunc {
+    type request struct {
+        LandingID int   `json:"landingId" binding:"required"`
+        IDs       []int `json:"ids" binding:"required"`
+    }
+    return func(c *gin.Context) {
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        if !authorizeLanding(c, db, req.LandingID, RoleEditor) {
+            return
+        }
+        err := mutateLinks(db, req.LandingID, func(tx *sqlx.Tx) error {
+            var existing []int
+            if err := tx.Select(&existing, "SELECT id FROM links WHERE landing_id=$1", req.LandingID); err != nil {
+                return err
+            }
+            if !sameIDSet(existing, req.IDs) {
+                return errLinkBatch{fieldErrors{"ids": "must list every link of the landing exactly once"}}
+            }
+            ids := make([]int64, len(req.IDs))
+            for i, id := range req.IDs {
+                ids[i] = int64(id)
+            }
+            query := `UPDATE links k SET position = o.pos - 1, updated_at = NOW()
+                      FROM unnest($2::int[]) WITH ORDINALITY AS o(id, pos)
+                      WHERE k.id = o.id AND k.landing_id = $1 AND k.position <> o.pos - 1`
+            _, err := tx.Exec(query, req.LandingID, pq.Int64Array(ids))
+            return err
+        })
+        respondLinkBatch(c, db, req.LandingID, err)
+    }
+}
+
+// batchLinks применяет создание, изменение и удаление ссылок одного лендинга
+// атомарно: при любой ошибке не меняется ничего. Порядок применения —
+// delete, update, create; позиции в update и create — индексы в списке на
+// момент применения операции.
+func batchLinks(db *sqlx.DB) gin.HandlerFunc {
+    type updateOp struct {
+        ID int `json:"id"`
+        linkInput
+    }
+    type request struct {
+        LandingID int         `json:"landingId" binding:"required"`
+        Create    []linkInput `json:"create"`
+        Update    []updateOp  `json:"update"`
+        Delete    []int       `json:"delete"`
+    }
+    return func(c *gin.Context) {
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        if len(req.Create)+len(req.Update)+len(req.Delete) > maxLinkBatch {
+            respondFieldErrors(c, fieldErrors{"batch": "at most " + strconv.Itoa(maxLinkBatch) + " operations are allowed"})
+            return
+        }
+        errs := fieldErrors{}
+        for i := range req.Create {
+            for k, v := range req.Create[i].validate("create." + strconv.Itoa(i) + ".") {
+                errs[k] = v
+            }
+        }
+        for i := range req.Update {
+            for k, v := range req.Update[i].validate("update." + strconv.Itoa(i) + ".") {
+                errs[k] = v
+            }
+        }
+        if len(errs) > 0 {
+            respondFieldErrors(c, errs)
+            return
+        }
+        if !authorizeLanding(c, db, req.LandingID, RoleEditor) {
+            return
+        }
+
+        err := mutateLinks(db, req.LandingID, func(tx *sqlx.Tx) error {
+            var existing []int
+            if err := tx.Select(&existing, "SELECT id FROM links WHERE landing_id=$1", req.LandingID); err != nil {
+                return err
+            }
+            owned := map[int]bool{}
+            for _, id := range existing {
+                owned[id] = true
+            }
+            deleted := map[int]bool{}
+            for i, id := range req.Delete {
+                if !owned[id] || deleted[id] {
+                    errs["delete."+strconv.Itoa(i)] = "link not found"
+                }
+                deleted[id] = true
+            }
+            for i, op := range req.Update {
+                if !owned[op.ID] || deleted[op.ID] {
+                    errs["update."+strconv.Itoa(i)+".id"] = "link not found"
+                }
+            }
+            if len(errs) > 0 {
+                return errLinkBatch{errs}
+            }
+
+            for _, id := range req.Delete {
+                if err := deleteLinkTx(tx, id); err != nil {
+                    return err
+                }
+            }
+            for _, op := range req.Update {
+                if _, err := updateLinkTx(tx, op.ID, op.linkInput); err != nil {
+                    return err
+                }
+            }
+            for _, in := range req.Create {
+                if _, err := insertLink(tx, req.LandingID, in); err != nil {
+                    return err
+                }
+            }
+            return nil
+        })
+        respondLinkBatch(c, db, req.LandingID, err)
+    }
+}
+
+// respondLinkBatch отвечает итоговым списком ссылок лендинга или ошибкой
+func respondLinkBatch(c *gin.Context, db *sqlx.DB, landingID int, err error) {
+    var batchErr errLinkBatch
+    switch {
+    case errors.As(err, &batchErr):
+        respondFieldErrors(c, batchErr.fields)
+        return
+    case err == sql.ErrNoRows:
+        c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+        return
+    case isUniqueViolation(err):
+        // позиции разошлись с ожидаемыми (например, старые данные) — повторять бесполезно
+        c.JSON(http.StatusConflict, gin.H{"error": "link positions conflict"})
+        return
+    case err != nil:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    items := []Link{}
+    if err := db.Select(&items, "SELECT * FROM links WHERE landing_id=$1 ORDER BY position", landingID); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    now := time.Now()
+    for i := range items {
+        items[i].State = linkState(items[i], now)
+    }
+    c.JSON(http.StatusOK, items)
+}
+
+// sameIDSet сообщает, что b — перестановка a без повторов
+func sameIDSet(a, b []int) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    seen := make(map[int]bool, len(a))
+    for _, id := range a {
+        seen[id] = true
+    }
+    for _, id := range b {
+        if !seen[id] {
+            return false
+        }
+        delete(seen, id)
+    }
+    return true
+}
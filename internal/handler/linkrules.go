@@ -0,0 +1,295 @@
+package handler
+
+import (
+    "database/sql/driver"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+)
+
+// maxLinkRules — ограничение на число правил одной ссылки
+const maxLinkRules = 20
+
+// LinkRule направляет посетителя на URL, если он подходит под все заданные
+// условия. Внутри одного условия достаточно совпадения с любым значением.
+type LinkRule struct {
+    Countries []string `json:"countries,omitempty"` // ISO 3166-1 alpha-2: RU, KZ
+    OS        []string `json:"os,omitempty"`        // см. ruleOS
+    Browsers  []string `json:"browsers,omitempty"`  // см. ruleBrowsers
+    Languages []string `json:"languages,omitempty"` // ISO 639-1: ru, en
+    Telegram  *bool    `json:"telegram,omitempty"`  // открыта ли ссылка во встроенном браузере Telegram
+    URL       string   `json:"url"`
+}
+
+// LinkRules хранится в links.rules как JSONB; порядок важен — срабатывает первое
+type LinkRules []LinkRule
+
+// Value реализует driver.Valuer
+func (r LinkRules) Value() (driver.Value, error) {
+    if r == nil {
+        return []byte("[]"), nil
+    }
+    return json.Marshal(r)
+}
+
+// Scan реализует sql.Scanner
+func (r *LinkRules) Scan(src interface{}) error {
+    var data []byte
+    switch v := src.(type) {
+    case []byte:
+        data = v
+    case string:
+        data = []byte(v)
+    case nil:
+        *r = LinkRules{}
+        return nil
+    default:
+        return fmt.Errorf("link rules: unsupported type %T", src)
+    }
+    return json.Unmarshal(data, r)
+}
+
+// Допустимые значения условий
+var (
+    ruleOS       = map[string]bool{"ios": true, "android": true, "windows": true, "macos": true, "linux": true, "chromeos": true}
+    ruleBrowsers = map[string]bool{"chrome": true, "safari": true, "firefox": true, "edge": true, "opera": true, "samsung": true, "yandex": true}
+    countryRe    = regexp.MustCompile(`^[A-Z]{2}$`)
+    languageRe   = regexp.MustCompile(`^[a-z]{2,3}$`)
+)
+
+// normalizeLinkRules приводит значения условий к каноническому виду
+// (страны — в верхнем регистре, остальное — в нижнем) и проверяет их.
+// Ошибки возвращаются с путями вида rules.0.countries.
+func normalizeLinkRules(rules LinkRules) (LinkRules, fieldErrors) {
+    errs := fieldErrors{}
+    if rules == nil {
+        return LinkRules{}, errs
+    }
+    if len(rules) > maxLinkRules {
+        errs["rules"] = fmt.Sprintf("must contain at most %d rules", maxLinkRules)
+        return rules, errs
+    }
+    out := make(LinkRules, len(rules))
+    for i, r := range rules {
+        prefix := "rules." + strconv.Itoa(i) + "."
+        check := func(field string, values []string, canon func(string) string, valid func(string) bool) []string {
+            res := make([]string, 0, len(values))
+            for _, v := range values {
+                v = canon(strings.TrimSpace(v))
+                if !valid(v) {
+                    errs[prefix+field] = fmt.Sprintf("unsupported value %q", v)
+                    continue
+                }
+                res = append(res, v)
+            }
+            if len(res) == 0 {
+                return nil
+            }
+            return res
+        }
+        r.Countries = check("countries", r.Countries, strings.ToUpper, countryRe.MatchString)
+        r.OS = check("os", r.OS, strings.ToLower, func(v string) bool { return ruleOS[v] })
+        r.Browsers = check("browsers", r.Browsers, strings.ToLower, func(v string) bool { return ruleBrowsers[v] })
+        r.Languages = check("languages", r.Languages, strings.ToLower, languageRe.MatchString)
+        if r.Countries == nil && r.OS == nil && r.Browsers == nil && r.Languages == nil && r.Telegram == nil {
+            errs[prefix+"conditions"] = "at least one condition is required"
+        }
+        checkURL(errs, prefix+"url", r.URL, true)
+        out[i] = r
+    }
+    return out, errs
+}
+
+// mergeRuleErrors нормализует правила и добавляет их ошибки к errs
+func mergeRuleErrors(rules LinkRules, errs fieldErrors) (LinkRules, fieldErrors) {
+    rules, ruleErrs := normalizeLinkRules(rules)
+    for k, v := range ruleErrs {
+        errs[k] = v
+    }
+    return rules, errs
+}
+
+// visitor — признаки посетителя, по которым выбирается правило
+type visitor struct {
+    Country  string `json:"country"`
+    OS       string `json:"os"`
+    Browser  string `json:"browser"`
+    Language string `json:"language"`
+    Telegram bool   `json:"telegram"`
+}
+
+// visitorFromRequest определяет посетителя по заголовкам. Страну сообщает
+// CDN перед сервером (CF-IPCountry у Cloudflare или X-Country-Code).
+func visitorFromRequest(r *http.Request) visitor {
+    country := r.Header.Get("CF-IPCountry")
+    if country == "" {
+        country = r.Header.Get("X-Country-Code")
+    }
+    v := parseUserAgent(r.UserAgent())
+    v.Country = strings.ToUpper(strings.TrimSpace(country))
+    // XX и T1 — у Cloudflare «неизвестно» и Tor
+    if v.Country == "XX" || v.Country == "T1" {
+        v.Country = ""
+    }
+    v.Language = preferredLanguage(r.Header.Get("Accept-Language"))
+    return v
+}
+
+// parseUserAgent определяет ОС, браузер и встроенный браузер Telegram.
+// Порядок проверок важен: многие браузеры называют себя Chrome и Safari.
+func parseUserAgent(ua string) visitor {
+    var v visitor
+    switch {
+    case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"), strings.Contains(ua, "iPod"):
+        v.OS = "ios"
+    case strings.Contains(ua, "Android"):
+        v.OS = "android"
+    case strings.Contains(ua, "CrOS"):
+        v.OS = "chromeos"
+    case strings.Contains(ua, "Windows"):
+        v.OS = "windows"
+    case strings.Contains(ua, "Macintosh"), strings.Contains(ua, "Mac OS X"):
+        v.OS = "macos"
+    case strings.Contains(ua, "Linux"):
+        v.OS = "linux"
+    }
+    switch {
+    case strings.Contains(ua, "YaBrowser"):
+        v.Browser = "yandex"
+    case strings.Contains(ua, "SamsungBrowser"):
+        v.Browser = "samsung"
+    case strings.Contains(ua, "Edg/"), strings.Contains(ua, "EdgA/"), strings.Contains(ua, "EdgiOS/"):
+        v.Browser = "edge"
+    case strings.Contains(ua, "OPR/"), strings.Contains(ua, "Opera"):
+        v.Browser = "opera"
+    case strings.Contains(ua, "Firefox/"), strings.Contains(ua, "FxiOS/"):
+        v.Browser = "firefox"
+    case strings.Contains(ua, "Chrome/"), strings.Contains(ua, "CriOS/"):
+        v.Browser = "chrome"
+    case strings.Contains(ua, "Safari/"):
+        v.Browser = "safari"
+    }
+    v.Telegram = strings.Contains(ua, "Telegram")
+    return v
+}
+
+// preferredLanguage возвращает основной тег самого предпочтительного языка
+// из Accept-Language: "ru-RU,ru;q=0.9,en;q=0.8" → "ru"
+func preferredLanguage(header string) string {
+    type lang struct {
+        tag string
+        q   float64
+    }
+    var langs []lang
+    for _, part := range strings.Split(header, ",") {
+        fields := strings.Split(strings.TrimSpace(part), ";")
+        tag := strings.ToLower(strings.TrimSpace(fields[0]))
+        if tag == "" || tag == "*" {
+            continue
+        }
+        q := 1.0
+        for _, f := range fields[1:] {
+            if v, ok := strings.CutPrefix(strings.TrimSpace(f), "q="); ok {
+                if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+                    q = parsed
+                }
+            }
+        }
+        if q > 0 {
+            langs = append(langs, lang{tag, q})
+        }
+    }
+    if len(langs) == 0 {
+        return ""
+    }
+    sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+    primary, _, _ := strings.Cut(langs[0].tag, "-")
+    return primary
+}
+
+// matches сообщает, подходит ли посетитель под правило
+func (r LinkRule) matches(v visitor) bool {
+    in := func(values []string, value string) bool {
+        if len(values) == 0 {
+            return true
+        }
+        for _, x := range values {
+            if x == value {
+                return true
+            }
+        }
+        return false
+    }
+    return in(r.Countries, v.Country) && in(r.OS, v.OS) && in(r.Browsers, v.Browser) &&
+        in(r.Languages, v.Language) && (r.Telegram == nil || *r.Telegram == v.Telegram)
+}
+
+// resolveLinkURL выбирает адрес по первому подошедшему правилу; -1 — ни одно
+// не подошло и используется url самой ссылки
+func resolveLinkURL(l Link, v visitor) (string, int) {
+    for i, r := range l.Rules {
+        if r.matches(v) {
+            return r.URL, i
+        }
+    }
+    return l.URL, -1
+}
+
+// testLinkRules показывает, куда попадёт посетитель с заданными признаками.
+// Признаки можно задать явно или передать userAgent / acceptLanguage —
+// тогда они определяются так же, как при настоящем переходе.
+func testLinkRules(db *sqlx.DB) gin.HandlerFunc {
+    type request struct {
+        Country        string `json:"country"`
+        OS             string `json:"os"`
+        Browser        string `json:"browser"`
+        Language       string `json:"language"`
+        Telegram       *bool  `json:"telegram"`
+        UserAgent      string `json:"userAgent"`
+        AcceptLanguage string `json:"acceptLanguage"`
+    }
+    return func(c *gin.Context) {
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        var link Link
+        if err := db.Get(&link, "SELECT * FROM links WHERE id=$1", c.Param("id")); err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+
+        v := parseUserAgent(req.UserAgent)
+        v.Language = preferredLanguage(req.AcceptLanguage)
+        if req.Country != "" {
+            v.Country = strings.ToUpper(req.Country)
+        }
+        if req.OS != "" {
+            v.OS = strings.ToLower(req.OS)
+        }
+        if req.Browser != "" {
+            v.Browser = strings.ToLower(req.Browser)
+        }
+        if req.Language != "" {
+            v.Language = strings.ToLower(req.Language)
+        }
+        if req.Telegram != nil {
+            v.Telegram = *req.Telegram
+        }
+
+        target, rule := resolveLinkURL(link, v)
+        resp := gin.H{"visitor": v, "url": target, "ruleIndex": rule}
+        if rule >= 0 {
+            resp["rule"] = link.Rules[rule]
+        }
+        c.JSON(http.StatusOK, resp)
+    }
+}
@@ -0,0 +1,283 @@
+package handler
+
+import (
+    "errors"
+    "net/http"
+    "net/mail"
+    "net/url"
+    "regexp"
+    "sort"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+)
+
+// Типы ссылок
+const (
+    LinkURL       = "url" // произвольный адрес
+    LinkTelegram  = "telegram"
+    LinkWhatsApp  = "whatsapp"
+    LinkInstagram = "instagram"
+    LinkYouTube   = "youtube"
+    LinkTikTok    = "tiktok"
+    LinkVK        = "vk"
+    LinkEmail     = "email"
+    LinkPhone     = "phone"
+)
+
+// linkType описывает тип ссылки: как выглядит кнопка и как разбирается
+// то, что ввёл пользователь (username, номер телефона, адрес)
+type linkType struct {
+    Title       string `json:"title"`       // заголовок кнопки по умолчанию
+    Icon        string `json:"icon"`        // имя иконки в templates/icons, пусто — без иконки
+    Color       string `json:"color"`       // фирменный цвет, hex без #
+    Placeholder string `json:"placeholder"` // подсказка для поля url
+    normalize   func(raw string) (string, error)
+}
+
+// linkTypes — реестр поддерживаемых типов
+var linkTypes = map[string]linkType{
+    LinkURL:       {Title: "Сайт", Placeholder: "https://example.com", normalize: normalizeWebURL},
+    LinkTelegram:  {Title: "Telegram", Icon: "telegram", Color: "26A5E4", Placeholder: "@channel или https://t.me/channel", normalize: normalizeTelegram},
+    LinkWhatsApp:  {Title: "WhatsApp", Icon: "whatsapp", Color: "25D366", Placeholder: "+79991234567", normalize: normalizeWhatsApp},
+    LinkInstagram: {Title: "Instagram", Icon: "instagram", Color: "E4405F", Placeholder: "@username", normalize: profileNormalizer("instagram.com", "https://instagram.com/", usernameRe)},
+    LinkYouTube:   {Title: "YouTube", Icon: "youtube", Color: "FF0000", Placeholder: "@channel или https://youtube.com/...", normalize: normalizeYouTube},
+    LinkTikTok:    {Title: "TikTok", Icon: "tiktok", Color: "000000", Placeholder: "@username", normalize: profileNormalizer("tiktok.com", "https://www.tiktok.com/@", usernameRe)},
+    LinkVK:        {Title: "ВКонтакте", Icon: "vk", Color: "0077FF", Placeholder: "username или https://vk.com/...", normalize: profileNormalizer("vk.com", "https://vk.com/", usernameRe)},
+    LinkEmail:     {Title: "Написать на почту", Placeholder: "hello@example.com", normalize: normalizeEmail},
+    LinkPhone:     {Title: "Позвонить", Placeholder: "+79991234567", normalize: normalizePhone},
+}
+
+var (
+    telegramUserRe  = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{3,31}$`)
+    usernameRe      = regexp.MustCompile(`^[A-Za-z0-9._]{1,30}$`)
+    youtubeHandleRe = regexp.MustCompile(`^[A-Za-z0-9._-]{3,30}$`)
+)
+
+// lookupLinkType возвращает тип по имени; старые типы (button, social и
+// прочие до появления реестра) показываются как обычные ссылки
+func lookupLinkType(name string) linkType {
+    if t, ok := linkTypes[name]; ok {
+        return t
+    }
+    return linkTypes[LinkURL]
+}
+
+// normalizeLink приводит тип и адрес ссылки к каноническому виду и
+// подставляет заголовок по умолчанию. Ошибки — по полям type и url.
+func normalizeLink(in *linkInput) fieldErrors {
+    errs := fieldErrors{}
+    in.Type = strings.ToLower(strings.TrimSpace(in.Type))
+    if in.Type == "" {
+        in.Type = LinkURL
+    }
+    t, ok := linkTypes[in.Type]
+    if !ok {
+        errs["type"] = "unsupported link type"
+        return errs
+    }
+    if strings.TrimSpace(in.URL) == "" {
+        errs["url"] = "is required"
+    } else if u, err := t.normalize(strings.TrimSpace(in.URL)); err != nil {
+        errs["url"] = err.Error()
+    } else {
+        in.URL = u
+    }
+    in.Title = strings.TrimSpace(in.Title)
+    if in.Title == "" {
+        in.Title = t.Title
+    }
+    if len([]rune(in.Title)) > 255 {
+        errs["title"] = "must be at most 255 characters"
+    }
+    return errs
+}
+
+// parseHTTP разбирает адрес, дописывая https://, если схема не указана
+func parseHTTP(raw string) (*url.URL, error) {
+    if !strings.Contains(raw, "://") {
+        raw = "https://" + raw
+    }
+    u, err := url.Parse(raw)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !strings.Contains(u.Host, ".") {
+        return nil, errors.New("must be a valid http(s) URL")
+    }
+    return u, nil
+}
+
+// hostIs сравнивает хост без www. и m.
+func hostIs(u *url.URL, host string) bool {
+    h := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."), "m.")
+    return h == host
+}
+
+func normalizeWebURL(raw string) (string, error) {
+    u, err := parseHTTP(raw)
+    if err != nil {
+        return "", err
+    }
+    return u.String(), nil
+}
+
+// normalizeTelegram: @channel, channel, t.me/channel, tg://resolve?domain=channel
+// → https://t.me/channel; приглашения (t.me/+..., t.me/joinchat/...) сохраняются как есть
+func normalizeTelegram(raw string) (string, error) {
+    const msg = "must be a Telegram username or t.me link"
+    if strings.HasPrefix(raw, "tg://") {
+        u, err := url.Parse(raw)
+        if err != nil || !telegramUserRe.MatchString(u.Query().Get("domain")) {
+            return "", errors.New(msg)
+        }
+        return "https://t.me/" + u.Query().Get("domain"), nil
+    }
+    if name := strings.TrimPrefix(raw, "@"); telegramUserRe.MatchString(name) {
+        return "https://t.me/" + name, nil
+    }
+    u, err := parseHTTP(raw)
+    if err != nil || !(hostIs(u, "t.me") || hostIs(u, "telegram.me")) {
+        return "", errors.New(msg)
+    }
+    path := strings.Trim(u.Path, "/")
+    first, _, _ := strings.Cut(path, "/")
+    switch {
+    case strings.HasPrefix(first, "+"), first == "joinchat", first == "addstickers", first == "c":
+    case telegramUserRe.MatchString(first):
+    default:
+        return "", errors.New(msg)
+    }
+    u.Scheme, u.Host = "https", "t.me"
+    return u.String(), nil
+}
+
+// normalizeWhatsApp: номер или ссылка wa.me / api.whatsapp.com → https://wa.me/<цифры>
+func normalizeWhatsApp(raw string) (string, error) {
+    if phone, err := normalizePhoneDigits(raw); err == nil {
+        return "https://wa.me/" + strings.TrimPrefix(phone, "+"), nil
+    }
+    u, err := parseHTTP(raw)
+    if err != nil {
+        return "", errors.New("must be a phone number or wa.me link")
+    }
+    switch {
+    case hostIs(u, "wa.me"):
+        if phone, err := normalizePhoneDigits(strings.Trim(u.Path, "/")); err == nil {
+            return "https://wa.me/" + strings.TrimPrefix(phone, "+"), nil
+        }
+        // короткие ссылки каталогов и сообщений (wa.me/message/...) оставляем
+        if u.Path != "" && u.Path != "/" {
+            u.Scheme = "https"
+            return u.String(), nil
+        }
+    case hostIs(u, "api.whatsapp.com"), hostIs(u, "whatsapp.com"):
+        if phone, err := normalizePhoneDigits(u.Query().Get("phone")); err == nil {
+            return "https://wa.me/" + strings.TrimPrefix(phone, "+"), nil
+        }
+        if strings.HasPrefix(u.Path, "/channel/") {
+            u.Scheme = "https"
+            return u.String(), nil
+        }
+    }
+    return "", errors.New("must be a phone number or wa.me link")
+}
+
+// profileNormalizer строит нормализатор профиля соцсети: @user, user или
+// ссылка на host → prefix + user
+func profileNormalizer(host, prefix string, userRe *regexp.Regexp) func(string) (string, error) {
+    msg := "must be a username or " + host + " link"
+    return func(raw string) (string, error) {
+        if name := strings.TrimPrefix(raw, "@"); userRe.MatchString(name) && !strings.Contains(name, "..") {
+            return prefix + name, nil
+        }
+        u, err := parseHTTP(raw)
+        if err != nil || !hostIs(u, host) {
+            return "", errors.New(msg)
+        }
+        first, rest, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+        if name := strings.TrimPrefix(first, "@"); rest == "" && userRe.MatchString(name) && u.RawQuery == "" {
+            return prefix + name, nil
+        }
+        // посты, клипы и прочие вложенные адреса сохраняем как есть
+        if first == "" {
+            return "", errors.New(msg)
+        }
+        u.Scheme = "https"
+        return u.String(), nil
+    }
+}
+
+// normalizeYouTube: @handle → https://youtube.com/@handle; ссылки youtube.com и youtu.be
+func normalizeYouTube(raw string) (string, error) {
+    const msg = "must be a YouTube @handle or youtube.com link"
+    if strings.HasPrefix(raw, "@") {
+        if !youtubeHandleRe.MatchString(raw[1:]) {
+            return "", errors.New(msg)
+        }
+        return "https://youtube.com/" + raw, nil
+    }
+    u, err := parseHTTP(raw)
+    if err != nil || !(hostIs(u, "youtube.com") || hostIs(u, "youtu.be") || hostIs(u, "music.youtube.com")) {
+        return "", errors.New(msg)
+    }
+    if u.Path == "" || u.Path == "/" {
+        return "", errors.New(msg)
+    }
+    u.Scheme = "https"
+    return u.String(), nil
+}
+
+// normalizeEmail: адрес или mailto: → mailto:адрес
+func normalizeEmail(raw string) (string, error) {
+    addr, err := mail.ParseAddress(strings.TrimPrefix(raw, "mailto:"))
+    if err != nil || !strings.Contains(addr.Address, ".") {
+        return "", errors.New("must be a valid email address")
+    }
+    return "mailto:" + addr.Address, nil
+}
+
+// normalizePhone: +7 (999) 123-45-67, tel:... → tel:+79991234567
+func normalizePhone(raw string) (string, error) {
+    phone, err := normalizePhoneDigits(strings.TrimPrefix(raw, "tel:"))
+    if err != nil {
+        return "", err
+    }
+    return "tel:" + phone, nil
+}
+
+// normalizePhoneDigits приводит номер к E.164 (+ и 8–15 цифр). Российские
+// номера в местном формате (8 999 ...) переводятся в +7.
+func normalizePhoneDigits(raw string) (string, error) {
+    var digits strings.Builder
+    plus := strings.HasPrefix(strings.TrimSpace(raw), "+")
+    for _, r := range raw {
+        switch {
+        case r >= '0' && r <= '9':
+            digits.WriteRune(r)
+        case strings.ContainsRune(" -().+", r):
+        default:
+            return "", errors.New("must be a phone number in international format, e.g. +79991234567")
+        }
+    }
+    d := digits.String()
+    if !plus && len(d) == 11 && d[0] == '8' {
+        d = "7" + d[1:]
+        plus = true
+    }
+    if !plus || len(d) < 8 || len(d) > 15 || d[0] == '0' {
+        return "", errors.New("must be a phone number in international format, e.g. +79991234567")
+    }
+    return "+" + d, nil
+}
+
+// listLinkTypes отдаёт реестр типов для редактора ссылок
+func listLinkTypes(c *gin.Context) {
+    type item struct {
+        Type string `json:"type"`
+        linkType
+    }
+    items := make([]item, 0, len(linkTypes))
+    for name, t := range linkTypes {
+        items = append(items, item{Type: name, linkType: t})
+    }
+    sort.Slice(items, func(i, j int) bool { return items[i].Type < items[j].Type })
+    c.JSON(http.StatusOK, items)
+}
@@ -0,0 +1,427 @@
+package handler
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "image"
+    "io"
+    "log"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+
+    _ "github.com/blagoweb/bbtg/internal/imaging" // декодеры png, jpeg, gif, webp для image.DecodeConfig
+    "github.com/blagoweb/bbtg/internal/storage"
+)
+
+// Media — загруженный файл (аватар или картинка для блоков)
+type Media struct {
+    ID          int       `db:"id" json:"id"`
+    WorkspaceID int       `db:"workspace_id" json:"workspaceId"`
+    LandingID   *int      `db:"landing_id" json:"landingId"`
+    UploadedBy  *int      `db:"uploaded_by" json:"uploadedBy"`
+    Kind        string    `db:"kind" json:"kind"`
+    ObjectKey   string    `db:"object_key" json:"-"`
+    URL         string    `db:"url" json:"url"`
+    ContentType string    `db:"content_type" json:"contentType"`
+    Size        int       `db:"size" json:"size"`
+    Width       int       `db:"width" json:"width"`
+    Height      int       `db:"height" json:"height"`
+    SHA256      string    `db:"sha256" json:"sha256"`
+    CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+
+    SourceSHA256 string         `db:"source_sha256" json:"-"` // хэш файла до обработки
+    Variants     []MediaVariant `db:"-" json:"variants"`
+}
+
+// Виды загрузок
+const (
+    MediaAvatar = "avatar"
+    MediaImage  = "image"
+)
+
+// mediaExtensions — допустимые типы файлов и их расширения
+var mediaExtensions = map[string]string{
+    "image/png":  ".png",
+    "image/jpeg": ".jpg",
+    "image/gif":  ".gif",
+    "image/webp": ".webp",
+}
+
+// Ограничения на загрузку по видам
+var mediaLimits = map[string]struct {
+    MaxSize      int64
+    MaxDimension int
+}{
+    MediaAvatar: {MaxSize: 5 << 20, MaxDimension: 4096},
+    MediaImage:  {MaxSize: 15 << 20, MaxDimension: 10000},
+}
+
+var (
+    errMediaTooLarge   = errors.New("file is too large")
+    errMediaType       = errors.New("unsupported file type, allowed: png, jpeg, gif, webp")
+    errMediaCorrupt    = errors.New("file is not a valid image")
+    errMediaDimensions = errors.New("image dimensions are too large")
+    errNoStorage       = errors.New("media storage is not configured")
+)
+
+// RegisterMediaRoutes регистрирует загрузку и управление файлами
+func RegisterMediaRoutes(rg *gin.RouterGroup, db *sqlx.DB, store storage.Backend) {
+    r := rg.Group("/media")
+    read, write := requireScope(ScopeLandingsRead), requireScope(ScopeLandingsWrite)
+    r.GET("", read, listMedia(db))
+    r.POST("", write, uploadMedia(db, store))
+    r.DELETE("/:id", write, deleteMedia(db, store))
+}
+
+// registerAvatarRoutes регистрирует загрузку аватара внутри /landings/:id
+func registerAvatarRoutes(r *gin.RouterGroup, db *sqlx.DB, store storage.Backend) {
+    r.POST("/:id/avatar", requireScope(ScopeLandingsWrite), requireLandingRole(db, "id", RoleEditor), uploadAvatar(db, store))
+}
+
+// contentKey строит ключ объекта по содержимому: prefix/<sha256>.<ext>
+func contentKey(prefix string, data []byte, ext string) string {
+    sum := sha256.Sum256(data)
+    return prefix + "/" + hex.EncodeToString(sum[:]) + ext
+}
+
+// readUpload читает файл из multipart-поля file с учётом лимита вида kind,
+// определяет тип по содержимому (заголовку клиента не верим) и размеры
+func readUpload(c *gin.Context, kind string) ([]byte, string, image.Config, error) {
+    limit := mediaLimits[kind]
+    var cfg image.Config
+    c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit.MaxSize+1<<20)
+    fh, err := c.FormFile("file")
+    if err != nil {
+        var maxErr *http.MaxBytesError
+        if errors.As(err, &maxErr) {
+            return nil, "", cfg, errMediaTooLarge
+        }
+        return nil, "", cfg, errors.New("file is required")
+    }
+    if fh.Size > limit.MaxSize {
+        return nil, "", cfg, errMediaTooLarge
+    }
+    f, err := fh.Open()
+    if err != nil {
+        return nil, "", cfg, err
+    }
+    defer f.Close()
+    data, err := io.ReadAll(io.LimitReader(f, limit.MaxSize+1))
+    if err != nil {
+        return nil, "", cfg, err
+    }
+    if int64(len(data)) > limit.MaxSize {
+        return nil, "", cfg, errMediaTooLarge
+    }
+
+    contentType := http.DetectContentType(data)
+    if _, ok := mediaExtensions[contentType]; !ok {
+        return nil, "", cfg, errMediaType
+    }
+    if cfg, _, err = image.DecodeConfig(bytes.NewReader(data)); err != nil {
+        return nil, "", cfg, errMediaCorrupt
+    }
+    if cfg.Width > limit.MaxDimension || cfg.Height > limit.MaxDimension {
+        return nil, "", cfg, errMediaDimensions
+    }
+    return data, contentType, cfg, nil
+}
+
+// respondUploadError отвечает на ошибку readUpload / storeMedia
+func respondUploadError(c *gin.Context, err error) {
+    switch err {
+    case errMediaTooLarge:
+        c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+    case errMediaType:
+        c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+    case errNoStorage:
+        c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+    default:
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    }
+}
+
+// storeMedia обрабатывает картинку (см. processImage), загружает её и варианты
+// в хранилище и записывает в media. Повторная загрузка того же файла в
+// пространство возвращает существующую запись без повторной обработки.
+func storeMedia(db sqlx.Ext, store storage.Backend, m Media, data []byte) (Media, error) {
+    if store == nil {
+        return m, errNoStorage
+    }
+    source := sha256.Sum256(data)
+    m.SourceSHA256 = hex.EncodeToString(source[:])
+
+    var existing []Media
+    err := sqlx.Select(db, &existing, "SELECT * FROM media WHERE workspace_id=$1 AND source_sha256=$2 AND kind=$3 LIMIT 1",
+        m.WorkspaceID, m.SourceSHA256, m.Kind)
+    if err != nil {
+        return m, err
+    }
+    if len(existing) > 0 {
+        err := loadVariants(db, existing)
+        return existing[0], err
+    }
+
+    img, err := processImage(m.Kind, data, m.ContentType)
+    if err != nil {
+        return m, err
+    }
+    sum := sha256.Sum256(img.Data)
+    m.SHA256 = hex.EncodeToString(sum[:])
+    m.ObjectKey = contentKey("media", img.Data, mediaExtensions[img.ContentType])
+    m.ContentType, m.Size, m.Width, m.Height = img.ContentType, len(img.Data), img.Width, img.Height
+
+    if m.URL, err = store.Put(m.ObjectKey, img.Data, img.ContentType); err != nil {
+        log.Printf("media upload %s error: %v", m.ObjectKey, err)
+        return m, err
+    }
+    variants := make([]MediaVariant, len(img.Variants))
+    for i, v := range img.Variants {
+        key := variantKey(m.ObjectKey, v.Width, v.Format)
+        u, err := store.Put(key, v.Data, "image/"+v.Format)
+        if err != nil {
+            log.Printf("media upload %s error: %v", key, err)
+            return m, err
+        }
+        variants[i] = MediaVariant{Format: v.Format, Width: v.Width, Height: v.Height, ObjectKey: key, URL: u, Size: len(v.Data)}
+    }
+
+    var item Media
+    query := `INSERT INTO media (workspace_id, landing_id, uploaded_by, kind, object_key, url, content_type, size, width, height, sha256, source_sha256)
+              VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+              ON CONFLICT (workspace_id, sha256) DO UPDATE SET workspace_id = media.workspace_id
+              RETURNING *`
+    err = sqlx.Get(db, &item, query, m.WorkspaceID, m.LandingID, m.UploadedBy, m.Kind, m.ObjectKey, m.URL,
+        m.ContentType, m.Size, m.Width, m.Height, m.SHA256, m.SourceSHA256)
+    if err != nil {
+        return item, err
+    }
+    for _, v := range variants {
+        query := `INSERT INTO media_variants (media_id, format, width, height, object_key, url, size)
+                  VALUES ($1,$2,$3,$4,$5,$6,$7) ON CONFLICT DO NOTHING`
+        if _, err := db.Exec(query, item.ID, v.Format, v.Width, v.Height, v.ObjectKey, v.URL, v.Size); err != nil {
+            return item, err
+        }
+    }
+    items := []Media{item}
+    err = loadVariants(db, items)
+    return items[0], err
+}
+
+// uploadMedia принимает картинку для блоков. Файл относится к лендингу
+// (поле landingId) или к пространству (workspaceId, по умолчанию личное).
+func uploadMedia(db *sqlx.DB, store storage.Backend) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+        data, contentType, cfg, err := readUpload(c, MediaImage)
+        if err != nil {
+            respondUploadError(c, err)
+            return
+        }
+
+        m := Media{Kind: MediaImage, UploadedBy: &uid, ContentType: contentType, Width: cfg.Width, Height: cfg.Height}
+        if raw := c.PostForm("landingId"); raw != "" {
+            landingID, err := strconv.Atoi(raw)
+            if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "invalid landingId"})
+                return
+            }
+            if !authorizeLanding(c, db, landingID, RoleEditor) {
+                return
+            }
+            if err := db.Get(&m.WorkspaceID, "SELECT workspace_id FROM landings WHERE id=$1", landingID); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                return
+            }
+            m.LandingID = &landingID
+        } else {
+            if raw := c.PostForm("workspaceId"); raw != "" {
+                if m.WorkspaceID, err = strconv.Atoi(raw); err != nil {
+                    c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workspaceId"})
+                    return
+                }
+            } else if m.WorkspaceID, err = personalWorkspaceID(db, uid); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                return
+            }
+            if !authorizeWorkspace(c, db, m.WorkspaceID, RoleEditor) {
+                return
+            }
+        }
+
+        item, err := storeMedia(db, store, m, data)
+        if err != nil {
+            respondUploadError(c, err)
+            return
+        }
+        c.JSON(http.StatusCreated, item)
+    }
+}
+
+// uploadAvatar загружает аватар лендинга и сразу ставит его в avatar_url черновика
+func uploadAvatar(db *sqlx.DB, store storage.Backend) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+        data, contentType, cfg, err := readUpload(c, MediaAvatar)
+        if err != nil {
+            respondUploadError(c, err)
+            return
+        }
+
+        landingID := c.GetInt(ctxLandingID)
+        m := Media{Kind: MediaAvatar, LandingID: &landingID, UploadedBy: &uid,
+            ContentType: contentType, Width: cfg.Width, Height: cfg.Height}
+        if err := db.Get(&m.WorkspaceID, "SELECT workspace_id FROM landings WHERE id=$1", landingID); err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+        item, err := storeMedia(db, store, m, data)
+        if err != nil {
+            respondUploadError(c, err)
+            return
+        }
+
+        var landing Landing
+        query := `UPDATE landings SET avatar_url=$1, updated_at=NOW() WHERE id=$2 RETURNING *`
+        if err := db.Get(&landing, query, item.URL, landingID); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusCreated, gin.H{"media": item, "landing": landing})
+    }
+}
+
+// listMedia возвращает файлы пространства (?workspaceId=, по умолчанию личное)
+// или одного лендинга (?landingId=)
+func listMedia(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+        items := []Media{}
+        if raw := c.Query("landingId"); raw != "" {
+            landingID, err := strconv.Atoi(raw)
+            if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "invalid landingId"})
+                return
+            }
+            if !authorizeLanding(c, db, landingID, RoleViewer) {
+                return
+            }
+            if err := db.Select(&items, "SELECT * FROM media WHERE landing_id=$1 ORDER BY created_at DESC", landingID); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                return
+            }
+            if err := loadVariants(db, items); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                return
+            }
+            c.JSON(http.StatusOK, items)
+            return
+        }
+
+        var workspaceID int
+        var err error
+        if raw := c.Query("workspaceId"); raw != "" {
+            if workspaceID, err = strconv.Atoi(raw); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workspaceId"})
+                return
+            }
+        } else if workspaceID, err = personalWorkspaceID(db, uid); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if !authorizeWorkspace(c, db, workspaceID, RoleViewer) {
+            return
+        }
+        if err := db.Select(&items, "SELECT * FROM media WHERE workspace_id=$1 ORDER BY created_at DESC", workspaceID); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if err := loadVariants(db, items); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusOK, items)
+    }
+}
+
+// mediaInUse сообщает, что адрес файла встречается в лендингах: в аватаре,
+// блоках или теме черновика либо в любой ревизии (опубликованной или из
+// истории, откуда её можно восстановить)
+func mediaInUse(q sqlx.Queryer, url string) (bool, error) {
+    var inUse bool
+    query := `SELECT EXISTS (
+                  SELECT 1 FROM landings
+                  WHERE avatar_url = $1 OR strpos(blocks::text, $1) > 0 OR strpos(theme::text, $1) > 0
+              ) OR EXISTS (
+                  SELECT 1 FROM landing_revisions WHERE strpos(snapshot::text, $1) > 0
+              )`
+    err := sqlx.Get(q, &inUse, query, url)
+    return inUse, err
+}
+
+// deleteMedia удаляет запись о файле. Файл, который ещё используют лендинги
+// (см. mediaInUse), не удаляется — 409. Сам объект удаляется из хранилища,
+// только если на него не ссылаются записи других пространств.
+func deleteMedia(db *sqlx.DB, store storage.Backend) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        id, err := strconv.Atoi(c.Param("id"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+            return
+        }
+        var item Media
+        if err := db.Get(&item, "SELECT * FROM media WHERE id=$1", id); err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+        if !authorizeWorkspace(c, db, item.WorkspaceID, RoleEditor) {
+            return
+        }
+        inUse, err := mediaInUse(db, item.URL)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if inUse {
+            c.JSON(http.StatusConflict, gin.H{"error": "media is in use"})
+            return
+        }
+        var variantKeys []string
+        if err := db.Select(&variantKeys, "SELECT object_key FROM media_variants WHERE media_id=$1", id); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+
+        var remaining int
+        query := `WITH deleted AS (DELETE FROM media WHERE id=$1 RETURNING object_key)
+                  SELECT COUNT(*) FROM media WHERE object_key=(SELECT object_key FROM deleted) AND id<>$1`
+        if err := db.Get(&remaining, query, id); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        // ключи вариантов выводятся из ключа файла, поэтому удаляются вместе с ним
+        if remaining == 0 && store != nil {
+            for _, key := range append([]string{item.ObjectKey}, variantKeys...) {
+                if err := store.Delete(key); err != nil {
+                    log.Printf("media %d delete object %s error: %v", id, key, err)
+                }
+            }
+        }
+        c.Status(http.StatusNoContent)
+    }
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+    "net/http"
+    "regexp"
+    "testing"
+
+    "github.com/DATA-DOG/go-sqlmock"
+    "github.com/gin-gonic/gin"
+)
+
+func TestDeleteMediaInUse(t *testing.T) {
+    db, mock := newMockDB(t)
+    const url = "https://cdn.example.com/media/abc.png"
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM media WHERE id=$1")).
+        WithArgs(3).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id", "object_key", "url"}).
+            AddRow(3, 1, "media/abc.png", url))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT role FROM workspace_members")).
+        WithArgs(1, 1).
+        WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(RoleEditor))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT 1 FROM landing_revisions")).
+        WithArgs(url).
+        WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+    router := newTestRouter(1, func(api *gin.RouterGroup) { RegisterMediaRoutes(api, db, nil) })
+    w := doRequest(router, http.MethodDelete, "/api/media/3", "")
+    if w.Code != http.StatusConflict {
+        t.Errorf("status = %d, want 409, body = %s", w.Code, w.Body)
+    }
+}
@@ -25,6 +25,7 @@ type Payment struct {
 // RegisterPaymentRoutes регистрирует маршруты для платежей
 func RegisterPaymentRoutes(rg *gin.RouterGroup, db *sqlx.DB) {
     r := rg.Group("/payments")
+    r.Use(requireScope(ScopePaymentsRead))
     r.GET("", listPayments(db))
     r.GET("/:id", getPayment(db))
 }
@@ -0,0 +1,404 @@
+package handler
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "database/sql"
+    "embed"
+    "encoding/hex"
+    "fmt"
+    "html/template"
+    "log"
+    "math"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+    "github.com/lib/pq"
+
+    "github.com/blagoweb/bbtg/internal/telegram"
+)
+
+//go:embed templates/*.html templates/icons/*.svg
+var templateFS embed.FS
+
+var landingTemplate = template.Must(template.ParseFS(templateFS, "templates/landing.html"))
+
+// publicCacheControl — сколько браузеры и CDN могут держать страницу у себя
+const publicCacheControl = "public, max-age=60, stale-while-revalidate=300"
+
+// publicPage — данные, которые передаются в шаблон публичной страницы
+type publicPage struct {
+    Landing      Landing
+    Blocks       []renderBlock
+    Links        []renderLink
+    ThemeCSS     template.CSS // CSS custom properties темы
+    FontsURL     string       // стили Google Fonts, если тема их использует
+    CanonicalURL string
+    SentBlockID  string // id формы, заявка из которой только что отправлена
+    AvatarSrcSet srcSet // уменьшенные копии аватара, если он загружен через media
+    TrackClicks  bool   // ссылки ведут через /r/:linkId (не в предпросмотре)
+}
+
+// renderBlock — блок с уже разобранными data для шаблона
+type renderBlock struct {
+    ID       string
+    Type     string
+    Data     interface{}
+    EmbedURL string // video_embed и map: адрес для iframe
+    SrcSet   srcSet // image: уменьшенные копии картинки
+}
+
+// renderLink — ссылка с оформлением своего типа для шаблона
+type renderLink struct {
+    Link
+    IconSVG template.HTML // иконка типа в фирменном цвете, встраивается в страницу
+}
+
+// RegisterPublicRoutes регистрирует публичные (без авторизации) маршруты для посетителей
+func RegisterPublicRoutes(rg *gin.RouterGroup, db *sqlx.DB, bot *telegram.Bot, clicks *ClickRecorder) {
+    rg.GET("/p/:slug", renderLanding(db))
+    rg.GET("/r/:linkId", redirectLink(db, clicks))
+    rg.POST("/p/:slug/leads", submitPublicLead(db, bot))
+}
+
+// renderLanding отдаёт опубликованную ревизию лендинга в виде готовой HTML-страницы
+func renderLanding(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        slug := normalizeSlug(c.Param("slug"))
+
+        landing, rev, err := publishedLanding(db, slug)
+        if err == sql.ErrNoRows {
+            // slug мог быть переименован — отправляем на актуальный адрес
+            var current string
+            query := `SELECT l.slug FROM landing_slug_history h
+                      JOIN landings l ON l.id = h.landing_id
+                      WHERE h.slug=$1 AND l.published_revision_id IS NOT NULL`
+            if err := db.Get(&current, query, slug); err != nil {
+                c.String(http.StatusNotFound, "not found")
+                return
+            }
+            target := "/p/" + current
+            if q := c.Request.URL.RawQuery; q != "" {
+                // сохраняем UTM-метки и прочие параметры исходной ссылки
+                target += "?" + q
+            }
+            c.Redirect(http.StatusMovedPermanently, target)
+            return
+        }
+        if err != nil {
+            log.Printf("public landing %q load error: %v", slug, err)
+            c.String(http.StatusInternalServerError, "internal error")
+            return
+        }
+
+        snap := rev.Snapshot
+        if snap.Links, err = visibleLinks(db, snap.Links, time.Now()); err != nil {
+            log.Printf("public landing %q links error: %v", slug, err)
+            c.String(http.StatusInternalServerError, "internal error")
+            return
+        }
+
+        // содержимое ревизии неизменно, поэтому ETag зависит от её id
+        // и от набора ссылок, видимых прямо сейчас
+        sent := c.Query("sent")
+        etag := landingETag(landing.ID, rev.ID, sent+"|"+linkIDs(snap.Links))
+
+        c.Header("Cache-Control", publicCacheControl)
+        c.Header("ETag", etag)
+        c.Header("Last-Modified", rev.CreatedAt.UTC().Format(http.TimeFormat))
+        if match := c.GetHeader("If-None-Match"); match != "" && match == etag {
+            c.Status(http.StatusNotModified)
+            return
+        }
+
+        page := snapshotPage(landing, snap)
+        attachSrcSets(db, &page)
+        page.CanonicalURL = publicURL(c, c.Request.URL.Path)
+        page.SentBlockID = sent
+        page.TrackClicks = true
+        writePage(c, page)
+    }
+}
+
+// previewLanding показывает черновик так, как он будет выглядеть после публикации
+func previewLanding(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var landing Landing
+        if err := db.Get(&landing, "SELECT * FROM landings WHERE id=$1", c.GetInt(ctxLandingID)); err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+        snap, err := captureSnapshot(db, landing)
+        if err == nil {
+            snap.Links, err = visibleLinks(db, snap.Links, time.Now())
+        }
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        page := snapshotPage(landing, snap)
+        attachSrcSets(db, &page)
+        page.CanonicalURL = publicURL(c, "/p/"+landing.Slug)
+        c.Header("Cache-Control", "no-store")
+        c.Header("X-Robots-Tag", "noindex")
+        writePage(c, page)
+    }
+}
+
+// publishedLanding находит лендинг по slug вместе с опубликованной ревизией.
+// Неопубликованный лендинг для посетителей не существует: sql.ErrNoRows.
+func publishedLanding(db *sqlx.DB, slug string) (Landing, Revision, error) {
+    var landing Landing
+    var rev Revision
+    if err := db.Get(&landing, "SELECT * FROM landings WHERE LOWER(slug)=$1", slug); err != nil {
+        return landing, rev, err
+    }
+    // не ждём планировщик: истёкший лендинг скрываем сразу
+    expired := landing.UnpublishAt != nil && !landing.UnpublishAt.After(time.Now())
+    if landing.PublishedRevisionID == nil || expired {
+        return landing, rev, sql.ErrNoRows
+    }
+    err := db.Get(&rev, "SELECT * FROM landing_revisions WHERE id=$1", *landing.PublishedRevisionID)
+    return landing, rev, err
+}
+
+// visibleLinks оставляет ссылки, которые посетитель должен видеть в момент
+// now: без ещё не начавшихся и истёкших, без исчерпанных (если им некуда
+// вести). Счётчики кликов берутся из links, в снимке их нет.
+func visibleLinks(q sqlx.Queryer, links []Link, now time.Time) ([]Link, error) {
+    var capped []int64
+    for _, l := range links {
+        if l.MaxClicks != nil {
+            capped = append(capped, int64(l.ID))
+        }
+    }
+    counts := map[int]int{}
+    if len(capped) > 0 {
+        var rows []struct {
+            ID         int `db:"id"`
+            ClickCount int `db:"click_count"`
+        }
+        if err := sqlx.Select(q, &rows, "SELECT id, click_count FROM links WHERE id = ANY($1)", pq.Int64Array(capped)); err != nil {
+            return nil, err
+        }
+        for _, r := range rows {
+            counts[r.ID] = r.ClickCount
+        }
+    }
+
+    out := make([]Link, 0, len(links))
+    for _, l := range links {
+        l.ClickCount = counts[l.ID]
+        switch linkState(l, now) {
+        case LinkUpcoming, LinkExpired:
+            continue
+        case LinkExhausted:
+            if l.FallbackURL == "" {
+                continue
+            }
+        }
+        out = append(out, l)
+    }
+    return out, nil
+}
+
+// linkIcons — SVG иконок типов ссылок (templates/icons), уже окрашенные в
+// фирменный цвет. Иконки встраиваются в страницу, а не грузятся со сторонних
+// CDN, чтобы не раскрывать им IP посетителей.
+var linkIcons = loadLinkIcons()
+
+func loadLinkIcons() map[string]template.HTML {
+    icons := map[string]template.HTML{}
+    for _, t := range linkTypes {
+        if t.Icon == "" {
+            continue
+        }
+        svg, err := templateFS.ReadFile("templates/icons/" + t.Icon + ".svg")
+        if err != nil {
+            panic(err)
+        }
+        colored := strings.Replace(strings.TrimSpace(string(svg)), "<svg ", `<svg fill="#`+t.Color+`" aria-hidden="true" `, 1)
+        icons[t.Icon] = template.HTML(colored)
+    }
+    return icons
+}
+
+// renderLinks подставляет оформление по типу ссылки
+func renderLinks(links []Link) []renderLink {
+    out := make([]renderLink, len(links))
+    for i, l := range links {
+        out[i] = renderLink{Link: l, IconSVG: linkIcons[lookupLinkType(l.Type).Icon]}
+    }
+    return out
+}
+
+// linkIDs перечисляет id ссылок через запятую (для ETag)
+func linkIDs(links []Link) string {
+    ids := make([]string, len(links))
+    for i, l := range links {
+        ids[i] = strconv.Itoa(l.ID)
+    }
+    return strings.Join(ids, ",")
+}
+
+// snapshotPage собирает данные шаблона из снимка содержимого
+func snapshotPage(landing Landing, snap LandingSnapshot) publicPage {
+    landing.Title = snap.Title
+    landing.Description = snap.Description
+    landing.AvatarURL = snap.AvatarURL
+    landing.Blocks = snap.Blocks
+    landing.Theme = snap.Theme
+    return publicPage{
+        Landing:  landing,
+        Blocks:   renderBlocks(snap.Blocks),
+        Links:    renderLinks(snap.Links),
+        ThemeCSS: themeCSS(snap.Theme),
+        FontsURL: themeFontsURL(snap.Theme),
+    }
+}
+
+// attachSrcSets подставляет srcset для аватара и картинок страницы. Без
+// вариантов (внешний URL, GIF, ошибка БД) картинка выводится как есть.
+func attachSrcSets(q sqlx.Queryer, page *publicPage) {
+    var urls []string
+    if page.Landing.AvatarURL != "" {
+        urls = append(urls, page.Landing.AvatarURL)
+    }
+    for _, b := range page.Blocks {
+        if d, ok := b.Data.(imageBlock); ok {
+            urls = append(urls, d.URL)
+        }
+    }
+    sets, err := loadSrcSets(q, urls)
+    if err != nil {
+        log.Printf("public landing %d srcset error: %v", page.Landing.ID, err)
+        return
+    }
+    page.AvatarSrcSet = sets[page.Landing.AvatarURL]
+    for i, b := range page.Blocks {
+        if d, ok := b.Data.(imageBlock); ok {
+            page.Blocks[i].SrcSet = sets[d.URL]
+        }
+    }
+}
+
+// writePage рендерит шаблон лендинга в ответ
+func writePage(c *gin.Context, page publicPage) {
+    var buf bytes.Buffer
+    if err := landingTemplate.Execute(&buf, page); err != nil {
+        log.Printf("public landing %d render error: %v", page.Landing.ID, err)
+        c.String(http.StatusInternalServerError, "internal error")
+        return
+    }
+    c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// landingETag строит слабый ETag по лендингу, ревизии и варианту страницы
+func landingETag(landingID, revisionID int, variant string) string {
+    h := sha256.New()
+    fmt.Fprintf(h, "%d:%d:%s", landingID, revisionID, variant)
+    return `W/"` + hex.EncodeToString(h.Sum(nil))[:16] + `"`
+}
+
+// renderBlocks разбирает data блоков в типизированные структуры для шаблона.
+// Блоки, не прошедшие схему (например, после её ужесточения), пропускаются.
+func renderBlocks(blocks Blocks) []renderBlock {
+    out := make([]renderBlock, 0, len(blocks))
+    for _, b := range blocks {
+        schema, ok := blockSchemas[b.Type]
+        if !ok {
+            continue
+        }
+        data, errs := schema(b.Data)
+        if len(errs) > 0 {
+            continue
+        }
+        rb := renderBlock{ID: b.ID, Type: b.Type, Data: data}
+        switch d := data.(type) {
+        case videoBlock:
+            rb.EmbedURL, _ = videoEmbedURL(d.URL)
+        case mapBlock:
+            rb.EmbedURL = mapEmbedURL(d)
+        }
+        out = append(out, rb)
+    }
+    return out
+}
+
+// mapEmbedURL строит адрес встраиваемой карты OpenStreetMap с маркером
+func mapEmbedURL(m mapBlock) string {
+    // размер области примерно соответствует масштабу zoom
+    delta := 360 / math.Pow(2, float64(m.Zoom)) / 2
+    return fmt.Sprintf("https://www.openstreetmap.org/export/embed.html?bbox=%f%%2C%f%%2C%f%%2C%f&layer=mapnik&marker=%f%%2C%f",
+        m.Lng-delta, m.Lat-delta/2, m.Lng+delta, m.Lat+delta/2, m.Lat, m.Lng)
+}
+
+// leadFieldLimits — максимальная длина полей заявки в символах (по схеме leads)
+var leadFieldLimits = map[string]int{"name": 255, "email": 255, "phone": 50, "message": 2000}
+
+// submitPublicLead принимает заявку из блока lead_form на публичной странице.
+// Работает без JavaScript: после сохранения редиректит обратно на страницу.
+func submitPublicLead(db *sqlx.DB, bot *telegram.Bot) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        // принимаем заявки только из опубликованных форм
+        landing, rev, err := publishedLanding(db, normalizeSlug(c.Param("slug")))
+        if err != nil {
+            c.String(http.StatusNotFound, "not found")
+            return
+        }
+
+        blockID := c.PostForm("block")
+        var form *leadFormBlock
+        for _, rb := range renderBlocks(rev.Snapshot.Blocks) {
+            if d, ok := rb.Data.(leadFormBlock); ok && rb.ID == blockID {
+                form = &d
+                break
+            }
+        }
+        if form == nil {
+            c.String(http.StatusBadRequest, "form not found")
+            return
+        }
+
+        back := "/p/" + landing.Slug + "?sent=" + url.QueryEscape(blockID) + "#block-" + url.QueryEscape(blockID)
+        // скрытое поле-ловушка: люди его не видят, боты заполняют
+        if c.PostForm("website") != "" {
+            c.Redirect(http.StatusSeeOther, back)
+            return
+        }
+
+        lead := Lead{LandingID: landing.ID}
+        values := map[string]*string{"name": &lead.Name, "email": &lead.Email, "phone": &lead.Phone, "message": &lead.Message}
+        filled := false
+        for _, f := range form.Fields {
+            v := truncate(strings.TrimSpace(c.PostForm(f)), leadFieldLimits[f])
+            *values[f] = v
+            filled = filled || v != ""
+        }
+        if !filled {
+            c.Redirect(http.StatusSeeOther, "/p/"+landing.Slug+"#block-"+url.QueryEscape(blockID))
+            return
+        }
+
+        if _, err := saveLead(db, bot, lead); err != nil {
+            log.Printf("public lead for landing %d error: %v", landing.ID, err)
+            c.String(http.StatusInternalServerError, "internal error")
+            return
+        }
+        c.Redirect(http.StatusSeeOther, back)
+    }
+}
+
+// publicURL собирает абсолютный URL с учётом прокси (X-Forwarded-Proto)
+func publicURL(c *gin.Context, path string) string {
+    scheme := "http"
+    if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+        scheme = "https"
+    }
+    return fmt.Sprintf("%s://%s%s", scheme, c.Request.Host, path)
+}
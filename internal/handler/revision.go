@@ -0,0 +1,464 @@
+package handler
+
+import (
+    "bytes"
+    "database/sql"
+    "database/sql/driver"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "sort"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+    "github.com/lib/pq"
+)
+
+// LandingSnapshot — содержимое лендинга на момент публикации.
+// Черновиком служит сама строка landings вместе с таблицей links.
+type LandingSnapshot struct {
+    Title       string `json:"title"`
+    Description string `json:"description"`
+    AvatarURL   string `json:"avatarUrl"`
+    Blocks      Blocks `json:"blocks"`
+    Theme       Theme  `json:"theme"`
+    Links       []Link `json:"links"`
+}
+
+// Value сохраняет снимок в JSONB
+func (s LandingSnapshot) Value() (driver.Value, error) {
+    return json.Marshal(s)
+}
+
+// Scan читает снимок из JSONB
+func (s *LandingSnapshot) Scan(src interface{}) error {
+    var data []byte
+    switch v := src.(type) {
+    case []byte:
+        data = v
+    case string:
+        data = []byte(v)
+    default:
+        return fmt.Errorf("snapshot: unsupported type %T", src)
+    }
+    return json.Unmarshal(data, s)
+}
+
+// Revision — опубликованная версия лендинга
+type Revision struct {
+    ID        int             `db:"id" json:"id"`
+    LandingID int             `db:"landing_id" json:"landingId"`
+    Number    int             `db:"number" json:"number"`
+    Snapshot  LandingSnapshot `db:"snapshot" json:"snapshot"`
+    Note      string          `db:"note" json:"note"`
+    CreatedBy *int            `db:"created_by" json:"createdBy"`
+    CreatedAt time.Time       `db:"created_at" json:"createdAt"`
+}
+
+// registerRevisionRoutes регистрирует публикацию и историю ревизий внутри /landings/:id
+func registerRevisionRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+    read, write := requireScope(ScopeLandingsRead), requireScope(ScopeLandingsWrite)
+    r.POST("/:id/publish", write, requireLandingRole(db, "id", RoleEditor), publishLanding(db))
+    r.POST("/:id/unpublish", write, requireLandingRole(db, "id", RoleEditor), unpublishLanding(db))
+    r.PUT("/:id/schedule", write, requireLandingRole(db, "id", RoleEditor), scheduleLanding(db))
+    r.GET("/:id/revisions", read, requireLandingRole(db, "id", RoleViewer), listRevisions(db))
+    r.GET("/:id/revisions/:revisionId", read, requireLandingRole(db, "id", RoleViewer), getRevision(db))
+    r.GET("/:id/revisions/:revisionId/diff", read, requireLandingRole(db, "id", RoleViewer), diffRevision(db))
+    r.POST("/:id/revisions/:revisionId/restore", write, requireLandingRole(db, "id", RoleEditor), restoreRevision(db))
+}
+
+// captureSnapshot снимает текущее (черновое) содержимое лендинга
+func captureSnapshot(q sqlx.Queryer, landing Landing) (LandingSnapshot, error) {
+    snap := LandingSnapshot{
+        Title:       landing.Title,
+        Description: landing.Description,
+        AvatarURL:   landing.AvatarURL,
+        Blocks:      landing.Blocks,
+        Theme:       landing.Theme,
+        Links:       []Link{},
+    }
+    if snap.Blocks == nil {
+        snap.Blocks = Blocks{}
+    }
+    err := sqlx.Select(q, &snap.Links, "SELECT * FROM links WHERE landing_id=$1 ORDER BY position, id", landing.ID)
+    // счётчик кликов живёт в links и в ревизию не попадает
+    for i := range snap.Links {
+        snap.Links[i].ClickCount = 0
+    }
+    return snap, err
+}
+
+// publishRevision сохраняет снимок новой ревизией и делает её опубликованной.
+// Вызывается внутри транзакции, строка лендинга должна быть заблокирована.
+func publishRevision(tx *sqlx.Tx, landing Landing, note string, userID *int) (Revision, error) {
+    var rev Revision
+    snap, err := captureSnapshot(tx, landing)
+    if err != nil {
+        return rev, err
+    }
+    query := `INSERT INTO landing_revisions (landing_id, number, snapshot, note, created_by)
+              VALUES ($1, (SELECT COALESCE(MAX(number), 0) + 1 FROM landing_revisions WHERE landing_id=$1), $2, $3, $4)
+              RETURNING *`
+    if err := tx.Get(&rev, query, landing.ID, snap, note, userID); err != nil {
+        return rev, err
+    }
+    _, err = tx.Exec("UPDATE landings SET published_revision_id=$1, published_at=$2 WHERE id=$3",
+        rev.ID, rev.CreatedAt, landing.ID)
+    return rev, err
+}
+
+// publishLanding публикует текущий черновик
+func publishLanding(db *sqlx.DB) gin.HandlerFunc {
+    type request struct {
+        Note string `json:"note"`
+    }
+    return func(c *gin.Context) {
+        var req request
+        if c.Request.ContentLength > 0 {
+            if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+            }
+        }
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+
+        tx, err := db.Beginx()
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        defer tx.Rollback()
+
+        var landing Landing
+        if err := tx.Get(&landing, "SELECT * FROM landings WHERE id=$1 FOR UPDATE", c.GetInt(ctxLandingID)); err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+        // блоки могли стать невалидными после ужесточения схем — такое не публикуем
+        if errs := validateBlocks(landing.Blocks); errs != nil {
+            respondFieldErrors(c, errs)
+            return
+        }
+        rev, err := publishRevision(tx, landing, req.Note, &uid)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if err := tx.Commit(); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusCreated, rev)
+    }
+}
+
+// unpublishLanding снимает лендинг с публикации; история ревизий сохраняется
+func unpublishLanding(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var item Landing
+        query := `UPDATE landings SET published_revision_id=NULL, published_at=NULL WHERE id=$1 RETURNING *`
+        if err := db.Get(&item, query, c.GetInt(ctxLandingID)); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusOK, item)
+    }
+}
+
+// listRevisions возвращает историю публикаций без снимков, от новых к старым
+func listRevisions(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        items := []Revision{}
+        query := `SELECT id, landing_id, number, note, created_by, created_at
+                  FROM landing_revisions WHERE landing_id=$1 ORDER BY number DESC`
+        if err := db.Select(&items, query, c.GetInt(ctxLandingID)); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusOK, items)
+    }
+}
+
+// loadRevision читает ревизию лендинга по параметру :revisionId
+func loadRevision(c *gin.Context, db sqlx.Queryer) (Revision, bool) {
+    var rev Revision
+    id, err := strconv.Atoi(c.Param("revisionId"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid revisionId"})
+        return rev, false
+    }
+    err = sqlx.Get(db, &rev, "SELECT * FROM landing_revisions WHERE id=$1 AND landing_id=$2", id, c.GetInt(ctxLandingID))
+    if err == sql.ErrNoRows {
+        c.JSON(http.StatusNotFound, gin.H{"error": "revision not found"})
+        return rev, false
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return rev, false
+    }
+    return rev, true
+}
+
+// getRevision возвращает ревизию вместе со снимком
+func getRevision(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if rev, ok := loadRevision(c, db); ok {
+            c.JSON(http.StatusOK, rev)
+        }
+    }
+}
+
+// snapshotDiff — отличия между двумя снимками
+type snapshotDiff struct {
+    From   string                 `json:"from"`
+    To     string                 `json:"to"`
+    Fields map[string]fieldChange `json:"fields"`
+    Blocks itemsDiff              `json:"blocks"`
+    Links  itemsDiff              `json:"links"`
+}
+
+type fieldChange struct {
+    From interface{} `json:"from"`
+    To   interface{} `json:"to"`
+}
+
+// itemsDiff сравнивает упорядоченные списки элементов по их id
+type itemsDiff struct {
+    Added     []interface{} `json:"added"`
+    Removed   []interface{} `json:"removed"`
+    Changed   []itemChange  `json:"changed"`
+    Reordered bool          `json:"reordered"`
+}
+
+type itemChange struct {
+    ID   string      `json:"id"`
+    From interface{} `json:"from"`
+    To   interface{} `json:"to"`
+}
+
+// diffRevision сравнивает ревизию с черновиком (по умолчанию) или с другой
+// ревизией: ?against=draft|<revisionId>
+func diffRevision(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        rev, ok := loadRevision(c, db)
+        if !ok {
+            return
+        }
+
+        against := c.DefaultQuery("against", "draft")
+        var other LandingSnapshot
+        if against == "draft" {
+            var landing Landing
+            if err := db.Get(&landing, "SELECT * FROM landings WHERE id=$1", rev.LandingID); err != nil {
+                c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+                return
+            }
+            snap, err := captureSnapshot(db, landing)
+            if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                return
+            }
+            other = snap
+        } else {
+            otherID, err := strconv.Atoi(against)
+            if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "against must be draft or a revision id"})
+                return
+            }
+            var o Revision
+            err = db.Get(&o, "SELECT * FROM landing_revisions WHERE id=$1 AND landing_id=$2", otherID, rev.LandingID)
+            if err != nil {
+                c.JSON(http.StatusNotFound, gin.H{"error": "revision not found"})
+                return
+            }
+            other = o.Snapshot
+            against = "revision:" + strconv.Itoa(o.ID)
+        }
+
+        diff := diffSnapshots(rev.Snapshot, other)
+        diff.From, diff.To = "revision:"+strconv.Itoa(rev.ID), against
+        c.JSON(http.StatusOK, diff)
+    }
+}
+
+// diffSnapshots вычисляет, что изменилось при переходе от a к b
+func diffSnapshots(a, b LandingSnapshot) snapshotDiff {
+    d := snapshotDiff{Fields: map[string]fieldChange{}}
+    for _, f := range []struct {
+        name string
+        x, y string
+    }{
+        {"title", a.Title, b.Title},
+        {"description", a.Description, b.Description},
+        {"avatarUrl", a.AvatarURL, b.AvatarURL},
+    } {
+        if f.x != f.y {
+            d.Fields[f.name] = fieldChange{From: f.x, To: f.y}
+        }
+    }
+    if !sameJSON(a.Theme, b.Theme) {
+        d.Fields["theme"] = fieldChange{From: a.Theme, To: b.Theme}
+    }
+
+    blockKeys := func(bs Blocks) ([]string, map[string]interface{}) {
+        keys, items := make([]string, len(bs)), map[string]interface{}{}
+        for i, b := range bs {
+            keys[i], items[b.ID] = b.ID, b
+        }
+        return keys, items
+    }
+    ak, ai := blockKeys(a.Blocks)
+    bk, bi := blockKeys(b.Blocks)
+    d.Blocks = diffItems(ak, ai, bk, bi, sameJSON)
+
+    // position и отметки времени меняются при любом перемещении — их не сравниваем
+    linkKeys := func(ls []Link) ([]string, map[string]interface{}) {
+        sorted := append([]Link(nil), ls...)
+        sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Position < sorted[j].Position })
+        keys, items := make([]string, len(sorted)), map[string]interface{}{}
+        for i, l := range sorted {
+            key := strconv.Itoa(l.ID)
+            keys[i] = key
+            items[key] = l
+        }
+        return keys, items
+    }
+    ak, ai = linkKeys(a.Links)
+    bk, bi = linkKeys(b.Links)
+    d.Links = diffItems(ak, ai, bk, bi, func(x, y interface{}) bool {
+        lx, ly := x.(Link), y.(Link)
+        return lx.Type == ly.Type && lx.Title == ly.Title && lx.URL == ly.URL &&
+            sameJSON(lx.VisibleFrom, ly.VisibleFrom) && sameJSON(lx.VisibleUntil, ly.VisibleUntil) &&
+            sameJSON(lx.MaxClicks, ly.MaxClicks) && lx.FallbackURL == ly.FallbackURL &&
+            sameJSON(lx.Rules, ly.Rules)
+    })
+    return d
+}
+
+func sameJSON(x, y interface{}) bool {
+    a, _ := json.Marshal(x)
+    b, _ := json.Marshal(y)
+    return bytes.Equal(a, b)
+}
+
+// diffItems сравнивает два упорядоченных набора элементов по ключам
+func diffItems(aKeys []string, a map[string]interface{}, bKeys []string, b map[string]interface{}, same func(x, y interface{}) bool) itemsDiff {
+    d := itemsDiff{Added: []interface{}{}, Removed: []interface{}{}, Changed: []itemChange{}}
+    var aCommon, bCommon []string
+    for _, k := range aKeys {
+        if _, ok := b[k]; !ok {
+            d.Removed = append(d.Removed, a[k])
+            continue
+        }
+        aCommon = append(aCommon, k)
+        if !same(a[k], b[k]) {
+            d.Changed = append(d.Changed, itemChange{ID: k, From: a[k], To: b[k]})
+        }
+    }
+    for _, k := range bKeys {
+        if _, ok := a[k]; !ok {
+            d.Added = append(d.Added, b[k])
+            continue
+        }
+        bCommon = append(bCommon, k)
+    }
+    for i := range aCommon {
+        if aCommon[i] != bCommon[i] {
+            d.Reordered = true
+            break
+        }
+    }
+    return d
+}
+
+// restoreRevision возвращает содержимое ревизии в черновик. Публикация не
+// меняется: восстановленный черновик нужно опубликовать отдельно.
+func restoreRevision(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        tx, err := db.Beginx()
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        defer tx.Rollback()
+
+        var current Landing
+        if err := tx.Get(&current, "SELECT * FROM landings WHERE id=$1 FOR UPDATE", c.GetInt(ctxLandingID)); err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+        rev, ok := loadRevision(c, tx)
+        if !ok {
+            return
+        }
+
+        item, err := restoreSnapshot(tx, current.ID, rev.Snapshot)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if err := tx.Commit(); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusOK, item)
+    }
+}
+
+// restoreSnapshot переписывает черновик лендинга содержимым снимка.
+// Ссылки сохраняют свои id, чтобы не терялась привязанная к ним статистика,
+// а позиции нумеруются заново: в старых снимках они могли повторяться.
+// Если id ссылки уже занят другим лендингом, она восстанавливается под новым.
+func restoreSnapshot(tx *sqlx.Tx, landingID int, snap LandingSnapshot) (Landing, error) {
+    var item Landing
+    ids := make([]int64, len(snap.Links))
+    for i, l := range snap.Links {
+        ids[i] = int64(l.ID)
+    }
+    if _, err := tx.Exec("DELETE FROM links WHERE landing_id=$1 AND NOT (id = ANY($2))", landingID, pq.Int64Array(ids)); err != nil {
+        return item, err
+    }
+    links := append([]Link(nil), snap.Links...)
+    sort.SliceStable(links, func(i, j int) bool { return links[i].Position < links[j].Position })
+    for i, l := range links {
+        query := `INSERT INTO links (id, landing_id, type, title, url, position,
+                                     visible_from, visible_until, max_clicks, fallback_url, rules, updated_at)
+                  VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,NOW())
+                  ON CONFLICT (id) DO UPDATE
+                     SET type=EXCLUDED.type, title=EXCLUDED.title, url=EXCLUDED.url,
+                         position=EXCLUDED.position, visible_from=EXCLUDED.visible_from,
+                         visible_until=EXCLUDED.visible_until, max_clicks=EXCLUDED.max_clicks,
+                         fallback_url=EXCLUDED.fallback_url, rules=EXCLUDED.rules, updated_at=NOW()
+                   WHERE links.landing_id = EXCLUDED.landing_id
+                  RETURNING id`
+        var id int
+        err := tx.Get(&id, query, l.ID, landingID, l.Type, l.Title, l.URL, i,
+            l.VisibleFrom, l.VisibleUntil, l.MaxClicks, l.FallbackURL, l.Rules)
+        if err == sql.ErrNoRows {
+            // id уже занят ссылкой другого лендинга — восстанавливаем под новым id,
+            // чужую ссылку не трогаем
+            query = `INSERT INTO links (landing_id, type, title, url, position,
+                                        visible_from, visible_until, max_clicks, fallback_url, rules, updated_at)
+                     VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,NOW())`
+            _, err = tx.Exec(query, landingID, l.Type, l.Title, l.URL, i,
+                l.VisibleFrom, l.VisibleUntil, l.MaxClicks, l.FallbackURL, l.Rules)
+        }
+        if err != nil {
+            return item, err
+        }
+    }
+
+    blocks := snap.Blocks
+    if blocks == nil {
+        blocks = Blocks{}
+    }
+    query := `UPDATE landings SET title=$1, description=$2, avatar_url=$3, blocks=$4, theme=$5, updated_at=NOW()
+              WHERE id=$6 RETURNING *`
+    err := tx.Get(&item, query, snap.Title, snap.Description, snap.AvatarURL, blocks, snap.Theme, landingID)
+    return item, err
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+    "regexp"
+    "testing"
+    "time"
+
+    "github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRestoreSnapshotReassignsTakenLinkID(t *testing.T) {
+    db, mock := newMockDB(t)
+    snap := LandingSnapshot{
+        Title: "A",
+        Links: []Link{
+            {ID: 7, Type: "url", Title: "Own", URL: "https://a.example/", Position: 0},
+            {ID: 9, Type: "url", Title: "Moved", URL: "https://b.example/", Position: 1},
+        },
+    }
+    now := time.Now()
+
+    mock.ExpectBegin()
+    mock.ExpectExec(regexp.QuoteMeta("DELETE FROM links WHERE landing_id=$1")).
+        WillReturnResult(sqlmock.NewResult(0, 0))
+    mock.ExpectQuery(regexp.QuoteMeta("ON CONFLICT (id) DO UPDATE")).
+        WithArgs(7, 1, "url", "Own", "https://a.example/", 0,
+            sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+    // ссылка 9 теперь принадлежит другому лендингу: upsert ничего не вернул
+    mock.ExpectQuery(regexp.QuoteMeta("ON CONFLICT (id) DO UPDATE")).
+        WithArgs(9, 1, "url", "Moved", "https://b.example/", 1,
+            sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"id"}))
+    mock.ExpectExec(regexp.QuoteMeta("INSERT INTO links (landing_id, type")).
+        WithArgs(1, "url", "Moved", "https://b.example/", 1,
+            sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectQuery(regexp.QuoteMeta("UPDATE landings SET title=$1")).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "title", "created_at", "updated_at"}).
+            AddRow(1, "A", now, now))
+    mock.ExpectRollback()
+
+    tx, err := db.Beginx()
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer tx.Rollback()
+    item, err := restoreSnapshot(tx, 1, snap)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if item.ID != 1 {
+        t.Errorf("landing id = %d, want 1", item.ID)
+    }
+}
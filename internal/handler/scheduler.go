@@ -0,0 +1,167 @@
+package handler
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "log"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+
+    "github.com/blagoweb/bbtg/internal/telegram"
+)
+
+// scheduleLanding задаёт или снимает расписание публикации.
+// null в поле очищает соответствующее время.
+func scheduleLanding(db *sqlx.DB) gin.HandlerFunc {
+    type request struct {
+        PublishAt   *time.Time `json:"publishAt"`
+        UnpublishAt *time.Time `json:"unpublishAt"`
+    }
+    return func(c *gin.Context) {
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+
+        errs := fieldErrors{}
+        now := time.Now()
+        if req.PublishAt != nil && !req.PublishAt.After(now) {
+            errs["publishAt"] = "must be in the future"
+        }
+        if req.UnpublishAt != nil && !req.UnpublishAt.After(now) {
+            errs["unpublishAt"] = "must be in the future"
+        }
+        if req.PublishAt != nil && req.UnpublishAt != nil && !req.UnpublishAt.After(*req.PublishAt) {
+            errs["unpublishAt"] = "must be after publishAt"
+        }
+        if len(errs) > 0 {
+            respondFieldErrors(c, errs)
+            return
+        }
+
+        var item Landing
+        query := `UPDATE landings SET publish_at=$1, unpublish_at=$2 WHERE id=$3 RETURNING *`
+        if err := db.Get(&item, query, req.PublishAt, req.UnpublishAt, c.GetInt(ctxLandingID)); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusOK, item)
+    }
+}
+
+// RunScheduler раз в interval публикует и снимает с публикации лендинги по
+// расписанию, пока не отменён ctx. Строки берутся через FOR UPDATE SKIP LOCKED,
+// поэтому планировщик можно запускать на нескольких репликах одновременно.
+func RunScheduler(ctx context.Context, db *sqlx.DB, bot *telegram.Bot, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        runScheduledJobs(ctx, db, bot)
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// scheduledJob — одно действие планировщика над лендингом
+type scheduledJob struct {
+    name  string
+    query string // выбирает одну созревшую строку
+    apply func(tx *sqlx.Tx, landing Landing) (string, error)
+}
+
+var scheduledJobs = []scheduledJob{
+    {
+        name: "publish",
+        query: `SELECT * FROM landings WHERE publish_at <= NOW()
+                ORDER BY publish_at LIMIT 1 FOR UPDATE SKIP LOCKED`,
+        apply: func(tx *sqlx.Tx, landing Landing) (string, error) {
+            if _, err := tx.Exec("UPDATE landings SET publish_at=NULL WHERE id=$1", landing.ID); err != nil {
+                return "", err
+            }
+            if errs := validateBlocks(landing.Blocks); errs != nil {
+                return fmt.Sprintf("Не удалось опубликовать «%s» по расписанию: в черновике есть ошибки в блоках.", landing.Title), nil
+            }
+            rev, err := publishRevision(tx, landing, "scheduled", nil)
+            if err != nil {
+                return "", err
+            }
+            return fmt.Sprintf("Лендинг «%s» опубликован по расписанию (ревизия %d): /p/%s", landing.Title, rev.Number, landing.Slug), nil
+        },
+    },
+    {
+        name: "unpublish",
+        query: `SELECT * FROM landings WHERE unpublish_at <= NOW()
+                ORDER BY unpublish_at LIMIT 1 FOR UPDATE SKIP LOCKED`,
+        apply: func(tx *sqlx.Tx, landing Landing) (string, error) {
+            query := `UPDATE landings SET unpublish_at=NULL, published_revision_id=NULL, published_at=NULL WHERE id=$1`
+            if _, err := tx.Exec(query, landing.ID); err != nil {
+                return "", err
+            }
+            return fmt.Sprintf("Лендинг «%s» снят с публикации по расписанию.", landing.Title), nil
+        },
+    },
+}
+
+// runScheduledJobs обрабатывает все созревшие лендинги, каждый в своей транзакции
+func runScheduledJobs(ctx context.Context, db *sqlx.DB, bot *telegram.Bot) {
+    for _, job := range scheduledJobs {
+        for ctx.Err() == nil {
+            landing, text, err := runScheduledJob(ctx, db, job)
+            if err == sql.ErrNoRows {
+                break
+            }
+            if err != nil {
+                log.Printf("scheduler %s error: %v", job.name, err)
+                break
+            }
+            log.Printf("scheduler: %s landing %d", job.name, landing.ID)
+            notifyLandingOwners(db, bot, landing, text)
+        }
+    }
+}
+
+func runScheduledJob(ctx context.Context, db *sqlx.DB, job scheduledJob) (Landing, string, error) {
+    var landing Landing
+    tx, err := db.BeginTxx(ctx, nil)
+    if err != nil {
+        return landing, "", err
+    }
+    defer tx.Rollback()
+
+    if err := tx.Get(&landing, job.query); err != nil {
+        return landing, "", err
+    }
+    text, err := job.apply(tx, landing)
+    if err != nil {
+        return landing, "", err
+    }
+    return landing, text, tx.Commit()
+}
+
+// notifyLandingOwners шлёт сообщение владельцам пространства лендинга
+func notifyLandingOwners(db *sqlx.DB, bot *telegram.Bot, landing Landing, text string) {
+    if bot == nil {
+        return
+    }
+    var chatIDs []int64
+    query := `SELECT u.telegram_id FROM workspace_members m
+              JOIN users u ON u.id = m.user_id
+              WHERE m.workspace_id=$1 AND m.role=$2`
+    if err := db.Select(&chatIDs, query, landing.WorkspaceID, RoleOwner); err != nil {
+        log.Printf("scheduler notify landing %d error: %v", landing.ID, err)
+        return
+    }
+    for _, id := range chatIDs {
+        if err := bot.SendMessage(id, text); err != nil {
+            log.Printf("scheduler notify %d error: %v", id, err)
+        }
+    }
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "database/sql"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "log"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+)
+
+// Session — сессия входа на одном устройстве. id сессии используется как
+// jti access-токенов, refresh-токен ротируется при каждом обновлении.
+type Session struct {
+    ID               string     `db:"id" json:"id"`
+    UserID           int        `db:"user_id" json:"userId"`
+    RefreshTokenHash string     `db:"refresh_token_hash" json:"-"`
+    UserAgent        string     `db:"user_agent" json:"userAgent"`
+    IPAddress        string     `db:"ip_address" json:"ipAddress"`
+    CreatedAt        time.Time  `db:"created_at" json:"createdAt"`
+    LastUsedAt       time.Time  `db:"last_used_at" json:"lastUsedAt"`
+    ExpiresAt        time.Time  `db:"expires_at" json:"expiresAt"`
+    RevokedAt        *time.Time `db:"revoked_at" json:"revokedAt"`
+}
+
+var errInvalidRefresh = errors.New("invalid refresh token")
+
+// randomToken возвращает n случайных байт в base64url
+func randomToken(n int) (string, error) {
+    buf := make([]byte, n)
+    if _, err := rand.Read(buf); err != nil {
+        return "", err
+    }
+    return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
+
+// newRefreshToken выпускает refresh-токен вида "<id сессии>.<секрет>":
+// по id находим сессию даже при несовпадении секрета
+func newRefreshToken(sessionID string) (string, error) {
+    secret, err := randomToken(32)
+    if err != nil {
+        return "", err
+    }
+    return sessionID + "." + secret, nil
+}
+
+// startSession заводит новую сессию и возвращает её с refresh-токеном
+func startSession(db sqlx.Queryer, userID int, c *gin.Context, ttl time.Duration) (Session, string, error) {
+    var s Session
+    id, err := randomToken(18)
+    if err != nil {
+        return s, "", err
+    }
+    refresh, err := newRefreshToken(id)
+    if err != nil {
+        return s, "", err
+    }
+    query := `INSERT INTO sessions (id, user_id, refresh_token_hash, user_agent, ip_address, expires_at)
+              VALUES ($1,$2,$3,$4,$5,$6) RETURNING *`
+    err = sqlx.Get(db, &s, query, id, userID, hashToken(refresh),
+        c.Request.UserAgent(), c.ClientIP(), time.Now().Add(ttl))
+    return s, refresh, err
+}
+
+// rotateSession обменивает refresh-токен на новый. Повторное предъявление
+// уже использованного токена означает утечку — такая сессия отзывается.
+func rotateSession(db *sqlx.DB, refresh string, ttl time.Duration) (Session, string, error) {
+    var s Session
+    sessionID, _, found := strings.Cut(refresh, ".")
+    if !found {
+        return s, "", errInvalidRefresh
+    }
+
+    tx, err := db.Beginx()
+    if err != nil {
+        return s, "", err
+    }
+    defer tx.Rollback()
+
+    if err := tx.Get(&s, "SELECT * FROM sessions WHERE id=$1 FOR UPDATE", sessionID); err != nil {
+        if err == sql.ErrNoRows {
+            return s, "", errInvalidRefresh
+        }
+        return s, "", err
+    }
+    if s.RevokedAt != nil || time.Now().After(s.ExpiresAt) {
+        return s, "", errInvalidRefresh
+    }
+    if hashToken(refresh) != s.RefreshTokenHash {
+        log.Printf("refresh token reuse detected, revoking session %s of user %d", s.ID, s.UserID)
+        if _, err := tx.Exec("UPDATE sessions SET revoked_at=NOW() WHERE id=$1", s.ID); err != nil {
+            return s, "", err
+        }
+        if err := tx.Commit(); err != nil {
+            return s, "", err
+        }
+        return s, "", errInvalidRefresh
+    }
+
+    next, err := newRefreshToken(s.ID)
+    if err != nil {
+        return s, "", err
+    }
+    query := `UPDATE sessions SET refresh_token_hash=$1, last_used_at=NOW(), expires_at=$2
+              WHERE id=$3 RETURNING *`
+    if err := tx.Get(&s, query, hashToken(next), time.Now().Add(ttl), s.ID); err != nil {
+        return s, "", err
+    }
+    return s, next, tx.Commit()
+}
+
+// sessionActive сообщает, что сессия пользователя не отозвана и не истекла
+func sessionActive(db sqlx.Queryer, sessionID string, userID int) (bool, error) {
+    var ok bool
+    query := `SELECT EXISTS (SELECT 1 FROM sessions
+              WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL AND expires_at > NOW())`
+    err := sqlx.Get(db, &ok, query, sessionID, userID)
+    return ok, err
+}
+
+// refreshSession обменивает refresh-токен на новую пару токенов
+func refreshSession(db *sqlx.DB, cfg AuthConfig) gin.HandlerFunc {
+    type request struct {
+        RefreshToken string `json:"refreshToken" binding:"required"`
+    }
+    return func(c *gin.Context) {
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        s, refresh, err := rotateSession(db, req.RefreshToken, cfg.RefreshTTL)
+        if err != nil {
+            if err == errInvalidRefresh {
+                c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+                return
+            }
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+
+        var user User
+        if err := db.Get(&user, "SELECT * FROM users WHERE id=$1", s.UserID); err != nil {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidRefresh.Error()})
+            return
+        }
+        respondTokens(c, user, s, refresh, cfg)
+    }
+}
+
+// logout отзывает текущую сессию
+func logout(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+        if _, err := db.Exec("UPDATE sessions SET revoked_at=NOW() WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL",
+            c.GetString(ctxSessionID), uid); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.Status(http.StatusNoContent)
+    }
+}
+
+// logoutAll отзывает все сессии пользователя («выйти на всех устройствах»)
+func logoutAll(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+        if _, err := db.Exec("UPDATE sessions SET revoked_at=NOW() WHERE user_id=$1 AND revoked_at IS NULL", uid); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.Status(http.StatusNoContent)
+    }
+}
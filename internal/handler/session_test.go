@@ -0,0 +1,91 @@
+package handler
+
+import (
+    "regexp"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/DATA-DOG/go-sqlmock"
+)
+
+var sessionColumns = []string{"id", "user_id", "refresh_token_hash", "user_agent", "ip_address",
+    "created_at", "last_used_at", "expires_at", "revoked_at"}
+
+func sessionRow(refresh string, expiresAt time.Time, revokedAt interface{}) *sqlmock.Rows {
+    now := time.Now()
+    return sqlmock.NewRows(sessionColumns).
+        AddRow("sess", 1, hashToken(refresh), "", "", now, now, expiresAt, revokedAt)
+}
+
+func TestRotateSession(t *testing.T) {
+    db, mock := newMockDB(t)
+    refresh := "sess.secret"
+    mock.ExpectBegin()
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM sessions WHERE id=$1 FOR UPDATE")).
+        WithArgs("sess").
+        WillReturnRows(sessionRow(refresh, time.Now().Add(time.Hour), nil))
+    mock.ExpectQuery(regexp.QuoteMeta("UPDATE sessions SET refresh_token_hash=$1")).
+        WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "sess").
+        WillReturnRows(sessionRow("sess.next", time.Now().Add(time.Hour), nil))
+    mock.ExpectCommit()
+
+    s, next, err := rotateSession(db, refresh, time.Hour)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if s.ID != "sess" || !strings.HasPrefix(next, "sess.") || next == refresh {
+        t.Errorf("rotateSession = %q, %q", s.ID, next)
+    }
+}
+
+func TestRotateSessionReuseRevokes(t *testing.T) {
+    db, mock := newMockDB(t)
+    // сессия уже ротирована: в базе хэш другого токена
+    mock.ExpectBegin()
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM sessions WHERE id=$1 FOR UPDATE")).
+        WithArgs("sess").
+        WillReturnRows(sessionRow("sess.current", time.Now().Add(time.Hour), nil))
+    mock.ExpectExec(regexp.QuoteMeta("UPDATE sessions SET revoked_at=NOW() WHERE id=$1")).
+        WithArgs("sess").
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectCommit()
+
+    if _, _, err := rotateSession(db, "sess.stolen", time.Hour); err != errInvalidRefresh {
+        t.Errorf("err = %v, want errInvalidRefresh", err)
+    }
+}
+
+func TestRotateSessionRejected(t *testing.T) {
+    refresh := "sess.secret"
+    tests := []struct {
+        name string
+        rows *sqlmock.Rows
+    }{
+        {"revoked", sessionRow(refresh, time.Now().Add(time.Hour), time.Now().Add(-time.Minute))},
+        {"expired", sessionRow(refresh, time.Now().Add(-time.Minute), nil)},
+        {"unknown", sqlmock.NewRows(sessionColumns)},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            db, mock := newMockDB(t)
+            // ничего не обновляется, транзакция откатывается
+            mock.ExpectBegin()
+            mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM sessions WHERE id=$1 FOR UPDATE")).
+                WithArgs("sess").
+                WillReturnRows(tt.rows)
+            mock.ExpectRollback()
+
+            if _, _, err := rotateSession(db, refresh, time.Hour); err != errInvalidRefresh {
+                t.Errorf("err = %v, want errInvalidRefresh", err)
+            }
+        })
+    }
+}
+
+func TestRotateSessionMalformed(t *testing.T) {
+    db, _ := newMockDB(t)
+    if _, _, err := rotateSession(db, "no-dot", time.Hour); err != errInvalidRefresh {
+        t.Errorf("err = %v, want errInvalidRefresh", err)
+    }
+}
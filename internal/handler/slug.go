@@ -0,0 +1,157 @@
+package handler
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "net/http"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+)
+
+const (
+    minSlugLength = 3
+    maxSlugLength = 64
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]*[a-z0-9])?$`)
+
+// reservedSlugs — адреса, которые нельзя занять: они совпадают с маршрутами
+// сервиса или могут ввести посетителей в заблуждение
+var reservedSlugs = map[string]bool{
+    "about": true, "admin": true, "api": true, "app": true, "auth": true,
+    "billing": true, "blog": true, "bot": true, "dashboard": true, "docs": true,
+    "edit": true, "health": true, "help": true, "login": true, "logout": true,
+    "media": true, "new": true, "p": true, "pricing": true, "privacy": true,
+    "r": true, "root": true, "settings": true, "signup": true, "static": true,
+    "support": true, "system": true, "telegram": true, "terms": true, "www": true,
+}
+
+var (
+    errSlugLength   = errors.New("slug must be between 3 and 64 characters")
+    errSlugFormat   = errors.New("slug may contain only latin letters, digits and single hyphens")
+    errSlugReserved = errors.New("slug is reserved")
+    errSlugTaken    = errors.New("slug is already taken")
+)
+
+// normalizeSlug приводит slug к каноническому виду (slug'и регистронезависимы)
+func normalizeSlug(s string) string {
+    return strings.ToLower(strings.TrimSpace(s))
+}
+
+// validateSlug проверяет формат уже нормализованного slug'а
+func validateSlug(slug string) error {
+    if len(slug) < minSlugLength || len(slug) > maxSlugLength {
+        return errSlugLength
+    }
+    if !slugPattern.MatchString(slug) || strings.Contains(slug, "--") {
+        return errSlugFormat
+    }
+    if reservedSlugs[slug] {
+        return errSlugReserved
+    }
+    return nil
+}
+
+// slugAvailable проверяет, что slug не занят другим лендингом — ни текущим
+// адресом, ни адресом из истории переименований. landingID — лендинг,
+// которому slug может принадлежать (0 для нового).
+func slugAvailable(q sqlx.Queryer, slug string, landingID int) (bool, error) {
+    var taken bool
+    query := `SELECT EXISTS (SELECT 1 FROM landings WHERE LOWER(slug)=$1 AND id<>$2)
+                  OR EXISTS (SELECT 1 FROM landing_slug_history WHERE slug=$1 AND landing_id<>$2)`
+    if err := sqlx.Get(q, &taken, query, slug, landingID); err != nil {
+        return false, err
+    }
+    return !taken, nil
+}
+
+// generateSlug подбирает свободный случайный slug для лендинга без явного адреса
+func generateSlug(q sqlx.Queryer) (string, error) {
+    for i := 0; i < 5; i++ {
+        buf := make([]byte, 4)
+        if _, err := rand.Read(buf); err != nil {
+            return "", err
+        }
+        slug := "page-" + hex.EncodeToString(buf)
+        ok, err := slugAvailable(q, slug, 0)
+        if err != nil {
+            return "", err
+        }
+        if ok {
+            return slug, nil
+        }
+    }
+    return "", errors.New("failed to generate unique slug")
+}
+
+// checkSlug проверяет slug для лендинга landingID и возвращает нормализованное значение
+func checkSlug(q sqlx.Queryer, raw string, landingID int) (string, error) {
+    slug := normalizeSlug(raw)
+    if err := validateSlug(slug); err != nil {
+        return slug, err
+    }
+    ok, err := slugAvailable(q, slug, landingID)
+    if err != nil {
+        return slug, err
+    }
+    if !ok {
+        return slug, errSlugTaken
+    }
+    return slug, nil
+}
+
+// respondSlugError переводит ошибку проверки slug'а в HTTP-ответ
+func respondSlugError(c *gin.Context, err error) {
+    switch err {
+    case errSlugTaken:
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "fields": fieldErrors{"slug": err.Error()}})
+    case errSlugLength, errSlugFormat, errSlugReserved:
+        respondFieldErrors(c, fieldErrors{"slug": err.Error()})
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    }
+}
+
+// checkSlugAvailability отвечает, можно ли занять slug (?slug=...&landingId=...)
+func checkSlugAvailability(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        landingID := 0
+        if raw := c.Query("landingId"); raw != "" {
+            id, err := strconv.Atoi(raw)
+            if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "invalid landingId"})
+                return
+            }
+            landingID = id
+        }
+
+        slug, err := checkSlug(db, c.Query("slug"), landingID)
+        switch err {
+        case nil:
+            c.JSON(http.StatusOK, gin.H{"slug": slug, "available": true})
+        case errSlugLength, errSlugFormat, errSlugReserved, errSlugTaken:
+            c.JSON(http.StatusOK, gin.H{"slug": slug, "available": false, "reason": err.Error()})
+        default:
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        }
+    }
+}
+
+// renameSlug запоминает прежний slug лендинга в истории, чтобы старые ссылки
+// продолжали редиректить, и освобождает новый slug, если он был в истории
+// этого же лендинга
+func renameSlug(tx *sqlx.Tx, landingID int, oldSlug, newSlug string) error {
+    if _, err := tx.Exec(`DELETE FROM landing_slug_history WHERE slug=$1 AND landing_id=$2`,
+        newSlug, landingID); err != nil {
+        return err
+    }
+    _, err := tx.Exec(`INSERT INTO landing_slug_history (slug, landing_id) VALUES ($1,$2)
+                       ON CONFLICT (slug) DO UPDATE SET landing_id=EXCLUDED.landing_id, created_at=NOW()`,
+        normalizeSlug(oldSlug), landingID)
+    return err
+}
@@ -29,9 +29,10 @@ type Subscription struct {
 // RegisterSubscriptionRoutes регистрирует маршруты для подписок
 func RegisterSubscriptionRoutes(rg *gin.RouterGroup, db *sqlx.DB, cfg *config.Config) {
     r := rg.Group("/subscriptions")
-    r.GET("", listSubscriptions(db))
-    r.POST("", createSubscription(db, cfg))
-    r.DELETE("/:id", cancelSubscription(db, cfg))
+    r.GET("", requireScope(ScopeSubscriptionsRead), listSubscriptions(db))
+    // оформлять и отменять подписки можно только из сессии пользователя
+    r.POST("", requireSession(), createSubscription(db, cfg))
+    r.DELETE("/:id", requireSession(), cancelSubscription(db, cfg))
 }
 
 // listSubscriptions возвращает все подписки текущего пользователя
@@ -121,9 +122,13 @@ func createSubscription(db *sqlx.DB, cfg *config.Config) gin.HandlerFunc {
 // cancelSubscription отменяет подписку в YooKassa и обновляет статус в БД
 func cancelSubscription(db *sqlx.DB, cfg *config.Config) gin.HandlerFunc {
     return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
         idStr := c.Param("id")
         var sub Subscription
-        if err := db.Get(&sub, "SELECT * FROM subscriptions WHERE id=$1", idStr); err != nil {
+        if err := db.Get(&sub, "SELECT * FROM subscriptions WHERE id=$1 AND user_id=$2", idStr, uid); err != nil {
             if err == sql.ErrNoRows {
                 c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
                 return
@@ -146,7 +151,7 @@ func cancelSubscription(db *sqlx.DB, cfg *config.Config) gin.HandlerFunc {
         defer resp.Body.Close()
 
         // Обновляем статус в БД
-        _, err = db.Exec("UPDATE subscriptions SET status='canceled', updated_at=NOW() WHERE id=$1", idStr)
+        _, err = db.Exec("UPDATE subscriptions SET status='canceled', updated_at=NOW() WHERE id=$1 AND user_id=$2", idStr, uid)
         if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
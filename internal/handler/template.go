@@ -0,0 +1,363 @@
+package handler
+
+import (
+    "database/sql"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+)
+
+// LandingTemplate — заготовка лендинга: содержимое, тема, ссылки и блоки.
+// Системные шаблоны не привязаны к пространству и доступны всем.
+type LandingTemplate struct {
+    ID          int              `db:"id" json:"id"`
+    WorkspaceID *int             `db:"workspace_id" json:"workspaceId"` // nil — системный шаблон
+    Name        string           `db:"name" json:"name"`
+    Description string           `db:"description" json:"description"`
+    Category    string           `db:"category" json:"category"`
+    Blueprint   *LandingSnapshot `db:"blueprint" json:"blueprint,omitempty"`
+    CreatedBy   *int             `db:"created_by" json:"createdBy"`
+    CreatedAt   time.Time        `db:"created_at" json:"createdAt"`
+    UpdatedAt   time.Time        `db:"updated_at" json:"updatedAt"`
+}
+
+// RegisterTemplateRoutes регистрирует каталог шаблонов и создание лендингов из них
+func RegisterTemplateRoutes(rg *gin.RouterGroup, db *sqlx.DB) {
+    r := rg.Group("/templates")
+    read, write := requireScope(ScopeLandingsRead), requireScope(ScopeLandingsWrite)
+    r.GET("", read, listTemplates(db))
+    r.POST("", write, saveTemplate(db))
+    r.GET("/:id", read, getTemplate(db))
+    r.GET("/:id/preview", read, previewTemplate(db))
+    r.DELETE("/:id", write, deleteTemplate(db))
+    r.POST("/:id/instantiate", write, instantiateTemplate(db))
+}
+
+// listTemplates возвращает системные шаблоны и шаблоны пространств пользователя
+// (или одного, если передан ?workspaceId=); без blueprint
+func listTemplates(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+        workspaceID := 0
+        if raw := c.Query("workspaceId"); raw != "" {
+            var err error
+            if workspaceID, err = strconv.Atoi(raw); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workspaceId"})
+                return
+            }
+        }
+
+        items := []LandingTemplate{}
+        query := `SELECT t.id, t.workspace_id, t.name, t.description, t.category, t.created_by, t.created_at, t.updated_at
+                  FROM landing_templates t
+                  WHERE t.workspace_id IS NULL
+                     OR (t.workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id=$1)
+                         AND ($2=0 OR t.workspace_id=$2))
+                  ORDER BY t.workspace_id NULLS FIRST, t.name`
+        if err := db.Select(&items, query, uid, workspaceID); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusOK, items)
+    }
+}
+
+// loadTemplate читает шаблон по :id и проверяет доступ: системные шаблоны
+// можно только читать, пользовательские — с ролью не ниже min в их пространстве
+func loadTemplate(c *gin.Context, db sqlx.Queryer, min string) (LandingTemplate, bool) {
+    var item LandingTemplate
+    id, err := strconv.Atoi(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+        return item, false
+    }
+    err = sqlx.Get(db, &item, "SELECT * FROM landing_templates WHERE id=$1", id)
+    if err == sql.ErrNoRows {
+        c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+        return item, false
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return item, false
+    }
+    if item.WorkspaceID == nil {
+        if min != RoleViewer {
+            c.JSON(http.StatusForbidden, gin.H{"error": "system templates are read-only"})
+            return item, false
+        }
+        return item, true
+    }
+    if !authorizeWorkspace(c, db, *item.WorkspaceID, min) {
+        return item, false
+    }
+    return item, true
+}
+
+func getTemplate(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if item, ok := loadTemplate(c, db, RoleViewer); ok {
+            c.JSON(http.StatusOK, item)
+        }
+    }
+}
+
+// previewTemplate показывает шаблон так, как будет выглядеть созданный из него лендинг
+func previewTemplate(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        item, ok := loadTemplate(c, db, RoleViewer)
+        if !ok {
+            return
+        }
+        page := snapshotPage(Landing{}, *item.Blueprint)
+        c.Header("Cache-Control", "no-store")
+        c.Header("X-Robots-Tag", "noindex")
+        writePage(c, page)
+    }
+}
+
+// saveTemplate сохраняет черновик лендинга как шаблон его пространства
+func saveTemplate(db *sqlx.DB) gin.HandlerFunc {
+    type request struct {
+        LandingID   int    `json:"landingId" binding:"required"`
+        Name        string `json:"name" binding:"required,max=255"`
+        Description string `json:"description"`
+        Category    string `json:"category" binding:"max=50"`
+    }
+    return func(c *gin.Context) {
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        if !authorizeLanding(c, db, req.LandingID, RoleEditor) {
+            return
+        }
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+
+        var landing Landing
+        if err := db.Get(&landing, "SELECT * FROM landings WHERE id=$1", req.LandingID); err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+        snap, err := captureSnapshot(db, landing)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        blueprint := blueprintOf(snap)
+
+        var item LandingTemplate
+        query := `INSERT INTO landing_templates (workspace_id, name, description, category, blueprint, created_by)
+                  VALUES ($1,$2,$3,$4,$5,$6) RETURNING *`
+        if err := db.Get(&item, query, landing.WorkspaceID, req.Name, req.Description, req.Category, blueprint, uid); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusCreated, item)
+    }
+}
+
+func deleteTemplate(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        item, ok := loadTemplate(c, db, RoleEditor)
+        if !ok {
+            return
+        }
+        if _, err := db.Exec("DELETE FROM landing_templates WHERE id=$1", item.ID); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.Status(http.StatusNoContent)
+    }
+}
+
+// copyRequest — параметры нового лендинга при создании из шаблона или копии
+type copyRequest struct {
+    Title       string `json:"title"`
+    Slug        string `json:"slug"`
+    WorkspaceID int    `json:"workspaceId"`
+}
+
+// instantiateTemplate создаёт новый лендинг (черновик) из шаблона
+func instantiateTemplate(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        item, ok := loadTemplate(c, db, RoleViewer)
+        if !ok {
+            return
+        }
+        var req copyRequest
+        if c.Request.ContentLength > 0 {
+            if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+            }
+        }
+        copyLanding(c, db, *item.Blueprint, req, 0)
+    }
+}
+
+// duplicateLanding создаёт копию лендинга (его черновика) по той же схеме,
+// что и создание из шаблона. По умолчанию копия остаётся в том же пространстве.
+// Копировать может только редактор исходного лендинга.
+func duplicateLanding(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var req copyRequest
+        if c.Request.ContentLength > 0 {
+            if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+            }
+        }
+        var landing Landing
+        if err := db.Get(&landing, "SELECT * FROM landings WHERE id=$1", c.GetInt(ctxLandingID)); err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+        snap, err := captureSnapshot(db, landing)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if req.Title == "" {
+            req.Title = landing.Title + " (копия)"
+        }
+        copyLanding(c, db, blueprintOf(snap), req, landing.WorkspaceID)
+    }
+}
+
+// copyLanding разворачивает blueprint в новый лендинг в одной транзакции и
+// отвечает им. Без workspaceId в запросе используется defaultWorkspace,
+// а если и он 0 — личное пространство.
+func copyLanding(c *gin.Context, db *sqlx.DB, blueprint LandingSnapshot, req copyRequest, defaultWorkspace int) {
+    uid, ok := currentUserID(c)
+    if !ok {
+        return
+    }
+    workspaceID := req.WorkspaceID
+    if workspaceID == 0 {
+        workspaceID = defaultWorkspace
+    }
+    if workspaceID == 0 {
+        var err error
+        if workspaceID, err = personalWorkspaceID(db, uid); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+    }
+    if !authorizeWorkspace(c, db, workspaceID, RoleEditor) {
+        return
+    }
+    if req.Title != "" {
+        blueprint.Title = req.Title
+    }
+    blueprint.Title = truncate(blueprint.Title, 255)
+
+    tx, err := db.Beginx()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    defer tx.Rollback()
+
+    var slug string
+    if req.Slug != "" {
+        slug, err = checkSlug(tx, req.Slug, 0)
+    } else {
+        slug, err = generateSlug(tx)
+    }
+    if err != nil {
+        respondSlugError(c, err)
+        return
+    }
+
+    item, err := instantiateBlueprint(tx, uid, workspaceID, slug, blueprint)
+    if err != nil {
+        if errs, ok := err.(fieldErrors); ok {
+            respondFieldErrors(c, errs)
+            return
+        }
+        if isUniqueViolation(err) {
+            respondSlugError(c, errSlugTaken)
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if err := tx.Commit(); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    c.JSON(http.StatusCreated, item)
+}
+
+// blueprintOf отвязывает снимок от исходного лендинга: у ссылок не остаётся
+// id, отметок времени и счётчика кликов, позиции идут подряд
+func blueprintOf(snap LandingSnapshot) LandingSnapshot {
+    links := make([]Link, len(snap.Links))
+    for i, l := range snap.Links {
+        links[i] = Link{Type: l.Type, Title: l.Title, URL: l.URL, Position: i,
+            VisibleFrom: l.VisibleFrom, VisibleUntil: l.VisibleUntil, MaxClicks: l.MaxClicks, FallbackURL: l.FallbackURL, Rules: l.Rules}
+    }
+    snap.Links = links
+    return snap
+}
+
+// validateBlueprintLinks проверяет ссылки blueprint так же, как API ссылок,
+// и нормализует их на месте; ключи ошибок — links.N.*
+func validateBlueprintLinks(links []Link) fieldErrors {
+    errs := fieldErrors{}
+    for i := range links {
+        l := &links[i]
+        in := linkInput{Type: l.Type, Title: l.Title, URL: l.URL,
+            VisibleFrom: l.VisibleFrom, VisibleUntil: l.VisibleUntil, MaxClicks: l.MaxClicks, FallbackURL: l.FallbackURL, Rules: l.Rules}
+        for k, v := range in.validate("links." + strconv.Itoa(i) + ".") {
+            errs[k] = v
+        }
+        l.Type, l.Title, l.URL, l.Rules = in.Type, in.Title, in.URL, in.Rules
+    }
+    return errs
+}
+
+// instantiateBlueprint создаёт лендинг с блоками и ссылками из blueprint.
+// Блоки получают новые id, лендинг создаётся неопубликованным черновиком.
+// Невалидные ссылки возвращаются ошибкой типа fieldErrors.
+func instantiateBlueprint(tx *sqlx.Tx, userID, workspaceID int, slug string, blueprint LandingSnapshot) (Landing, error) {
+    var item Landing
+    if errs := validateBlueprintLinks(blueprint.Links); len(errs) > 0 {
+        return item, errs
+    }
+    blocks := make(Blocks, len(blueprint.Blocks))
+    for i, b := range blueprint.Blocks {
+        id, err := newBlockID()
+        if err != nil {
+            return item, err
+        }
+        b.ID = id
+        blocks[i] = b
+    }
+
+    query := `INSERT INTO landings(user_id, workspace_id, slug, title, description, avatar_url, blocks, theme, created_at, updated_at)
+              VALUES($1,$2,$3,$4,$5,$6,$7,$8,NOW(),NOW()) RETURNING *`
+    if err := tx.Get(&item, query, userID, workspaceID, slug,
+        blueprint.Title, blueprint.Description, blueprint.AvatarURL, blocks, blueprint.Theme); err != nil {
+        return item, err
+    }
+    for i, l := range blueprint.Links {
+        query := `INSERT INTO links (landing_id, type, title, url, position, visible_from, visible_until, max_clicks, fallback_url, rules)
+                  VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`
+        if _, err := tx.Exec(query, item.ID, l.Type, l.Title, l.URL, i,
+            l.VisibleFrom, l.VisibleUntil, l.MaxClicks, l.FallbackURL, l.Rules); err != nil {
+            return item, err
+        }
+    }
+    return item, nil
+}
@@ -0,0 +1,477 @@
+package handler
+
+import (
+    "bytes"
+    "database/sql/driver"
+    "encoding/json"
+    "fmt"
+    "html/template"
+    "math"
+    "net/http"
+    "net/url"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+)
+
+// Theme — дизайн-токены лендинга. Пустые поля берутся из пресета
+// (по умолчанию light), поэтому хранится только то, что пользователь поменял.
+type Theme struct {
+    Preset      string           `json:"preset,omitempty"`
+    Colors      ThemeColors      `json:"colors"`
+    Font        string           `json:"font,omitempty"`        // основной шрифт
+    HeadingFont string           `json:"headingFont,omitempty"` // шрифт заголовков
+    ButtonShape string           `json:"buttonShape,omitempty"` // square, rounded, pill
+    ButtonStyle string           `json:"buttonStyle,omitempty"` // filled, outline
+    Background  *ThemeBackground `json:"background,omitempty"`
+}
+
+// ThemeColors — цвета в формате #rgb или #rrggbb
+type ThemeColors struct {
+    Background       string `json:"background,omitempty"`
+    Text             string `json:"text,omitempty"`
+    Muted            string `json:"muted,omitempty"` // второстепенный текст
+    Accent           string `json:"accent,omitempty"`
+    Surface          string `json:"surface,omitempty"` // карточки, формы
+    ButtonBackground string `json:"buttonBackground,omitempty"`
+    ButtonText       string `json:"buttonText,omitempty"`
+}
+
+// ThemeBackground — фон страницы: сплошной цвет, градиент или картинка
+type ThemeBackground struct {
+    Type     string `json:"type"` // color, gradient, image
+    From     string `json:"from,omitempty"`
+    To       string `json:"to,omitempty"`
+    Angle    int    `json:"angle,omitempty"`
+    ImageURL string `json:"imageUrl,omitempty"`
+}
+
+// Value сохраняет тему в JSONB
+func (t Theme) Value() (driver.Value, error) {
+    return json.Marshal(t)
+}
+
+// Scan читает тему из JSONB
+func (t *Theme) Scan(src interface{}) error {
+    var data []byte
+    switch v := src.(type) {
+    case []byte:
+        data = v
+    case string:
+        data = []byte(v)
+    case nil:
+        *t = Theme{}
+        return nil
+    default:
+        return fmt.Errorf("theme: unsupported type %T", src)
+    }
+    return json.Unmarshal(data, t)
+}
+
+// themeFont — шрифт из разрешённого списка
+type themeFont struct {
+    Stack  string // CSS font-family
+    Google string // семейство для Google Fonts, пусто — системный
+}
+
+var themeFonts = map[string]themeFont{
+    "system":           {Stack: `-apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif`},
+    "serif":            {Stack: `Georgia, "Times New Roman", serif`},
+    "mono":             {Stack: `ui-monospace, "SF Mono", Menlo, monospace`},
+    "Inter":            {Stack: `"Inter", sans-serif`, Google: "Inter:wght@400;500;600"},
+    "Roboto":           {Stack: `"Roboto", sans-serif`, Google: "Roboto:wght@400;500;700"},
+    "Open Sans":        {Stack: `"Open Sans", sans-serif`, Google: "Open+Sans:wght@400;600"},
+    "Montserrat":       {Stack: `"Montserrat", sans-serif`, Google: "Montserrat:wght@400;600;700"},
+    "PT Sans":          {Stack: `"PT Sans", sans-serif`, Google: "PT+Sans:wght@400;700"},
+    "PT Serif":         {Stack: `"PT Serif", serif`, Google: "PT+Serif:wght@400;700"},
+    "Lora":             {Stack: `"Lora", serif`, Google: "Lora:wght@400;600"},
+    "Playfair Display": {Stack: `"Playfair Display", serif`, Google: "Playfair+Display:wght@400;700"},
+    "Rubik":            {Stack: `"Rubik", sans-serif`, Google: "Rubik:wght@400;500;700"},
+}
+
+var buttonRadius = map[string]string{"square": "0", "rounded": "12px", "pill": "999px"}
+
+// themePresets — встроенные темы; пользовательская тема накладывается поверх
+var themePresets = map[string]Theme{
+    "light": {
+        Colors: ThemeColors{Background: "#f5f5f7", Text: "#1c1c1e", Muted: "#636366", Accent: "#007aff",
+            Surface: "#ffffff", ButtonBackground: "#ffffff", ButtonText: "#1c1c1e"},
+        Font: "system", HeadingFont: "system", ButtonShape: "rounded", ButtonStyle: "filled",
+    },
+    "dark": {
+        Colors: ThemeColors{Background: "#000000", Text: "#f2f2f7", Muted: "#aeaeb2", Accent: "#0a84ff",
+            Surface: "#1c1c1e", ButtonBackground: "#2c2c2e", ButtonText: "#f2f2f7"},
+        Font: "system", HeadingFont: "system", ButtonShape: "rounded", ButtonStyle: "filled",
+    },
+    "telegram": {
+        Colors: ThemeColors{Background: "#e7f3fb", Text: "#0f1419", Muted: "#4a5a68", Accent: "#2481cc",
+            Surface: "#ffffff", ButtonBackground: "#1f6fb2", ButtonText: "#ffffff"},
+        Font: "Roboto", HeadingFont: "Roboto", ButtonShape: "rounded", ButtonStyle: "filled",
+    },
+    "sunset": {
+        Colors: ThemeColors{Background: "#b23a48", Text: "#ffffff", Muted: "#ffe3d8", Accent: "#ffd6c9",
+            Surface: "#5e2a3e", ButtonBackground: "#ffffff", ButtonText: "#7a2e1d"},
+        Font: "Montserrat", HeadingFont: "Montserrat", ButtonShape: "pill", ButtonStyle: "filled",
+        Background: &ThemeBackground{Type: "gradient", From: "#b23a48", To: "#5e2a5e", Angle: 160},
+    },
+    "minimal": {
+        Colors: ThemeColors{Background: "#ffffff", Text: "#111111", Muted: "#555555", Accent: "#111111",
+            Surface: "#ffffff", ButtonBackground: "#ffffff", ButtonText: "#111111"},
+        Font: "Inter", HeadingFont: "Inter", ButtonShape: "square", ButtonStyle: "outline",
+    },
+    "editorial": {
+        Colors: ThemeColors{Background: "#faf7f2", Text: "#2b2118", Muted: "#6b5d4f", Accent: "#9c3d25",
+            Surface: "#ffffff", ButtonBackground: "#2b2118", ButtonText: "#faf7f2"},
+        Font: "Lora", HeadingFont: "Playfair Display", ButtonShape: "square", ButtonStyle: "filled",
+    },
+}
+
+const defaultThemePreset = "light"
+
+var hexColorRe = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// validateTheme проверяет токены темы
+func validateTheme(t Theme) fieldErrors {
+    errs := fieldErrors{}
+    if t.Preset != "" {
+        if _, ok := themePresets[t.Preset]; !ok {
+            errs["preset"] = "unknown preset"
+        }
+    }
+    colors := map[string]string{
+        "colors.background": t.Colors.Background, "colors.text": t.Colors.Text,
+        "colors.muted": t.Colors.Muted, "colors.accent": t.Colors.Accent,
+        "colors.surface": t.Colors.Surface, "colors.buttonBackground": t.Colors.ButtonBackground,
+        "colors.buttonText": t.Colors.ButtonText,
+    }
+    for field, v := range colors {
+        checkColor(errs, field, v, false)
+    }
+    for field, v := range map[string]string{"font": t.Font, "headingFont": t.HeadingFont} {
+        if _, ok := themeFonts[v]; v != "" && !ok {
+            errs[field] = "unsupported font"
+        }
+    }
+    if _, ok := buttonRadius[t.ButtonShape]; t.ButtonShape != "" && !ok {
+        errs["buttonShape"] = "must be one of square, rounded, pill"
+    }
+    switch t.ButtonStyle {
+    case "", "filled", "outline":
+    default:
+        errs["buttonStyle"] = "must be one of filled, outline"
+    }
+    if bg := t.Background; bg != nil {
+        switch bg.Type {
+        case "color":
+        case "gradient":
+            checkColor(errs, "background.from", bg.From, true)
+            checkColor(errs, "background.to", bg.To, true)
+            if bg.Angle < 0 || bg.Angle > 360 {
+                errs["background.angle"] = "must be between 0 and 360"
+            }
+        case "image":
+            checkURL(errs, "background.imageUrl", bg.ImageURL, true)
+            if u, err := url.Parse(bg.ImageURL); err == nil && u.Scheme != "https" && u.Scheme != "http" {
+                errs["background.imageUrl"] = "must be an http(s) URL"
+            }
+        default:
+            errs["background.type"] = "must be one of color, gradient, image"
+        }
+    }
+    if len(errs) > 0 {
+        return errs
+    }
+    return nil
+}
+
+func checkColor(errs fieldErrors, field, value string, required bool) {
+    if value == "" {
+        if required {
+            errs[field] = "is required"
+        }
+        return
+    }
+    if !hexColorRe.MatchString(value) {
+        errs[field] = "must be a hex color like #1a2b3c"
+    }
+}
+
+// resolveTheme накладывает тему на её пресет и возвращает полный набор токенов
+func resolveTheme(t Theme) Theme {
+    name := t.Preset
+    if _, ok := themePresets[name]; !ok {
+        name = defaultThemePreset
+    }
+    r := themePresets[name]
+    r.Preset = name
+    pick := func(dst *string, v string) {
+        if v != "" {
+            *dst = v
+        }
+    }
+    pick(&r.Colors.Background, t.Colors.Background)
+    pick(&r.Colors.Text, t.Colors.Text)
+    pick(&r.Colors.Muted, t.Colors.Muted)
+    pick(&r.Colors.Accent, t.Colors.Accent)
+    pick(&r.Colors.Surface, t.Colors.Surface)
+    pick(&r.Colors.ButtonBackground, t.Colors.ButtonBackground)
+    pick(&r.Colors.ButtonText, t.Colors.ButtonText)
+    pick(&r.Font, t.Font)
+    pick(&r.HeadingFont, t.HeadingFont)
+    pick(&r.ButtonShape, t.ButtonShape)
+    pick(&r.ButtonStyle, t.ButtonStyle)
+    if t.Background != nil {
+        bg := *t.Background
+        r.Background = &bg
+    }
+    if r.Background == nil {
+        r.Background = &ThemeBackground{Type: "color"}
+    }
+    return r
+}
+
+// themeWarning — предупреждение о плохо читаемом сочетании цветов
+type themeWarning struct {
+    Foreground string  `json:"foreground"`
+    Background string  `json:"background"`
+    Ratio      float64 `json:"ratio"`
+    Minimum    float64 `json:"minimum"`
+    Message    string  `json:"message"`
+}
+
+// themeWarnings проверяет контраст по WCAG 2.1: 4.5 для основного текста,
+// 3 для второстепенного и акцентов. На картинке контраст не посчитать.
+func themeWarnings(t Theme) []themeWarning {
+    r := resolveTheme(t)
+    pageBgs := map[string]string{"colors.background": r.Colors.Background}
+    switch r.Background.Type {
+    case "gradient":
+        pageBgs = map[string]string{"background.from": r.Background.From, "background.to": r.Background.To}
+    case "image":
+        pageBgs = map[string]string{}
+    }
+
+    type pair struct {
+        fg, fgName, bg, bgName string
+        min                    float64
+    }
+    var pairs []pair
+    for name, bg := range pageBgs {
+        pairs = append(pairs,
+            pair{r.Colors.Text, "colors.text", bg, name, 4.5},
+            pair{r.Colors.Muted, "colors.muted", bg, name, 3},
+        )
+    }
+    pairs = append(pairs,
+        pair{r.Colors.Text, "colors.text", r.Colors.Surface, "colors.surface", 4.5},
+        pair{r.Colors.Accent, "colors.accent", r.Colors.Surface, "colors.surface", 3},
+    )
+    if r.ButtonStyle == "outline" {
+        for name, bg := range pageBgs {
+            pairs = append(pairs, pair{r.Colors.ButtonText, "colors.buttonText", bg, name, 4.5})
+        }
+    } else {
+        pairs = append(pairs, pair{r.Colors.ButtonText, "colors.buttonText", r.Colors.ButtonBackground, "colors.buttonBackground", 4.5})
+    }
+
+    warnings := []themeWarning{}
+    for _, p := range pairs {
+        ratio := contrastRatio(p.fg, p.bg)
+        if ratio < p.min {
+            warnings = append(warnings, themeWarning{
+                Foreground: p.fgName,
+                Background: p.bgName,
+                Ratio:      math.Round(ratio*100) / 100,
+                Minimum:    p.min,
+                Message:    fmt.Sprintf("%s on %s is hard to read (%.2f:1, need %.1f:1)", p.fgName, p.bgName, ratio, p.min),
+            })
+        }
+    }
+    if r.Background.Type == "image" {
+        warnings = append(warnings, themeWarning{
+            Background: "background.imageUrl",
+            Message:    "contrast cannot be checked against a background image, make sure the text stays readable",
+        })
+    }
+    sort.Slice(warnings, func(i, j int) bool { return warnings[i].Message < warnings[j].Message })
+    return warnings
+}
+
+// contrastRatio — отношение контраста двух цветов по WCAG (от 1 до 21)
+func contrastRatio(a, b string) float64 {
+    la, lb := relativeLuminance(a), relativeLuminance(b)
+    if la < lb {
+        la, lb = lb, la
+    }
+    return (la + 0.05) / (lb + 0.05)
+}
+
+func relativeLuminance(hex string) float64 {
+    r, g, b := parseHexColor(hex)
+    channel := func(v uint8) float64 {
+        c := float64(v) / 255
+        if c <= 0.03928 {
+            return c / 12.92
+        }
+        return math.Pow((c+0.055)/1.055, 2.4)
+    }
+    return 0.2126*channel(r) + 0.7152*channel(g) + 0.0722*channel(b)
+}
+
+func parseHexColor(hex string) (r, g, b uint8) {
+    s := strings.TrimPrefix(hex, "#")
+    if len(s) == 3 {
+        s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+    }
+    v, err := strconv.ParseUint(s, 16, 32)
+    if err != nil || len(s) != 6 {
+        return 0, 0, 0
+    }
+    return uint8(v >> 16), uint8(v >> 8), uint8(v)
+}
+
+// themeCSS превращает тему в CSS custom properties. Значения уже
+// провалидированы, поэтому их можно вставлять в <style> как есть.
+func themeCSS(t Theme) template.CSS {
+    r := resolveTheme(t)
+    background := r.Colors.Background
+    switch r.Background.Type {
+    case "gradient":
+        background = fmt.Sprintf("linear-gradient(%ddeg, %s, %s)", r.Background.Angle, r.Background.From, r.Background.To)
+    case "image":
+        background = fmt.Sprintf("%s url(\"%s\") center / cover no-repeat fixed", r.Colors.Background, cssURL(r.Background.ImageURL))
+    }
+    buttonBg, buttonBorder := r.Colors.ButtonBackground, r.Colors.ButtonBackground
+    if r.ButtonStyle == "outline" {
+        buttonBg, buttonBorder = "transparent", r.Colors.ButtonText
+    }
+
+    var b bytes.Buffer
+    b.WriteString(":root {")
+    for _, v := range [][2]string{
+        {"--color-bg", r.Colors.Background},
+        {"--color-text", r.Colors.Text},
+        {"--color-muted", r.Colors.Muted},
+        {"--color-accent", r.Colors.Accent},
+        {"--color-surface", r.Colors.Surface},
+        {"--button-bg", buttonBg},
+        {"--button-border", buttonBorder},
+        {"--button-text", r.Colors.ButtonText},
+        {"--button-radius", buttonRadius[r.ButtonShape]},
+        {"--font-body", themeFonts[r.Font].Stack},
+        {"--font-heading", themeFonts[r.HeadingFont].Stack},
+        {"--page-bg", background},
+    } {
+        fmt.Fprintf(&b, " %s: %s;", v[0], v[1])
+    }
+    b.WriteString(" }")
+    return template.CSS(b.String())
+}
+
+// cssURL экранирует символы, которые могут закрыть url("...") в CSS
+func cssURL(raw string) string {
+    var b strings.Builder
+    for _, r := range raw {
+        switch {
+        case r == '"' || r == '\'' || r == '(' || r == ')' || r == '\\' || r == '<' || r == '>' || r <= ' ':
+            fmt.Fprintf(&b, "%%%02X", r)
+        default:
+            b.WriteRune(r)
+        }
+    }
+    return b.String()
+}
+
+// themeFontsURL возвращает адрес стилей Google Fonts для шрифтов темы
+func themeFontsURL(t Theme) string {
+    r := resolveTheme(t)
+    var families []string
+    for _, name := range []string{r.Font, r.HeadingFont} {
+        if f := themeFonts[name]; f.Google != "" && !containsString(families, "family="+f.Google) {
+            families = append(families, "family="+f.Google)
+        }
+    }
+    if len(families) == 0 {
+        return ""
+    }
+    return "https://fonts.googleapis.com/css2?" + strings.Join(families, "&") + "&display=swap"
+}
+
+func containsString(list []string, s string) bool {
+    for _, v := range list {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
+
+// registerThemeRoutes регистрирует тему внутри /landings/:id
+func registerThemeRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+    read, write := requireScope(ScopeLandingsRead), requireScope(ScopeLandingsWrite)
+    r.GET("/:id/theme", read, requireLandingRole(db, "id", RoleViewer), getTheme(db))
+    r.PUT("/:id/theme", write, requireLandingRole(db, "id", RoleEditor), updateTheme(db))
+}
+
+// listThemePresets возвращает встроенные пресеты с полным набором токенов
+func listThemePresets(c *gin.Context) {
+    names := make([]string, 0, len(themePresets))
+    for name := range themePresets {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    items := make([]Theme, len(names))
+    for i, name := range names {
+        items[i] = resolveTheme(Theme{Preset: name})
+    }
+    c.JSON(http.StatusOK, items)
+}
+
+// themeResponse — тема лендинга, её итоговые токены и предупреждения о контрасте
+type themeResponse struct {
+    Theme    Theme          `json:"theme"`
+    Resolved Theme          `json:"resolved"`
+    Warnings []themeWarning `json:"warnings"`
+}
+
+func newThemeResponse(t Theme) themeResponse {
+    return themeResponse{Theme: t, Resolved: resolveTheme(t), Warnings: themeWarnings(t)}
+}
+
+func getTheme(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var theme Theme
+        if err := db.Get(&theme, "SELECT theme FROM landings WHERE id=$1", c.GetInt(ctxLandingID)); err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+        c.JSON(http.StatusOK, newThemeResponse(theme))
+    }
+}
+
+// updateTheme заменяет тему черновика. Плохой контраст не блокирует
+// сохранение — он возвращается в warnings.
+func updateTheme(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var theme Theme
+        dec := json.NewDecoder(c.Request.Body)
+        dec.DisallowUnknownFields()
+        if err := dec.Decode(&theme); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        if errs := validateTheme(theme); errs != nil {
+            respondFieldErrors(c, errs)
+            return
+        }
+        if _, err := db.Exec("UPDATE landings SET theme=$1, updated_at=NOW() WHERE id=$2", theme, c.GetInt(ctxLandingID)); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusOK, newThemeResponse(theme))
+    }
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+    "time"
+
+    "github.com/jmoiron/sqlx"
+)
+
+// User — пользователь сервиса, заведённый при первом входе через Telegram
+type User struct {
+    ID           int       `db:"id" json:"id"`
+    TelegramID   int64     `db:"telegram_id" json:"telegramId"`
+    Username     string    `db:"username" json:"username"`
+    FirstName    string    `db:"first_name" json:"firstName"`
+    LastName     string    `db:"last_name" json:"lastName"`
+    LanguageCode string    `db:"language_code" json:"languageCode"`
+    IsPremium    bool      `db:"is_premium" json:"isPremium"`
+    PhotoURL     string    `db:"photo_url" json:"photoUrl"`
+    CreatedAt    time.Time `db:"created_at" json:"createdAt"`
+    UpdatedAt    time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+// TelegramProfile — данные пользователя, которые присылает Telegram при входе.
+// Login Widget не сообщает язык и Premium: пустой LanguageCode и nil IsPremium
+// оставляют сохранённые ранее значения.
+type TelegramProfile struct {
+    ID           int64
+    Username     string
+    FirstName    string
+    LastName     string
+    LanguageCode string
+    IsPremium    *bool
+    PhotoURL     string
+}
+
+// DisplayName возвращает username, а если его нет — имя и фамилию
+func (u User) DisplayName() string {
+    if u.Username != "" {
+        return u.Username
+    }
+    name := u.FirstName
+    if u.LastName != "" {
+        if name != "" {
+            name += " "
+        }
+        name += u.LastName
+    }
+    return name
+}
+
+// upsertTelegramUser создаёт пользователя по telegram_id или обновляет его профиль
+func upsertTelegramUser(db sqlx.Queryer, p TelegramProfile) (User, error) {
+    var u User
+    query := `INSERT INTO users (telegram_id, username, first_name, last_name, language_code, is_premium, photo_url, created_at, updated_at)
+              VALUES ($1,$2,$3,$4,$5,COALESCE($6::boolean, FALSE),$7,NOW(),NOW())
+              ON CONFLICT (telegram_id) DO UPDATE SET
+                  username=EXCLUDED.username,
+                  first_name=EXCLUDED.first_name,
+                  last_name=EXCLUDED.last_name,
+                  language_code=COALESCE(NULLIF(EXCLUDED.language_code, ''), users.language_code),
+                  is_premium=COALESCE($6::boolean, users.is_premium),
+                  photo_url=COALESCE(NULLIF(EXCLUDED.photo_url, ''), users.photo_url),
+                  updated_at=NOW()
+              RETURNING *`
+    err := sqlx.Get(db, &u, query,
+        p.ID, p.Username, p.FirstName, p.LastName, p.LanguageCode, p.IsPremium, p.PhotoURL)
+    return u, err
+}
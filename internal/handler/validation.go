@@ -0,0 +1,29 @@
+package handler
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "github.com/lib/pq"
+)
+
+// fieldErrors — ошибки валидации по отдельным полям запроса (поле -> сообщение)
+type fieldErrors map[string]string
+
+// Error позволяет вернуть ошибки валидации из функций, работающих в транзакции
+func (e fieldErrors) Error() string {
+    return "validation failed"
+}
+
+// respondFieldErrors отвечает 400 со списком ошибок по полям
+func respondFieldErrors(c *gin.Context, errs fieldErrors) {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "fields": errs})
+}
+
+// isUniqueViolation сообщает, что запрос упал на уникальном индексе
+func isUniqueViolation(err error) bool {
+    if pqErr, ok := err.(*pq.Error); ok {
+        return pqErr.Code == "23505"
+    }
+    return false
+}
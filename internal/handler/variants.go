@@ -0,0 +1,214 @@
+package handler
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/jmoiron/sqlx"
+    "github.com/lib/pq"
+
+    "github.com/blagoweb/bbtg/internal/imaging"
+)
+
+// MediaVariant — уменьшенная копия загруженной картинки
+type MediaVariant struct {
+    ID        int       `db:"id" json:"id"`
+    MediaID   int       `db:"media_id" json:"mediaId"`
+    Format    string    `db:"format" json:"format"` // jpeg; png и webp для картинок с прозрачностью
+    Width     int       `db:"width" json:"width"`
+    Height    int       `db:"height" json:"height"`
+    ObjectKey string    `db:"object_key" json:"-"`
+    URL       string    `db:"url" json:"url"`
+    Size      int       `db:"size" json:"size"`
+    CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
+
+// Ширины вариантов по видам загрузок; аватары режутся в квадрат
+var variantWidths = map[string][]int{
+    MediaAvatar: {128, 256, 512},
+    MediaImage:  {480, 960, 1440},
+}
+
+// mainMaxSide — до какого размера уменьшается сам загруженный файл
+var mainMaxSide = map[string]int{
+    MediaAvatar: 1024,
+    MediaImage:  2560,
+}
+
+// maxImagePixels ограничивает память на декодирование (~4 байта на пиксель)
+const maxImagePixels = 50_000_000
+
+// imageSlots ограничивает число одновременно обрабатываемых картинок
+var imageSlots = make(chan struct{}, 2)
+
+// processedImage — результат обработки загрузки
+type processedImage struct {
+    Data        []byte
+    ContentType string
+    Width       int
+    Height      int
+    Variants    []processedVariant
+}
+
+type processedVariant struct {
+    Format string
+    Width  int
+    Height int
+    Data   []byte
+}
+
+// processImage исправляет ориентацию, убирает метаданные перекодированием,
+// ограничивает размер и строит варианты в JPEG, а для картинок с прозрачностью
+// — в PNG и WebP (в JPEG фон стал бы белым). GIF сохраняется как есть,
+// чтобы не потерять анимацию.
+func processImage(kind string, data []byte, contentType string) (processedImage, error) {
+    if contentType == "image/gif" {
+        return processedImage{Data: data, ContentType: contentType}, nil
+    }
+    imageSlots <- struct{}{}
+    defer func() { <-imageSlots }()
+
+    img, format, err := imaging.Decode(data, maxImagePixels)
+    if errors.Is(err, imaging.ErrTooLarge) {
+        return processedImage{}, errMediaDimensions
+    }
+    if err != nil {
+        return processedImage{}, errMediaCorrupt
+    }
+    img = imaging.Fit(img, mainMaxSide[kind])
+
+    // фото остаются JPEG, графика и картинки с прозрачностью — PNG
+    out := processedImage{ContentType: "image/jpeg", Width: img.Rect.Dx(), Height: img.Rect.Dy()}
+    mainFormat := imaging.FormatJPEG
+    if format == "png" || !imaging.Opaque(img) {
+        mainFormat, out.ContentType = imaging.FormatPNG, "image/png"
+    }
+    if out.Data, err = imaging.Encode(img, mainFormat); err != nil {
+        return out, err
+    }
+    // WebP у нас только lossless: рядом с PNG он обычно легче, а рядом с
+    // JPEG фотографии выходит в разы тяжелее — для таких вариантов его не строим
+    formats := []string{imaging.FormatJPEG}
+    if !imaging.Opaque(img) {
+        formats = []string{imaging.FormatPNG, imaging.FormatWebP}
+    }
+
+    for _, w := range variantWidths[kind] {
+        v := img
+        if kind == MediaAvatar {
+            if w > img.Rect.Dx() || w > img.Rect.Dy() {
+                continue
+            }
+            v = imaging.Square(img, w)
+        } else {
+            if w >= img.Rect.Dx() {
+                continue
+            }
+            v = imaging.ResizeWidth(img, w)
+        }
+        for _, f := range formats {
+            b, err := imaging.Encode(v, f)
+            if err != nil {
+                return out, err
+            }
+            out.Variants = append(out.Variants, processedVariant{Format: f, Width: v.Rect.Dx(), Height: v.Rect.Dy(), Data: b})
+        }
+    }
+    return out, nil
+}
+
+// variantKey выводит ключ варианта из ключа основного файла:
+// media/<sha256>.jpg → media/<sha256>-480.webp
+func variantKey(mainKey string, width int, format string) string {
+    base := mainKey
+    if i := strings.LastIndex(base, "."); i > strings.LastIndex(base, "/") {
+        base = base[:i]
+    }
+    ext := ".jpg"
+    switch format {
+    case imaging.FormatWebP:
+        ext = ".webp"
+    case imaging.FormatPNG:
+        ext = ".png"
+    }
+    return fmt.Sprintf("%s-%d%s", base, width, ext)
+}
+
+// loadVariants подгружает варианты к записям media
+func loadVariants(q sqlx.Queryer, items []Media) error {
+    if len(items) == 0 {
+        return nil
+    }
+    ids := make([]int64, len(items))
+    index := map[int]int{}
+    for i := range items {
+        ids[i] = int64(items[i].ID)
+        index[items[i].ID] = i
+        items[i].Variants = []MediaVariant{}
+    }
+    var variants []MediaVariant
+    query := `SELECT * FROM media_variants WHERE media_id = ANY($1) ORDER BY format, width`
+    if err := sqlx.Select(q, &variants, query, pq.Int64Array(ids)); err != nil {
+        return err
+    }
+    for _, v := range variants {
+        i := index[v.MediaID]
+        items[i].Variants = append(items[i].Variants, v)
+    }
+    return nil
+}
+
+// srcSet — готовые значения srcset для одной картинки
+type srcSet struct {
+    Fallback string // JPEG или PNG — для браузеров без WebP
+    WebP     string // пусто, если WebP не выгоднее запасного формата
+}
+
+// loadSrcSets находит варианты для картинок страницы по их URL
+func loadSrcSets(q sqlx.Queryer, urls []string) (map[string]srcSet, error) {
+    result := map[string]srcSet{}
+    if len(urls) == 0 {
+        return result, nil
+    }
+    var rows []struct {
+        MediaURL string `db:"media_url"`
+        MediaVariant
+    }
+    query := `SELECT DISTINCT ON (m.url, v.format, v.width) m.url AS media_url, v.*
+              FROM media m JOIN media_variants v ON v.media_id = m.id
+              WHERE m.url = ANY($1)
+              ORDER BY m.url, v.format, v.width`
+    if err := sqlx.Select(q, &rows, query, pq.StringArray(urls)); err != nil {
+        return nil, err
+    }
+
+    type sizes struct {
+        fallback, webp         []string
+        fallbackSize, webpSize int
+    }
+    byURL := map[string]*sizes{}
+    for _, r := range rows {
+        s := byURL[r.MediaURL]
+        if s == nil {
+            s = &sizes{}
+            byURL[r.MediaURL] = s
+        }
+        entry := fmt.Sprintf("%s %dw", r.URL, r.Width)
+        if r.Format == imaging.FormatWebP {
+            s.webp, s.webpSize = append(s.webp, entry), s.webpSize+r.Size
+        } else {
+            s.fallback, s.fallbackSize = append(s.fallback, entry), s.fallbackSize+r.Size
+        }
+    }
+    for u, s := range byURL {
+        set := srcSet{Fallback: strings.Join(s.fallback, ", ")}
+        // WebP у нас lossless: отдаём его, только если он легче запасного формата
+        if len(s.webp) == len(s.fallback) && s.webpSize < s.fallbackSize {
+            set.WebP = strings.Join(s.webp, ", ")
+        }
+        result[u] = set
+    }
+    return result, nil
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+    "bytes"
+    "image"
+    "image/color"
+    "image/jpeg"
+    "image/png"
+    "testing"
+
+    "github.com/blagoweb/bbtg/internal/imaging"
+)
+
+// testJPEG кодирует непрозрачную картинку w×h
+func testJPEG(t *testing.T, w, h int) []byte {
+    t.Helper()
+    img := image.NewRGBA(image.Rect(0, 0, w, h))
+    for x := 0; x < w; x++ {
+        for y := 0; y < h; y++ {
+            img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+        }
+    }
+    var buf bytes.Buffer
+    if err := jpeg.Encode(&buf, img, nil); err != nil {
+        t.Fatal(err)
+    }
+    return buf.Bytes()
+}
+
+func TestProcessImageVariants(t *testing.T) {
+    tests := []struct {
+        name   string
+        kind   string
+        w, h   int
+        widths []int // ожидаемые ширины вариантов
+        square bool
+    }{
+        {"photo", MediaImage, 1200, 800, []int{480, 960}, false},
+        {"small photo", MediaImage, 400, 300, nil, false},
+        {"avatar", MediaAvatar, 600, 400, []int{128, 256}, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            out, err := processImage(tt.kind, testJPEG(t, tt.w, tt.h), "image/jpeg")
+            if err != nil {
+                t.Fatal(err)
+            }
+            if out.ContentType != "image/jpeg" {
+                t.Errorf("content type = %s, want image/jpeg", out.ContentType)
+            }
+            got := map[int]int{}
+            for _, v := range out.Variants {
+                got[v.Width]++
+                // lossless WebP для фото тяжелее JPEG и не строится
+                if v.Format != imaging.FormatJPEG {
+                    t.Errorf("variant %dw is %s, want jpeg", v.Width, v.Format)
+                }
+                if tt.square && v.Width != v.Height {
+                    t.Errorf("avatar variant %dx%d is not square", v.Width, v.Height)
+                }
+            }
+            if len(got) != len(tt.widths) {
+                t.Fatalf("variant widths = %v, want %v", got, tt.widths)
+            }
+            for _, w := range tt.widths {
+                if got[w] == 0 {
+                    t.Errorf("no variant %dw", w)
+                }
+            }
+        })
+    }
+}
+
+func TestProcessImageKeepsTransparencyInVariants(t *testing.T) {
+    img := image.NewNRGBA(image.Rect(0, 0, 1000, 500))
+    for x := 0; x < 500; x++ {
+        for y := 0; y < 500; y++ {
+            img.Set(x, y, color.NRGBA{R: 200, A: 255})
+        }
+    }
+    var buf bytes.Buffer
+    if err := png.Encode(&buf, img); err != nil {
+        t.Fatal(err)
+    }
+
+    out, err := processImage(MediaImage, buf.Bytes(), "image/png")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(out.Variants) == 0 {
+        t.Fatal("no variants")
+    }
+    formats := map[string]int{}
+    for _, v := range out.Variants {
+        formats[v.Format]++
+        if v.Format != imaging.FormatPNG && v.Format != imaging.FormatWebP {
+            t.Errorf("variant %dw is %s, transparent images need png or webp", v.Width, v.Format)
+        }
+    }
+    if formats[imaging.FormatPNG] == 0 || formats[imaging.FormatPNG] != formats[imaging.FormatWebP] {
+        t.Errorf("variant formats = %v, want png and webp for every width", formats)
+    }
+}
+
+func TestVariantKey(t *testing.T) {
+    if got := variantKey("media/abc.jpg", 480, "webp"); got != "media/abc-480.webp" {
+        t.Errorf("variantKey = %q", got)
+    }
+    if got := variantKey("media/abc.png", 960, "jpeg"); got != "media/abc-960.jpg" {
+        t.Errorf("variantKey = %q", got)
+    }
+    if got := variantKey("media/abc.png", 960, "png"); got != "media/abc-960.png" {
+        t.Errorf("variantKey = %q", got)
+    }
+}
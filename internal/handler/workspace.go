@@ -0,0 +1,506 @@
+package handler
+
+import (
+    "database/sql"
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/jmoiron/sqlx"
+
+    "github.com/blagoweb/bbtg/internal/telegram"
+)
+
+// invitationTTL — сколько действует приглашение в пространство
+const invitationTTL = 7 * 24 * time.Hour
+
+// Workspace — рабочее пространство: лендинги принадлежат ему, а не пользователю
+type Workspace struct {
+    ID         int       `db:"id" json:"id"`
+    Name       string    `db:"name" json:"name"`
+    IsPersonal bool      `db:"is_personal" json:"isPersonal"`
+    CreatedBy  *int      `db:"created_by" json:"createdBy"`
+    CreatedAt  time.Time `db:"created_at" json:"createdAt"`
+    UpdatedAt  time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+// WorkspaceMember — участник пространства с ролью
+type WorkspaceMember struct {
+    WorkspaceID int       `db:"workspace_id" json:"workspaceId"`
+    UserID      int       `db:"user_id" json:"userId"`
+    Role        string    `db:"role" json:"role"`
+    Username    string    `db:"username" json:"username"`
+    FirstName   string    `db:"first_name" json:"firstName"`
+    LastName    string    `db:"last_name" json:"lastName"`
+    CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+}
+
+// WorkspaceInvitation — приглашение в пространство по deep link
+type WorkspaceInvitation struct {
+    ID          int        `db:"id" json:"id"`
+    WorkspaceID int        `db:"workspace_id" json:"workspaceId"`
+    Token       string     `db:"token" json:"token"`
+    Role        string     `db:"role" json:"role"`
+    InvitedBy   *int       `db:"invited_by" json:"invitedBy"`
+    ExpiresAt   time.Time  `db:"expires_at" json:"expiresAt"`
+    AcceptedBy  *int       `db:"accepted_by" json:"acceptedBy"`
+    AcceptedAt  *time.Time `db:"accepted_at" json:"acceptedAt"`
+    CreatedAt   time.Time  `db:"created_at" json:"createdAt"`
+}
+
+// RegisterWorkspaceRoutes регистрирует маршруты рабочих пространств.
+// bot нужен для ссылок-приглашений вида t.me/<bot>?startapp=inv_<token>.
+func RegisterWorkspaceRoutes(rg *gin.RouterGroup, db *sqlx.DB, bot *telegram.Bot) {
+    r := rg.Group("/workspaces")
+    r.Use(requireSession())
+    r.GET("", listWorkspaces(db))
+    r.POST("", createWorkspace(db))
+    r.GET("/:id", requireWorkspaceRole(db, "id", RoleViewer), getWorkspace(db))
+    r.PUT("/:id", requireWorkspaceRole(db, "id", RoleOwner), updateWorkspace(db))
+    r.DELETE("/:id", requireWorkspaceRole(db, "id", RoleOwner), deleteWorkspace(db))
+    r.GET("/:id/members", requireWorkspaceRole(db, "id", RoleViewer), listMembers(db))
+    r.PUT("/:id/members/:userId", requireWorkspaceRole(db, "id", RoleOwner), updateMember(db))
+    r.DELETE("/:id/members/:userId", requireWorkspaceRole(db, "id", RoleViewer), removeMember(db))
+    r.GET("/:id/invitations", requireWorkspaceRole(db, "id", RoleOwner), listInvitations(db))
+    r.POST("/:id/invitations", requireWorkspaceRole(db, "id", RoleOwner), createInvitation(db, bot))
+    r.DELETE("/:id/invitations/:invitationId", requireWorkspaceRole(db, "id", RoleOwner), deleteInvitation(db))
+
+    inv := rg.Group("/invitations")
+    inv.Use(requireSession())
+    inv.POST("/:token/accept", acceptInvitation(db))
+}
+
+// ensurePersonalWorkspace заводит пользователю личное пространство, если его ещё нет
+func ensurePersonalWorkspace(db *sqlx.DB, user User) error {
+    tx, err := db.Beginx()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    name := user.DisplayName()
+    if name == "" {
+        name = "Personal"
+    }
+    var id int
+    err = tx.Get(&id, `INSERT INTO workspaces (name, is_personal, created_by) VALUES ($1, TRUE, $2)
+                       ON CONFLICT (created_by) WHERE is_personal DO NOTHING RETURNING id`, name, user.ID)
+    if err == sql.ErrNoRows {
+        return nil // уже есть
+    }
+    if err != nil {
+        return err
+    }
+    if _, err := tx.Exec(`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1,$2,$3)`,
+        id, user.ID, RoleOwner); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// personalWorkspaceID возвращает личное пространство пользователя
+func personalWorkspaceID(q sqlx.Queryer, userID int) (int, error) {
+    var id int
+    err := sqlx.Get(q, &id, `SELECT id FROM workspaces WHERE is_personal AND created_by=$1`, userID)
+    return id, err
+}
+
+// listWorkspaces возвращает пространства пользователя вместе с его ролью
+func listWorkspaces(db *sqlx.DB) gin.HandlerFunc {
+    type item struct {
+        Workspace
+        Role string `db:"role" json:"role"`
+    }
+    return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+        var items []item
+        query := `SELECT w.*, m.role FROM workspaces w
+                  JOIN workspace_members m ON m.workspace_id = w.id
+                  WHERE m.user_id=$1
+                  ORDER BY w.is_personal DESC, w.created_at`
+        if err := db.Select(&items, query, uid); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusOK, items)
+    }
+}
+
+// createWorkspace создаёт командное пространство; создатель становится владельцем
+func createWorkspace(db *sqlx.DB) gin.HandlerFunc {
+    type request struct {
+        Name string `json:"name" binding:"required"`
+    }
+    return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+
+        tx, err := db.Beginx()
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        defer tx.Rollback()
+
+        var ws Workspace
+        if err := tx.Get(&ws, `INSERT INTO workspaces (name, created_by) VALUES ($1,$2) RETURNING *`,
+            req.Name, uid); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if _, err := tx.Exec(`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1,$2,$3)`,
+            ws.ID, uid, RoleOwner); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if err := tx.Commit(); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusCreated, ws)
+    }
+}
+
+// getWorkspace возвращает пространство по ID
+func getWorkspace(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var ws Workspace
+        if err := db.Get(&ws, "SELECT * FROM workspaces WHERE id=$1", c.GetInt(ctxWorkspaceID)); err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+        c.JSON(http.StatusOK, ws)
+    }
+}
+
+// updateWorkspace переименовывает пространство
+func updateWorkspace(db *sqlx.DB) gin.HandlerFunc {
+    type request struct {
+        Name string `json:"name" binding:"required"`
+    }
+    return func(c *gin.Context) {
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        var ws Workspace
+        query := `UPDATE workspaces SET name=$1, updated_at=NOW() WHERE id=$2 RETURNING *`
+        if err := db.Get(&ws, query, req.Name, c.GetInt(ctxWorkspaceID)); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusOK, ws)
+    }
+}
+
+// deleteWorkspace удаляет командное пространство вместе с его лендингами.
+// Личное пространство удалить нельзя.
+func deleteWorkspace(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        res, err := db.Exec("DELETE FROM workspaces WHERE id=$1 AND NOT is_personal", c.GetInt(ctxWorkspaceID))
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if n, _ := res.RowsAffected(); n == 0 {
+            c.JSON(http.StatusConflict, gin.H{"error": "personal workspace cannot be deleted"})
+            return
+        }
+        c.Status(http.StatusNoContent)
+    }
+}
+
+// listMembers возвращает участников пространства
+func listMembers(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var items []WorkspaceMember
+        query := `SELECT m.workspace_id, m.user_id, m.role, m.created_at,
+                         COALESCE(u.username, '') AS username,
+                         COALESCE(u.first_name, '') AS first_name,
+                         COALESCE(u.last_name, '') AS last_name
+                  FROM workspace_members m
+                  JOIN users u ON u.id = m.user_id
+                  WHERE m.workspace_id=$1
+                  ORDER BY m.created_at`
+        if err := db.Select(&items, query, c.GetInt(ctxWorkspaceID)); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusOK, items)
+    }
+}
+
+// updateMember меняет роль участника. Последнего владельца понизить нельзя.
+func updateMember(db *sqlx.DB) gin.HandlerFunc {
+    type request struct {
+        Role string `json:"role" binding:"required"`
+    }
+    return func(c *gin.Context) {
+        memberID, err := strconv.Atoi(c.Param("userId"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+            return
+        }
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        if _, ok := roleRank[req.Role]; !ok {
+            respondFieldErrors(c, fieldErrors{"role": "must be one of owner, editor, viewer"})
+            return
+        }
+
+        workspaceID := c.GetInt(ctxWorkspaceID)
+        tx, err := db.Beginx()
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        defer tx.Rollback()
+
+        if req.Role != RoleOwner {
+            if last, err := isLastOwner(tx, workspaceID, memberID); err != nil || last {
+                respondLastOwner(c, err)
+                return
+            }
+        }
+        res, err := tx.Exec(`UPDATE workspace_members SET role=$1 WHERE workspace_id=$2 AND user_id=$3`,
+            req.Role, workspaceID, memberID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if n, _ := res.RowsAffected(); n == 0 {
+            c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+        if err := tx.Commit(); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.Status(http.StatusNoContent)
+    }
+}
+
+// removeMember исключает участника. Владелец может исключить любого,
+// остальные — только выйти сами. Последний владелец уйти не может.
+func removeMember(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+        memberID, err := strconv.Atoi(c.Param("userId"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+            return
+        }
+
+        workspaceID := c.GetInt(ctxWorkspaceID)
+        tx, err := db.Beginx()
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        defer tx.Rollback()
+
+        if memberID != uid {
+            role, err := workspaceRole(tx, workspaceID, uid)
+            if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                return
+            }
+            if !checkRole(c, role, RoleOwner) {
+                return
+            }
+        }
+        if last, err := isLastOwner(tx, workspaceID, memberID); err != nil || last {
+            respondLastOwner(c, err)
+            return
+        }
+        if _, err := tx.Exec(`DELETE FROM workspace_members WHERE workspace_id=$1 AND user_id=$2`,
+            workspaceID, memberID); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if err := tx.Commit(); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.Status(http.StatusNoContent)
+    }
+}
+
+// isLastOwner сообщает, что userID — единственный владелец пространства.
+// Строки владельцев блокируются, чтобы два параллельных запроса не оставили
+// пространство без владельца.
+func isLastOwner(tx *sqlx.Tx, workspaceID, userID int) (bool, error) {
+    var owners []int
+    err := tx.Select(&owners, `SELECT user_id FROM workspace_members
+                               WHERE workspace_id=$1 AND role=$2 FOR UPDATE`, workspaceID, RoleOwner)
+    if err != nil {
+        return false, err
+    }
+    return len(owners) == 1 && owners[0] == userID, nil
+}
+
+func respondLastOwner(c *gin.Context, err error) {
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    c.JSON(http.StatusConflict, gin.H{"error": "workspace must keep at least one owner"})
+}
+
+// listInvitations возвращает неиспользованные приглашения пространства
+func listInvitations(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var items []WorkspaceInvitation
+        query := `SELECT * FROM workspace_invitations
+                  WHERE workspace_id=$1 AND accepted_at IS NULL AND expires_at > NOW()
+                  ORDER BY created_at DESC`
+        if err := db.Select(&items, query, c.GetInt(ctxWorkspaceID)); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusOK, items)
+    }
+}
+
+// createInvitation выпускает приглашение и ссылку на Mini App с ним
+func createInvitation(db *sqlx.DB, bot *telegram.Bot) gin.HandlerFunc {
+    type request struct {
+        Role string `json:"role" binding:"required"`
+    }
+    return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+        var req request
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        if req.Role != RoleEditor && req.Role != RoleViewer {
+            respondFieldErrors(c, fieldErrors{"role": "must be editor or viewer"})
+            return
+        }
+
+        // start_param допускает только [A-Za-z0-9_-] и до 64 символов
+        token, err := randomToken(24)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+
+        var inv WorkspaceInvitation
+        query := `INSERT INTO workspace_invitations (workspace_id, token, role, invited_by, expires_at)
+                  VALUES ($1,$2,$3,$4,$5) RETURNING *`
+        if err := db.Get(&inv, query, c.GetInt(ctxWorkspaceID), token, req.Role, uid,
+            time.Now().Add(invitationTTL)); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+
+        // Mini App получит "inv_<token>" в start_param и вызовет accept
+        link := ""
+        if bot != nil {
+            link = fmt.Sprintf("https://t.me/%s?startapp=inv_%s", bot.Username(), token)
+        }
+        c.JSON(http.StatusCreated, gin.H{"invitation": inv, "link": link})
+    }
+}
+
+// deleteInvitation отзывает приглашение
+func deleteInvitation(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        invitationID, err := strconv.Atoi(c.Param("invitationId"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid invitationId"})
+            return
+        }
+        res, err := db.Exec(`DELETE FROM workspace_invitations WHERE id=$1 AND workspace_id=$2`,
+            invitationID, c.GetInt(ctxWorkspaceID))
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if n, _ := res.RowsAffected(); n == 0 {
+            c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+        c.Status(http.StatusNoContent)
+    }
+}
+
+// acceptInvitation добавляет текущего пользователя в пространство по приглашению.
+// Если он уже участник, роль не понижается.
+func acceptInvitation(db *sqlx.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        uid, ok := currentUserID(c)
+        if !ok {
+            return
+        }
+
+        tx, err := db.Beginx()
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        defer tx.Rollback()
+
+        var inv WorkspaceInvitation
+        query := `SELECT * FROM workspace_invitations
+                  WHERE token=$1 AND accepted_at IS NULL AND expires_at > NOW()
+                  FOR UPDATE`
+        if err := tx.Get(&inv, query, c.Param("token")); err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "invitation not found or expired"})
+            return
+        }
+
+        role, err := workspaceRole(tx, inv.WorkspaceID, uid)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if !roleAtLeast(role, inv.Role) {
+            if _, err := tx.Exec(`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1,$2,$3)
+                                  ON CONFLICT (workspace_id, user_id) DO UPDATE SET role=EXCLUDED.role`,
+                inv.WorkspaceID, uid, inv.Role); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                return
+            }
+        }
+        if _, err := tx.Exec(`UPDATE workspace_invitations SET accepted_by=$1, accepted_at=NOW() WHERE id=$2`,
+            uid, inv.ID); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+
+        var ws Workspace
+        if err := tx.Get(&ws, "SELECT * FROM workspaces WHERE id=$1", inv.WorkspaceID); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if err := tx.Commit(); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusOK, ws)
+    }
+}
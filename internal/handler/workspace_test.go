@@ -0,0 +1,188 @@
+package handler
+
+import (
+    "net/http"
+    "regexp"
+    "testing"
+    "time"
+
+    "github.com/DATA-DOG/go-sqlmock"
+    "github.com/gin-gonic/gin"
+)
+
+const (
+    workspaceRoleQuery = "SELECT role FROM workspace_members WHERE workspace_id=$1 AND user_id=$2"
+    ownersQuery        = "SELECT user_id FROM workspace_members"
+    invitationQuery    = "SELECT * FROM workspace_invitations"
+)
+
+var invitationColumns = []string{"id", "workspace_id", "token", "role", "invited_by", "expires_at",
+    "accepted_by", "accepted_at", "created_at"}
+
+func newWorkspaceRouter(t *testing.T, userID int) (*gin.Engine, sqlmock.Sqlmock) {
+    db, mock := newMockDB(t)
+    router := newTestRouter(userID, func(api *gin.RouterGroup) {
+        RegisterWorkspaceRoutes(api, db, nil)
+    })
+    return router, mock
+}
+
+func expectWorkspaceRole(mock sqlmock.Sqlmock, workspaceID, userID int, role string) {
+    mock.ExpectQuery(regexp.QuoteMeta(workspaceRoleQuery)).
+        WithArgs(workspaceID, userID).
+        WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(role))
+}
+
+func TestIsLastOwner(t *testing.T) {
+    tests := []struct {
+        name   string
+        owners []int
+        userID int
+        want   bool
+    }{
+        {"sole owner", []int{1}, 1, true},
+        {"one of owners", []int{1, 2}, 1, false},
+        {"not an owner", []int{2}, 1, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            db, mock := newMockDB(t)
+            rows := sqlmock.NewRows([]string{"user_id"})
+            for _, id := range tt.owners {
+                rows.AddRow(id)
+            }
+            mock.ExpectBegin()
+            mock.ExpectQuery(regexp.QuoteMeta(ownersQuery)).
+                WithArgs(10, RoleOwner).
+                WillReturnRows(rows)
+            mock.ExpectRollback()
+
+            tx, err := db.Beginx()
+            if err != nil {
+                t.Fatal(err)
+            }
+            defer tx.Rollback()
+            got, err := isLastOwner(tx, 10, tt.userID)
+            if err != nil || got != tt.want {
+                t.Errorf("isLastOwner = %v, %v; want %v", got, err, tt.want)
+            }
+        })
+    }
+}
+
+func TestLastOwnerCannotLeave(t *testing.T) {
+    router, mock := newWorkspaceRouter(t, 1)
+    expectWorkspaceRole(mock, 10, 1, RoleOwner)
+    mock.ExpectBegin()
+    mock.ExpectQuery(regexp.QuoteMeta(ownersQuery)).
+        WithArgs(10, RoleOwner).
+        WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+    mock.ExpectRollback()
+
+    w := doRequest(router, http.MethodDelete, "/api/workspaces/10/members/1", "")
+    if w.Code != http.StatusConflict {
+        t.Errorf("status = %d, want 409, body = %s", w.Code, w.Body)
+    }
+}
+
+func TestDeleteInvitation(t *testing.T) {
+    t.Run("invalid id", func(t *testing.T) {
+        router, mock := newWorkspaceRouter(t, 1)
+        expectWorkspaceRole(mock, 10, 1, RoleOwner)
+        w := doRequest(router, http.MethodDelete, "/api/workspaces/10/invitations/abc", "")
+        if w.Code != http.StatusBadRequest {
+            t.Errorf("status = %d, want 400, body = %s", w.Code, w.Body)
+        }
+    })
+    t.Run("other workspace", func(t *testing.T) {
+        router, mock := newWorkspaceRouter(t, 1)
+        expectWorkspaceRole(mock, 10, 1, RoleOwner)
+        mock.ExpectExec(regexp.QuoteMeta("DELETE FROM workspace_invitations WHERE id=$1 AND workspace_id=$2")).
+            WithArgs(5, 10).
+            WillReturnResult(sqlmock.NewResult(0, 0))
+        w := doRequest(router, http.MethodDelete, "/api/workspaces/10/invitations/5", "")
+        if w.Code != http.StatusNotFound {
+            t.Errorf("status = %d, want 404, body = %s", w.Code, w.Body)
+        }
+    })
+    t.Run("deleted", func(t *testing.T) {
+        router, mock := newWorkspaceRouter(t, 1)
+        expectWorkspaceRole(mock, 10, 1, RoleOwner)
+        mock.ExpectExec(regexp.QuoteMeta("DELETE FROM workspace_invitations WHERE id=$1 AND workspace_id=$2")).
+            WithArgs(5, 10).
+            WillReturnResult(sqlmock.NewResult(0, 1))
+        w := doRequest(router, http.MethodDelete, "/api/workspaces/10/invitations/5", "")
+        if w.Code != http.StatusNoContent {
+            t.Errorf("status = %d, want 204, body = %s", w.Code, w.Body)
+        }
+    })
+}
+
+func TestAcceptInvitation(t *testing.T) {
+    router, mock := newWorkspaceRouter(t, 2)
+    now := time.Now()
+    mock.ExpectBegin()
+    mock.ExpectQuery(regexp.QuoteMeta(invitationQuery)).
+        WithArgs("tok").
+        WillReturnRows(sqlmock.NewRows(invitationColumns).
+            AddRow(5, 10, "tok", RoleEditor, 1, now.Add(time.Hour), nil, nil, now))
+    mock.ExpectQuery(regexp.QuoteMeta(workspaceRoleQuery)).
+        WithArgs(10, 2).
+        WillReturnRows(sqlmock.NewRows([]string{"role"}))
+    mock.ExpectExec(regexp.QuoteMeta("INSERT INTO workspace_members")).
+        WithArgs(10, 2, RoleEditor).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectExec(regexp.QuoteMeta("UPDATE workspace_invitations SET accepted_by=$1")).
+        WithArgs(2, 5).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM workspaces WHERE id=$1")).
+        WithArgs(10).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "is_personal", "created_by", "created_at", "updated_at"}).
+            AddRow(10, "Team", false, 1, now, now))
+    mock.ExpectCommit()
+
+    w := doRequest(router, http.MethodPost, "/api/invitations/tok/accept", "")
+    if w.Code != http.StatusOK {
+        t.Errorf("status = %d, want 200, body = %s", w.Code, w.Body)
+    }
+}
+
+func TestAcceptInvitationKeepsHigherRole(t *testing.T) {
+    router, mock := newWorkspaceRouter(t, 2)
+    now := time.Now()
+    // владелец, принявший приглашение редактора, остаётся владельцем
+    mock.ExpectBegin()
+    mock.ExpectQuery(regexp.QuoteMeta(invitationQuery)).
+        WithArgs("tok").
+        WillReturnRows(sqlmock.NewRows(invitationColumns).
+            AddRow(5, 10, "tok", RoleEditor, 1, now.Add(time.Hour), nil, nil, now))
+    expectWorkspaceRole(mock, 10, 2, RoleOwner)
+    mock.ExpectExec(regexp.QuoteMeta("UPDATE workspace_invitations SET accepted_by=$1")).
+        WithArgs(2, 5).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM workspaces WHERE id=$1")).
+        WithArgs(10).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name", "is_personal", "created_by", "created_at", "updated_at"}).
+            AddRow(10, "Team", false, 1, now, now))
+    mock.ExpectCommit()
+
+    w := doRequest(router, http.MethodPost, "/api/invitations/tok/accept", "")
+    if w.Code != http.StatusOK {
+        t.Errorf("status = %d, want 200, body = %s", w.Code, w.Body)
+    }
+}
+
+func TestAcceptExpiredInvitation(t *testing.T) {
+    router, mock := newWorkspaceRouter(t, 2)
+    // просроченное или уже принятое приглашение не находится запросом
+    mock.ExpectBegin()
+    mock.ExpectQuery(regexp.QuoteMeta("WHERE token=$1 AND accepted_at IS NULL AND expires_at > NOW()")).
+        WithArgs("tok").
+        WillReturnRows(sqlmock.NewRows(invitationColumns))
+    mock.ExpectRollback()
+
+    w := doRequest(router, http.MethodPost, "/api/invitations/tok/accept", "")
+    if w.Code != http.StatusNotFound {
+        t.Errorf("status = %d, want 404, body = %s", w.Code, w.Body)
+    }
+}
@@ -0,0 +1,74 @@
+package imaging
+
+import (
+    "bytes"
+    "encoding/binary"
+)
+
+// jpegOrientation читает тег Orientation (0x0112) из EXIF-блока JPEG.
+// Возвращает 1 (без поворота), если тега нет или данные повреждены.
+func jpegOrientation(data []byte) int {
+    if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+        return 1
+    }
+    pos := 2
+    for pos+4 <= len(data) {
+        if data[pos] != 0xFF {
+            return 1
+        }
+        marker := data[pos+1]
+        // SOS — дальше идут сжатые данные, EXIF уже не встретится
+        if marker == 0xDA || marker == 0xD9 {
+            return 1
+        }
+        size := int(binary.BigEndian.Uint16(data[pos+2:]))
+        if size < 2 || pos+2+size > len(data) {
+            return 1
+        }
+        segment := data[pos+4 : pos+2+size]
+        if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+            return tiffOrientation(segment[6:])
+        }
+        pos += 2 + size
+    }
+    return 1
+}
+
+// tiffOrientation ищет Orientation в IFD0 TIFF-структуры EXIF
+func tiffOrientation(tiff []byte) int {
+    if len(tiff) < 8 {
+        return 1
+    }
+    var order binary.ByteOrder
+    switch string(tiff[:2]) {
+    case "II":
+        order = binary.LittleEndian
+    case "MM":
+        order = binary.BigEndian
+    default:
+        return 1
+    }
+    if order.Uint16(tiff[2:]) != 42 {
+        return 1
+    }
+    ifd := int(order.Uint32(tiff[4:]))
+    if ifd < 8 || ifd+2 > len(tiff) {
+        return 1
+    }
+    count := int(order.Uint16(tiff[ifd:]))
+    for i := 0; i < count; i++ {
+        entry := ifd + 2 + i*12
+        if entry+12 > len(tiff) {
+            return 1
+        }
+        // тип 3 — SHORT, значение лежит в первых двух байтах поля value
+        if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+            o := int(order.Uint16(tiff[entry+8:]))
+            if o < 1 || o > 8 {
+                return 1
+            }
+            return o
+        }
+    }
+    return 1
+}
@@ -0,0 +1,197 @@
+// Package imaging декодирует загруженные картинки, исправляет ориентацию по
+// EXIF, убирает метаданные (перекодированием) и строит уменьшенные варианты.
+// Всё на чистом Go, без cgo.
+package imaging
+
+import (
+    "bytes"
+    "errors"
+    "image"
+    "image/color"
+    "image/draw"
+    _ "image/gif" // регистрирует декодер
+    "image/jpeg"
+    "image/png"
+
+    "github.com/HugoSmits86/nativewebp"
+    xdraw "golang.org/x/image/draw"
+    _ "golang.org/x/image/webp" // регистрирует декодер
+)
+
+// Форматы результата
+const (
+    FormatJPEG = "jpeg"
+    FormatPNG  = "png"
+    FormatWebP = "webp"
+)
+
+// JPEGQuality — качество JPEG для основного файла и вариантов
+const JPEGQuality = 82
+
+// ErrTooLarge — картинка больше допустимого числа пикселей
+var ErrTooLarge = errors.New("image has too many pixels")
+
+// Decode декодирует картинку и поворачивает её согласно EXIF Orientation.
+// maxPixels ограничивает размер до декодирования, чтобы не съесть всю память.
+func Decode(data []byte, maxPixels int) (*image.NRGBA, string, error) {
+    cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+    if err != nil {
+        return nil, "", err
+    }
+    if maxPixels > 0 && cfg.Width*cfg.Height > maxPixels {
+        return nil, "", ErrTooLarge
+    }
+    img, format, err := image.Decode(bytes.NewReader(data))
+    if err != nil {
+        return nil, "", err
+    }
+    nrgba := toNRGBA(img)
+    if format == "jpeg" {
+        nrgba = orient(nrgba, jpegOrientation(data))
+    }
+    return nrgba, format, nil
+}
+
+func toNRGBA(img image.Image) *image.NRGBA {
+    if n, ok := img.(*image.NRGBA); ok && n.Rect.Min == (image.Point{}) {
+        return n
+    }
+    b := img.Bounds()
+    dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+    draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+    return dst
+}
+
+// orient применяет EXIF Orientation (1..8) к картинке
+func orient(src *image.NRGBA, o int) *image.NRGBA {
+    if o <= 1 || o > 8 {
+        return src
+    }
+    w, h := src.Rect.Dx(), src.Rect.Dy()
+    dw, dh := w, h
+    if o >= 5 {
+        dw, dh = h, w
+    }
+    dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+    for y := 0; y < h; y++ {
+        for x := 0; x < w; x++ {
+            var dx, dy int
+            switch o {
+            case 2: // зеркало по горизонтали
+                dx, dy = w-1-x, y
+            case 3: // поворот на 180°
+                dx, dy = w-1-x, h-1-y
+            case 4: // зеркало по вертикали
+                dx, dy = x, h-1-y
+            case 5: // транспонирование
+                dx, dy = y, x
+            case 6: // поворот на 90° по часовой
+                dx, dy = h-1-y, x
+            case 7: // поперечное транспонирование
+                dx, dy = h-1-y, w-1-x
+            case 8: // поворот на 90° против часовой
+                dx, dy = y, w-1-x
+            }
+            si := y*src.Stride + x*4
+            di := dy*dst.Stride + dx*4
+            copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+        }
+    }
+    return dst
+}
+
+// Fit уменьшает картинку так, чтобы большая сторона не превышала max.
+// Меньшие картинки возвращаются как есть.
+func Fit(img *image.NRGBA, max int) *image.NRGBA {
+    w, h := img.Rect.Dx(), img.Rect.Dy()
+    if w <= max && h <= max {
+        return img
+    }
+    if w >= h {
+        return scale(img, max, h*max/w)
+    }
+    return scale(img, w*max/h, max)
+}
+
+// ResizeWidth уменьшает картинку до ширины width с сохранением пропорций
+func ResizeWidth(img *image.NRGBA, width int) *image.NRGBA {
+    w, h := img.Rect.Dx(), img.Rect.Dy()
+    if width >= w {
+        return img
+    }
+    return scale(img, width, h*width/w)
+}
+
+// Square вырезает квадрат по центру и масштабирует его до size×size
+func Square(img *image.NRGBA, size int) *image.NRGBA {
+    w, h := img.Rect.Dx(), img.Rect.Dy()
+    side := w
+    if h < side {
+        side = h
+    }
+    crop := img.SubImage(image.Rect((w-side)/2, (h-side)/2, (w-side)/2+side, (h-side)/2+side)).(*image.NRGBA)
+    if side <= size {
+        return toNRGBA(crop)
+    }
+    return scale(crop, size, size)
+}
+
+func scale(img *image.NRGBA, w, h int) *image.NRGBA {
+    if w < 1 {
+        w = 1
+    }
+    if h < 1 {
+        h = 1
+    }
+    dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+    xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+    return dst
+}
+
+// Opaque сообщает, что в картинке нет прозрачных пикселей
+func Opaque(img *image.NRGBA) bool {
+    return img.Opaque()
+}
+
+// EncodeJPEG кодирует картинку в JPEG; прозрачность заливается белым
+func EncodeJPEG(img *image.NRGBA) ([]byte, error) {
+    var src image.Image = img
+    if !img.Opaque() {
+        flat := image.NewRGBA(img.Bounds())
+        draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+        draw.Draw(flat, flat.Bounds(), img, img.Rect.Min, draw.Over)
+        src = flat
+    }
+    var buf bytes.Buffer
+    err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: JPEGQuality})
+    return buf.Bytes(), err
+}
+
+// EncodePNG кодирует картинку в PNG с максимальным сжатием
+func EncodePNG(img *image.NRGBA) ([]byte, error) {
+    var buf bytes.Buffer
+    enc := png.Encoder{CompressionLevel: png.BestCompression}
+    err := enc.Encode(&buf, img)
+    return buf.Bytes(), err
+}
+
+// EncodeWebP кодирует картинку в WebP. Кодировщик на чистом Go умеет только
+// lossless, поэтому для фотографий WebP обычно крупнее JPEG.
+func EncodeWebP(img *image.NRGBA) ([]byte, error) {
+    var buf bytes.Buffer
+    err := nativewebp.Encode(&buf, img, nil)
+    return buf.Bytes(), err
+}
+
+// Encode кодирует картинку в указанном формате
+func Encode(img *image.NRGBA, format string) ([]byte, error) {
+    switch format {
+    case FormatJPEG:
+        return EncodeJPEG(img)
+    case FormatPNG:
+        return EncodePNG(img)
+    case FormatWebP:
+        return EncodeWebP(img)
+    }
+    return nil, errors.New("imaging: unknown format " + format)
+}
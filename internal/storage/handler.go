@@ -0,0 +1,80 @@
+package storage
+
+import (
+    "errors"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+)
+
+// MaxPutSize ограничивает размер загрузки по presigned PUT
+const MaxPutSize = 32 << 20
+
+// Handler раздаёт объекты хранилища по HTTP для local и memory:
+// GET/HEAD /<key> — публично (как public-read в R2),
+// PUT /<key>?expires=&signature= — только по ссылке из PresignPut.
+// Ключ берётся из пути запроса без префикса prefix.
+func Handler(b Backend, signer *Signer, prefix string) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        // Content-Type задаёт загрузивший файл — браузер не должен его угадывать
+        w.Header().Set("X-Content-Type-Options", "nosniff")
+        key, err := CleanKey(strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/"))
+        if err != nil {
+            http.NotFound(w, r)
+            return
+        }
+        switch r.Method {
+        case http.MethodGet, http.MethodHead:
+            obj, err := b.Stat(key)
+            if err != nil {
+                writeError(w, r, err)
+                return
+            }
+            // приватных объектов нет, но ссылка из PresignGet тоже должна работать
+            if r.URL.Query().Has("signature") && !signer.Verify(http.MethodGet, key, r.URL.Query()) {
+                http.Error(w, "invalid signature", http.StatusForbidden)
+                return
+            }
+            w.Header().Set("Content-Type", obj.ContentType)
+            w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+            w.Header().Set("Last-Modified", obj.ModTime.UTC().Format(http.TimeFormat))
+            w.Header().Set("Cache-Control", "public, max-age=86400")
+            if r.Method == http.MethodHead {
+                return
+            }
+            data, err := b.Get(key)
+            if err != nil {
+                writeError(w, r, err)
+                return
+            }
+            w.Write(data)
+        case http.MethodPut:
+            if !signer.Verify(http.MethodPut, key, r.URL.Query()) {
+                http.Error(w, "invalid signature", http.StatusForbidden)
+                return
+            }
+            data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxPutSize))
+            if err != nil {
+                http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+                return
+            }
+            if _, err := b.Put(key, data, r.Header.Get("Content-Type")); err != nil {
+                writeError(w, r, err)
+                return
+            }
+            w.WriteHeader(http.StatusOK)
+        default:
+            w.Header().Set("Allow", "GET, HEAD, PUT")
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        }
+    })
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+    if errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalidKey) {
+        http.NotFound(w, r)
+        return
+    }
+    http.Error(w, "storage error", http.StatusInternalServerError)
+}
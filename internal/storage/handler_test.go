@@ -0,0 +1,87 @@
+package storage_test
+
+import (
+    "bytes"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "testing"
+    "time"
+
+    "github.com/blagoweb/bbtg/internal/storage"
+    "github.com/blagoweb/bbtg/internal/storage/memory"
+)
+
+func newTestHandler(t *testing.T) (http.Handler, *memory.Store) {
+    t.Helper()
+    signer := storage.NewSigner([]byte("secret"))
+    store := memory.New("http://storage.test/files", signer)
+    return storage.Handler(store, signer, "/files"), store
+}
+
+func serve(h http.Handler, method, target string, body []byte) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(method, target, bytes.NewReader(body))
+    w := httptest.NewRecorder()
+    h.ServeHTTP(w, req)
+    return w
+}
+
+func TestHandlerGet(t *testing.T) {
+    h, store := newTestHandler(t)
+    if _, err := store.Put("media/a.txt", []byte("<html>hi</html>"), "text/plain"); err != nil {
+        t.Fatal(err)
+    }
+
+    w := serve(h, http.MethodGet, "/files/media/a.txt", nil)
+    if w.Code != http.StatusOK || w.Body.String() != "<html>hi</html>" {
+        t.Fatalf("GET = %d %q", w.Code, w.Body)
+    }
+    if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+        t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+    }
+    if got := w.Header().Get("Content-Type"); got != "text/plain" {
+        t.Errorf("Content-Type = %q", got)
+    }
+
+    if w := serve(h, http.MethodGet, "/files/media/missing.txt", nil); w.Code != http.StatusNotFound {
+        t.Errorf("missing object: status = %d", w.Code)
+    }
+    if w := serve(h, http.MethodGet, "/files/media/../secret", nil); w.Code != http.StatusNotFound {
+        t.Errorf("path traversal: status = %d", w.Code)
+    }
+}
+
+func TestHandlerPut(t *testing.T) {
+    h, store := newTestHandler(t)
+    signed, err := store.PresignPut("media/b.png", "image/png", time.Minute)
+    if err != nil {
+        t.Fatal(err)
+    }
+    u, err := url.Parse(signed)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // без подписи и с подписью другого ключа загрузить нельзя
+    if w := serve(h, http.MethodPut, "/files/media/b.png", []byte("x")); w.Code != http.StatusForbidden {
+        t.Errorf("unsigned PUT: status = %d", w.Code)
+    }
+    if w := serve(h, http.MethodPut, "/files/media/c.png?"+u.RawQuery, []byte("x")); w.Code != http.StatusForbidden {
+        t.Errorf("PUT to another key: status = %d", w.Code)
+    }
+
+    if w := serve(h, http.MethodPut, u.RequestURI(), []byte("data")); w.Code != http.StatusOK {
+        t.Fatalf("signed PUT: status = %d, body = %s", w.Code, w.Body)
+    }
+    if data, err := store.Get("media/b.png"); err != nil || string(data) != "data" {
+        t.Errorf("stored = %q, %v", data, err)
+    }
+}
+
+func TestHandlerMethodNotAllowed(t *testing.T) {
+    h, _ := newTestHandler(t)
+    w := serve(h, http.MethodDelete, "/files/media/a.png", nil)
+    if w.Code != http.StatusMethodNotAllowed {
+        t.Errorf("status = %d, want 405", w.Code)
+    }
+}
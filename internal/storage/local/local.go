@@ -0,0 +1,186 @@
+// Package local хранит объекты в каталоге на диске — для self-hosting без
+// облачного хранилища. Файлы раздаются через storage.Handler.
+package local
+
+import (
+    "errors"
+    "io/fs"
+    "mime"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/blagoweb/bbtg/internal/storage"
+)
+
+// Store — хранилище в каталоге root с публичным адресом baseURL
+type Store struct {
+    root    string
+    baseURL string
+    signer  *storage.Signer
+}
+
+var _ storage.Backend = (*Store)(nil)
+
+// New создаёт каталог root, если его нет. baseURL — адрес, по которому
+// смонтирован storage.Handler, например https://example.com/files
+func New(root, baseURL string, signer *storage.Signer) (*Store, error) {
+    if err := os.MkdirAll(root, 0o755); err != nil {
+        return nil, err
+    }
+    return &Store{root: root, baseURL: strings.TrimSuffix(baseURL, "/"), signer: signer}, nil
+}
+
+func (s *Store) path(key string) (string, error) {
+    key, err := storage.CleanKey(key)
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put записывает файл атомарно: во временный файл и переименованием.
+// Тип содержимого не хранится, Stat выводит его из расширения ключа.
+func (s *Store) Put(key string, data []byte, contentType string) (string, error) {
+    p, err := s.path(key)
+    if err != nil {
+        return "", err
+    }
+    if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+        return "", err
+    }
+    tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+    if err != nil {
+        return "", err
+    }
+    defer os.Remove(tmp.Name())
+    if _, err := tmp.Write(data); err != nil {
+        tmp.Close()
+        return "", err
+    }
+    if err := tmp.Close(); err != nil {
+        return "", err
+    }
+    if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+        return "", err
+    }
+    if err := os.Rename(tmp.Name(), p); err != nil {
+        return "", err
+    }
+    return s.URL(key), nil
+}
+
+// Get читает файл
+func (s *Store) Get(key string) ([]byte, error) {
+    p, err := s.path(key)
+    if err != nil {
+        return nil, err
+    }
+    data, err := os.ReadFile(p)
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil, storage.ErrNotFound
+    }
+    return data, err
+}
+
+// Delete удаляет файл
+func (s *Store) Delete(key string) error {
+    p, err := s.path(key)
+    if err != nil {
+        return err
+    }
+    if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+        return err
+    }
+    return nil
+}
+
+// List обходит каталог и возвращает файлы с ключами на prefix
+func (s *Store) List(prefix string) ([]storage.Object, error) {
+    var out []storage.Object
+    err := filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+            return nil
+        }
+        rel, err := filepath.Rel(s.root, p)
+        if err != nil {
+            return err
+        }
+        key := filepath.ToSlash(rel)
+        if !strings.HasPrefix(key, prefix) {
+            return nil
+        }
+        info, err := d.Info()
+        if err != nil {
+            return err
+        }
+        out = append(out, object(key, info))
+        return nil
+    })
+    return out, err
+}
+
+// Stat возвращает размер, тип и время изменения файла
+func (s *Store) Stat(key string) (storage.Object, error) {
+    p, err := s.path(key)
+    if err != nil {
+        return storage.Object{}, err
+    }
+    info, err := os.Stat(p)
+    if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+        return storage.Object{}, storage.ErrNotFound
+    }
+    if err != nil {
+        return storage.Object{}, err
+    }
+    obj := object(key, info)
+    if obj.ContentType == "" {
+        // расширения нет — определяем тип по началу файла
+        data, err := s.Get(key)
+        if err != nil {
+            return obj, err
+        }
+        obj.ContentType = http.DetectContentType(data)
+    }
+    return obj, nil
+}
+
+func object(key string, info fs.FileInfo) storage.Object {
+    return storage.Object{
+        Key:         key,
+        Size:        info.Size(),
+        ContentType: mime.TypeByExtension(filepath.Ext(key)),
+        ModTime:     info.ModTime(),
+    }
+}
+
+// PresignPut выдаёт подписанную ссылку на storage.Handler
+func (s *Store) PresignPut(key, contentType string, ttl time.Duration) (string, error) {
+    if _, err := storage.CleanKey(key); err != nil {
+        return "", err
+    }
+    return s.signer.Sign(http.MethodPut, key, s.URL(key), ttl), nil
+}
+
+// PresignGet выдаёт подписанную ссылку на storage.Handler
+func (s *Store) PresignGet(key string, ttl time.Duration) (string, error) {
+    if _, err := storage.CleanKey(key); err != nil {
+        return "", err
+    }
+    return s.signer.Sign(http.MethodGet, key, s.URL(key), ttl), nil
+}
+
+// URL возвращает адрес файла: <baseURL>/<key>
+func (s *Store) URL(key string) string {
+    return s.baseURL + "/" + key
+}
+
+// KeyFromURL возвращает ключ, если url выдан этим хранилищем
+func (s *Store) KeyFromURL(rawURL string) (string, bool) {
+    return storage.KeyFromURL(s.baseURL, rawURL)
+}
@@ -0,0 +1,118 @@
+package local
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/blagoweb/bbtg/internal/storage"
+)
+
+func newTestStore(t *testing.T) (*Store, string) {
+    t.Helper()
+    root := t.TempDir()
+    s, err := New(root, "https://example.test/files/", storage.NewSigner([]byte("secret")))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return s, root
+}
+
+func TestPutGet(t *testing.T) {
+    s, root := newTestStore(t)
+    u, err := s.Put("media/a.png", []byte("first"), "image/png")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if u != "https://example.test/files/media/a.png" {
+        t.Errorf("URL = %q", u)
+    }
+    // повторная запись заменяет файл целиком
+    if _, err := s.Put("media/a.png", []byte("2"), "image/png"); err != nil {
+        t.Fatal(err)
+    }
+    data, err := s.Get("media/a.png")
+    if err != nil || string(data) != "2" {
+        t.Errorf("Get = %q, %v", data, err)
+    }
+
+    // временные файлы не остаются в каталоге
+    entries, err := os.ReadDir(filepath.Join(root, "media"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(entries) != 1 || entries[0].Name() != "a.png" {
+        var names []string
+        for _, e := range entries {
+            names = append(names, e.Name())
+        }
+        t.Errorf("files = %v, want only a.png", names)
+    }
+
+    obj, err := s.Stat("media/a.png")
+    if err != nil || obj.Size != 1 || obj.ContentType != "image/png" {
+        t.Errorf("Stat = %+v, %v", obj, err)
+    }
+}
+
+func TestPutIsAtomic(t *testing.T) {
+    s, root := newTestStore(t)
+    // переименование не удаётся: на месте ключа каталог. Недописанный
+    // файл не должен появиться ни под ключом, ни рядом с ним
+    if err := os.MkdirAll(filepath.Join(root, "media", "a.png"), 0o755); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.Put("media/a.png", []byte("new"), "image/png"); err == nil {
+        t.Fatal("Put over a directory succeeded")
+    }
+    entries, err := os.ReadDir(filepath.Join(root, "media"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, e := range entries {
+        if strings.HasPrefix(e.Name(), ".upload-") {
+            t.Errorf("temporary file %s left behind", e.Name())
+        }
+    }
+    if info, err := os.Stat(filepath.Join(root, "media", "a.png")); err != nil || !info.IsDir() {
+        t.Errorf("target replaced: %v", err)
+    }
+}
+
+func TestPathTraversal(t *testing.T) {
+    s, root := newTestStore(t)
+    outside := filepath.Join(filepath.Dir(root), "outside.txt")
+    for _, key := range []string{"../outside.txt", "media/../../outside.txt", "/etc/passwd"} {
+        if _, err := s.Put(key, []byte("x"), ""); !errors.Is(err, storage.ErrInvalidKey) {
+            t.Errorf("Put(%q): err = %v, want ErrInvalidKey", key, err)
+        }
+        if _, err := s.Get(key); !errors.Is(err, storage.ErrInvalidKey) {
+            t.Errorf("Get(%q): err = %v, want ErrInvalidKey", key, err)
+        }
+        if err := s.Delete(key); !errors.Is(err, storage.ErrInvalidKey) {
+            t.Errorf("Delete(%q): err = %v, want ErrInvalidKey", key, err)
+        }
+        if _, err := s.PresignPut(key, "", 0); !errors.Is(err, storage.ErrInvalidKey) {
+            t.Errorf("PresignPut(%q): err = %v, want ErrInvalidKey", key, err)
+        }
+    }
+    if _, err := os.Stat(outside); !os.IsNotExist(err) {
+        t.Errorf("file written outside root: %v", err)
+    }
+}
+
+func TestNotFound(t *testing.T) {
+    s, _ := newTestStore(t)
+    if _, err := s.Get("media/missing.png"); !errors.Is(err, storage.ErrNotFound) {
+        t.Errorf("Get: err = %v, want ErrNotFound", err)
+    }
+    if _, err := s.Stat("media"); err == nil {
+        // каталог объектом не считается
+        t.Error("Stat of a directory succeeded")
+    }
+    if err := s.Delete("media/missing.png"); err != nil {
+        t.Errorf("Delete of missing object: %v", err)
+    }
+}
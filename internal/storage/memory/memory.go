@@ -0,0 +1,123 @@
+// Package memory хранит объекты в памяти процесса — для разработки и
+// интеграционных тестов без сети. Содержимое теряется при перезапуске.
+package memory
+
+import (
+    "net/http"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/blagoweb/bbtg/internal/storage"
+)
+
+// Store — хранилище в памяти с публичным адресом baseURL
+type Store struct {
+    mu      sync.RWMutex
+    objects map[string]entry
+    baseURL string
+    signer  *storage.Signer
+}
+
+type entry struct {
+    data        []byte
+    contentType string
+    modTime     time.Time
+}
+
+var _ storage.Backend = (*Store)(nil)
+
+// New создаёт пустое хранилище. baseURL — адрес, по которому смонтирован
+// storage.Handler; для тестов подойдёт любой, например http://storage.test
+func New(baseURL string, signer *storage.Signer) *Store {
+    return &Store{objects: map[string]entry{}, baseURL: strings.TrimSuffix(baseURL, "/"), signer: signer}
+}
+
+// Put сохраняет копию data
+func (s *Store) Put(key string, data []byte, contentType string) (string, error) {
+    if _, err := storage.CleanKey(key); err != nil {
+        return "", err
+    }
+    if contentType == "" {
+        contentType = http.DetectContentType(data)
+    }
+    s.mu.Lock()
+    s.objects[key] = entry{data: append([]byte(nil), data...), contentType: contentType, modTime: time.Now()}
+    s.mu.Unlock()
+    return s.URL(key), nil
+}
+
+// Get возвращает копию объекта
+func (s *Store) Get(key string) ([]byte, error) {
+    s.mu.RLock()
+    e, ok := s.objects[key]
+    s.mu.RUnlock()
+    if !ok {
+        return nil, storage.ErrNotFound
+    }
+    return append([]byte(nil), e.data...), nil
+}
+
+// Delete удаляет объект
+func (s *Store) Delete(key string) error {
+    s.mu.Lock()
+    delete(s.objects, key)
+    s.mu.Unlock()
+    return nil
+}
+
+// List возвращает объекты с ключами на prefix, отсортированные по ключу
+func (s *Store) List(prefix string) ([]storage.Object, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    var out []storage.Object
+    for key, e := range s.objects {
+        if strings.HasPrefix(key, prefix) {
+            out = append(out, object(key, e))
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+    return out, nil
+}
+
+// Stat возвращает сведения об объекте
+func (s *Store) Stat(key string) (storage.Object, error) {
+    s.mu.RLock()
+    e, ok := s.objects[key]
+    s.mu.RUnlock()
+    if !ok {
+        return storage.Object{}, storage.ErrNotFound
+    }
+    return object(key, e), nil
+}
+
+func object(key string, e entry) storage.Object {
+    return storage.Object{Key: key, Size: int64(len(e.data)), ContentType: e.contentType, ModTime: e.modTime}
+}
+
+// PresignPut выдаёт подписанную ссылку на storage.Handler
+func (s *Store) PresignPut(key, contentType string, ttl time.Duration) (string, error) {
+    if _, err := storage.CleanKey(key); err != nil {
+        return "", err
+    }
+    return s.signer.Sign(http.MethodPut, key, s.URL(key), ttl), nil
+}
+
+// PresignGet выдаёт подписанную ссылку на storage.Handler
+func (s *Store) PresignGet(key string, ttl time.Duration) (string, error) {
+    if _, err := storage.CleanKey(key); err != nil {
+        return "", err
+    }
+    return s.signer.Sign(http.MethodGet, key, s.URL(key), ttl), nil
+}
+
+// URL возвращает адрес объекта: <baseURL>/<key>
+func (s *Store) URL(key string) string {
+    return s.baseURL + "/" + key
+}
+
+// KeyFromURL возвращает ключ, если url выдан этим хранилищем
+func (s *Store) KeyFromURL(rawURL string) (string, bool) {
+    return storage.KeyFromURL(s.baseURL, rawURL)
+}
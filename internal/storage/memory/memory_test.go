@@ -0,0 +1,69 @@
+package memory
+
+import (
+    "errors"
+    "testing"
+
+    "github.com/blagoweb/bbtg/internal/storage"
+)
+
+func TestPutCopiesData(t *testing.T) {
+    s := New("http://storage.test/", storage.NewSigner([]byte("secret")))
+    data := []byte("abc")
+    u, err := s.Put("media/a.txt", data, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if u != "http://storage.test/media/a.txt" {
+        t.Errorf("URL = %q", u)
+    }
+    // изменения исходного и полученного среза не меняют объект
+    data[0] = 'X'
+    got, err := s.Get("media/a.txt")
+    if err != nil || string(got) != "abc" {
+        t.Fatalf("Get = %q, %v", got, err)
+    }
+    got[0] = 'Y'
+    if again, _ := s.Get("media/a.txt"); string(again) != "abc" {
+        t.Errorf("Get after change = %q", again)
+    }
+
+    obj, err := s.Stat("media/a.txt")
+    if err != nil || obj.Size != 3 || obj.ContentType != "text/plain; charset=utf-8" {
+        t.Errorf("Stat = %+v, %v", obj, err)
+    }
+}
+
+func TestList(t *testing.T) {
+    s := New("http://storage.test", nil)
+    for _, key := range []string{"media/b", "exports/x", "media/a"} {
+        if _, err := s.Put(key, []byte("1"), "text/plain"); err != nil {
+            t.Fatal(err)
+        }
+    }
+    objs, err := s.List("media/")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(objs) != 2 || objs[0].Key != "media/a" || objs[1].Key != "media/b" {
+        t.Errorf("List = %+v", objs)
+    }
+    if err := s.Delete("media/a"); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.Get("media/a"); !errors.Is(err, storage.ErrNotFound) {
+        t.Errorf("Get after Delete: err = %v, want ErrNotFound", err)
+    }
+}
+
+func TestInvalidKey(t *testing.T) {
+    s := New("http://storage.test", storage.NewSigner([]byte("secret")))
+    for _, key := range []string{"", "../a", "/a", "a/./b"} {
+        if _, err := s.Put(key, []byte("x"), ""); !errors.Is(err, storage.ErrInvalidKey) {
+            t.Errorf("Put(%q): err = %v, want ErrInvalidKey", key, err)
+        }
+        if _, err := s.PresignGet(key, 0); !errors.Is(err, storage.ErrInvalidKey) {
+            t.Errorf("PresignGet(%q): err = %v, want ErrInvalidKey", key, err)
+        }
+    }
+}
@@ -2,14 +2,21 @@ package r2
 
 import (
     "bytes"
+    "errors"
     "fmt"
     "io"
+    "net/http"
     "net/url"
+    "strings"
+    "time"
 
     "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/aws/awserr"
     "github.com/aws/aws-sdk-go/aws/credentials"
     "github.com/aws/aws-sdk-go/aws/session"
     "github.com/aws/aws-sdk-go/service/s3"
+
+    "github.com/blagoweb/bbtg/internal/storage"
 )
 
 // Client обёртка над AWS S3-клиентом для Cloudflare R2
@@ -21,6 +28,8 @@ type Client struct {
     host   string
 }
 
+var _ storage.Backend = (*Client)(nil)
+
 // NewClient создаёт новый R2-клиент
 func NewClient(endpoint, accessKey, secretKey, bucket string) (*Client, error) {
     cfg := aws.NewConfig().
@@ -47,30 +56,32 @@ func NewClient(endpoint, accessKey, secretKey, bucket string) (*Client, error) {
     }, nil
 }
 
-// Upload загружает данные в R2 под ключом objectKey и возвращает публичный URL
-func (c *Client) Upload(objectKey string, data []byte) (string, error) {
+// Put загружает данные в R2 под ключом objectKey и возвращает публичный URL
+func (c *Client) Put(objectKey string, data []byte, contentType string) (string, error) {
+    if contentType == "" {
+        contentType = http.DetectContentType(data)
+    }
     _, err := c.svc.PutObject(&s3.PutObjectInput{
-        Bucket: aws.String(c.bucket),
-        Key:    aws.String(objectKey),
-        Body:   bytes.NewReader(data),
-        ACL:    aws.String("public-read"),
+        Bucket:      aws.String(c.bucket),
+        Key:         aws.String(objectKey),
+        Body:        bytes.NewReader(data),
+        ACL:         aws.String("public-read"),
+        ContentType: aws.String(contentType),
     })
     if err != nil {
         return "", fmt.Errorf("failed to upload to R2: %w", err)
     }
-
-    // Формируем публичный URL: https://<host>/<bucket>/<objectKey>
-    return fmt.Sprintf("https://%s/%s/%s", c.host, c.bucket, objectKey), nil
+    return c.URL(objectKey), nil
 }
 
-// Download скачивает объект из R2 по ключу objectKey
-func (c *Client) Download(objectKey string) ([]byte, error) {
+// Get скачивает объект из R2 по ключу objectKey
+func (c *Client) Get(objectKey string) ([]byte, error) {
     out, err := c.svc.GetObject(&s3.GetObjectInput{
         Bucket: aws.String(c.bucket),
         Key:    aws.String(objectKey),
     })
     if err != nil {
-        return nil, fmt.Errorf("failed to download from R2: %w", err)
+        return nil, fmt.Errorf("failed to download from R2: %w", notFound(err))
     }
     defer out.Body.Close()
 
@@ -79,4 +90,103 @@ func (c *Client) Download(objectKey string) ([]byte, error) {
         return nil, fmt.Errorf("failed reading R2 response body: %w", err)
     }
     return buf.Bytes(), nil
-}
\ No newline at end of file
+}
+
+// Delete удаляет объект из R2 по ключу objectKey
+func (c *Client) Delete(objectKey string) error {
+    _, err := c.svc.DeleteObject(&s3.DeleteObjectInput{
+        Bucket: aws.String(c.bucket),
+        Key:    aws.String(objectKey),
+    })
+    if err != nil {
+        return fmt.Errorf("failed to delete from R2: %w", err)
+    }
+    return nil
+}
+
+// List возвращает объекты бакета с ключами на prefix (постранично, все страницы)
+func (c *Client) List(prefix string) ([]storage.Object, error) {
+    var out []storage.Object
+    input := &s3.ListObjectsV2Input{Bucket: aws.String(c.bucket), Prefix: aws.String(prefix)}
+    err := c.svc.ListObjectsV2Pages(input, func(page *s3.ListObjectsV2Output, last bool) bool {
+        for _, o := range page.Contents {
+            out = append(out, storage.Object{
+                Key:     aws.StringValue(o.Key),
+                Size:    aws.Int64Value(o.Size),
+                ModTime: aws.TimeValue(o.LastModified),
+            })
+        }
+        return true
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to list R2 objects: %w", err)
+    }
+    return out, nil
+}
+
+// Stat возвращает сведения об объекте (HEAD)
+func (c *Client) Stat(objectKey string) (storage.Object, error) {
+    out, err := c.svc.HeadObject(&s3.HeadObjectInput{
+        Bucket: aws.String(c.bucket),
+        Key:    aws.String(objectKey),
+    })
+    if err != nil {
+        return storage.Object{}, fmt.Errorf("failed to stat R2 object: %w", notFound(err))
+    }
+    return storage.Object{
+        Key:         objectKey,
+        Size:        aws.Int64Value(out.ContentLength),
+        ContentType: aws.StringValue(out.ContentType),
+        ModTime:     aws.TimeValue(out.LastModified),
+    }, nil
+}
+
+// PresignPut выдаёт временный URL для загрузки объекта напрямую в R2
+func (c *Client) PresignPut(objectKey, contentType string, ttl time.Duration) (string, error) {
+    input := &s3.PutObjectInput{
+        Bucket: aws.String(c.bucket),
+        Key:    aws.String(objectKey),
+        ACL:    aws.String("public-read"),
+    }
+    if contentType != "" {
+        input.ContentType = aws.String(contentType)
+    }
+    req, _ := c.svc.PutObjectRequest(input)
+    return req.Presign(ttl)
+}
+
+// PresignGet выдаёт временный URL для скачивания объекта из R2
+func (c *Client) PresignGet(objectKey string, ttl time.Duration) (string, error) {
+    req, _ := c.svc.GetObjectRequest(&s3.GetObjectInput{
+        Bucket: aws.String(c.bucket),
+        Key:    aws.String(objectKey),
+    })
+    return req.Presign(ttl)
+}
+
+// URL формирует публичный URL: https://<host>/<bucket>/<objectKey>
+func (c *Client) URL(objectKey string) string {
+    return fmt.Sprintf("https://%s/%s/%s", c.host, c.bucket, objectKey)
+}
+
+// KeyFromURL возвращает ключ объекта, если url выдан этим клиентом (см. URL)
+func (c *Client) KeyFromURL(rawURL string) (string, bool) {
+    u, err := url.Parse(rawURL)
+    if err != nil || u.Host != c.host {
+        return "", false
+    }
+    prefix := "/" + c.bucket + "/"
+    if !strings.HasPrefix(u.Path, prefix) || len(u.Path) == len(prefix) {
+        return "", false
+    }
+    return strings.TrimPrefix(u.Path, prefix), true
+}
+
+// notFound превращает ответ 404 от R2 в storage.ErrNotFound
+func notFound(err error) error {
+    var reqErr awserr.RequestFailure
+    if errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotFound {
+        return storage.ErrNotFound
+    }
+    return err
+}
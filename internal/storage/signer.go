@@ -0,0 +1,44 @@
+package storage
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "net/url"
+    "strconv"
+    "time"
+)
+
+// Signer подписывает временные ссылки для хранилищ без собственного
+// механизма presigned URL (local, memory). Проверяет их Handler.
+type Signer struct {
+    secret []byte
+}
+
+// NewSigner создаёт подписчик с секретом secret
+func NewSigner(secret []byte) *Signer {
+    return &Signer{secret: secret}
+}
+
+// Sign добавляет к objectURL параметры expires и signature
+func (s *Signer) Sign(method, key, objectURL string, ttl time.Duration) string {
+    expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+    q := url.Values{"expires": {expires}, "signature": {s.signature(method, key, expires)}}
+    return objectURL + "?" + q.Encode()
+}
+
+// Verify проверяет подпись и срок действия ссылки
+func (s *Signer) Verify(method, key string, q url.Values) bool {
+    expires := q.Get("expires")
+    unix, err := strconv.ParseInt(expires, 10, 64)
+    if err != nil || time.Now().Unix() > unix {
+        return false
+    }
+    return hmac.Equal([]byte(q.Get("signature")), []byte(s.signature(method, key, expires)))
+}
+
+func (s *Signer) signature(method, key, expires string) string {
+    mac := hmac.New(sha256.New, s.secret)
+    mac.Write([]byte(method + "\n" + key + "\n" + expires))
+    return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+    "net/http"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+)
+
+func signedQuery(t *testing.T, s *Signer, method, key string, ttl time.Duration) url.Values {
+    t.Helper()
+    signed := s.Sign(method, key, "https://cdn.test/"+key, ttl)
+    u, err := url.Parse(signed)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return u.Query()
+}
+
+func TestSigner(t *testing.T) {
+    s := NewSigner([]byte("secret"))
+    q := signedQuery(t, s, http.MethodPut, "media/a.png", time.Minute)
+    if !s.Verify(http.MethodPut, "media/a.png", q) {
+        t.Fatal("valid signature rejected")
+    }
+
+    if s.Verify(http.MethodGet, "media/a.png", q) {
+        t.Error("signature for PUT accepted for GET")
+    }
+    if s.Verify(http.MethodPut, "media/b.png", q) {
+        t.Error("signature accepted for another key")
+    }
+    if NewSigner([]byte("other")).Verify(http.MethodPut, "media/a.png", q) {
+        t.Error("signature accepted with another secret")
+    }
+
+    // продлить ссылку, подменив expires, нельзя
+    extended := url.Values{"expires": {"9999999999"}, "signature": {q.Get("signature")}}
+    if s.Verify(http.MethodPut, "media/a.png", extended) {
+        t.Error("signature accepted with changed expires")
+    }
+    tampered := url.Values{"expires": {q.Get("expires")}, "signature": {strings.Repeat("0", 64)}}
+    if s.Verify(http.MethodPut, "media/a.png", tampered) {
+        t.Error("forged signature accepted")
+    }
+    if s.Verify(http.MethodPut, "media/a.png", url.Values{}) {
+        t.Error("missing signature accepted")
+    }
+}
+
+func TestSignerExpired(t *testing.T) {
+    s := NewSigner([]byte("secret"))
+    q := signedQuery(t, s, http.MethodGet, "media/a.png", -time.Minute)
+    if s.Verify(http.MethodGet, "media/a.png", q) {
+        t.Error("expired signature accepted")
+    }
+}
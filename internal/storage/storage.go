@@ -0,0 +1,82 @@
+// Package storage описывает хранилище файлов (аватары, картинки, экспорт).
+// Реализации: r2 (Cloudflare R2 / любой S3), local (каталог на диске) и
+// memory (для разработки и тестов).
+package storage
+
+import (
+    "errors"
+    "path"
+    "strings"
+    "time"
+)
+
+// Backend — хранилище объектов с публичными URL
+type Backend interface {
+    // Put сохраняет объект и возвращает его публичный URL
+    Put(key string, data []byte, contentType string) (string, error)
+    // Get читает объект целиком
+    Get(key string) ([]byte, error)
+    // Delete удаляет объект; отсутствие объекта ошибкой не считается
+    Delete(key string) error
+    // List возвращает объекты с ключами, начинающимися с prefix
+    List(prefix string) ([]Object, error)
+    // Stat возвращает сведения об объекте без его содержимого
+    Stat(key string) (Object, error)
+    // PresignPut выдаёт временный URL для загрузки объекта методом PUT
+    PresignPut(key, contentType string, ttl time.Duration) (string, error)
+    // PresignGet выдаёт временный URL для скачивания объекта
+    PresignGet(key string, ttl time.Duration) (string, error)
+    // URL возвращает публичный URL объекта
+    URL(key string) string
+    // KeyFromURL возвращает ключ, если url выдан этим хранилищем
+    KeyFromURL(rawURL string) (string, bool)
+}
+
+// Object — сведения об объекте хранилища
+type Object struct {
+    Key         string
+    Size        int64
+    ContentType string
+    ModTime     time.Time
+}
+
+var (
+    // ErrNotFound — объекта с таким ключом нет
+    ErrNotFound = errors.New("storage: object not found")
+    // ErrInvalidKey — ключ пустой или выходит за пределы хранилища
+    ErrInvalidKey = errors.New("storage: invalid object key")
+)
+
+// CleanKey проверяет ключ объекта: относительный путь через "/", без "." и "..".
+// Нужна реализациям, где ключ превращается в путь файла или URL.
+func CleanKey(key string) (string, error) {
+    if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || strings.ContainsRune(key, 0) {
+        return "", ErrInvalidKey
+    }
+    if path.Clean(key) != key {
+        return "", ErrInvalidKey
+    }
+    for _, part := range strings.Split(key, "/") {
+        if part == "." || part == ".." {
+            return "", ErrInvalidKey
+        }
+    }
+    return key, nil
+}
+
+// KeyFromURL — общая реализация Backend.KeyFromURL для хранилищ,
+// раздающих объекты по адресу baseURL/<key>
+func KeyFromURL(baseURL, rawURL string) (string, bool) {
+    prefix := strings.TrimSuffix(baseURL, "/") + "/"
+    if !strings.HasPrefix(rawURL, prefix) {
+        return "", false
+    }
+    key := strings.TrimPrefix(rawURL, prefix)
+    if i := strings.IndexAny(key, "?#"); i >= 0 {
+        key = key[:i]
+    }
+    if _, err := CleanKey(key); err != nil {
+        return "", false
+    }
+    return key, true
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestCleanKey(t *testing.T) {
+    valid := []string{"a.png", "media/abc.jpg", "exports/1/2.zip", "a..b/c"}
+    for _, key := range valid {
+        if got, err := CleanKey(key); err != nil || got != key {
+            t.Errorf("CleanKey(%q) = %q, %v", key, got, err)
+        }
+    }
+    invalid := []string{
+        "", "/etc/passwd", "../secret", "media/../../secret", "media/./a.png", "./a.png",
+        "media/..", "media//a.png", "media/", `media\..\a.png`, "a\x00.png",
+    }
+    for _, key := range invalid {
+        if _, err := CleanKey(key); !errors.Is(err, ErrInvalidKey) {
+            t.Errorf("CleanKey(%q): err = %v, want ErrInvalidKey", key, err)
+        }
+    }
+}
+
+func TestKeyFromURL(t *testing.T) {
+    tests := []struct {
+        rawURL string
+        key    string
+        ok     bool
+    }{
+        {"https://cdn.test/files/media/a.png", "media/a.png", true},
+        {"https://cdn.test/files/media/a.png?v=2#x", "media/a.png", true},
+        {"https://cdn.test/files/../media/a.png", "", false},
+        {"https://cdn.test/filesX/a.png", "", false},
+        {"https://other.test/files/a.png", "", false},
+    }
+    for _, tt := range tests {
+        key, ok := KeyFromURL("https://cdn.test/files/", tt.rawURL)
+        if key != tt.key || ok != tt.ok {
+            t.Errorf("KeyFromURL(%q) = %q, %v; want %q, %v", tt.rawURL, key, ok, tt.key, tt.ok)
+        }
+    }
+}
@@ -4,68 +4,107 @@ import (
     "crypto/hmac"
     "crypto/sha256"
     "encoding/hex"
-    "encoding/json" // FIX: для парсинга user
+    "encoding/json"
     "errors"
     "fmt"
     "net/url"
     "sort"
+    "strconv"
     "strings"
+    "sync"
+    "time"
 )
 
-type tgUser struct { // FIX: структура для user
-    ID        int64  `json:"id"`
-    Username  string `json:"username"`
-    FirstName string `json:"first_name"`
-    LastName  string `json:"last_name"`
+// Ошибки проверки initData. Конкретная причина добавляется через %w,
+// поэтому сравнивать нужно через errors.Is.
+var (
+    ErrMalformed = errors.New("telegram: malformed init data")
+    ErrBadHash   = errors.New("telegram: init data hash mismatch")
+    ErrExpired   = errors.New("telegram: init data expired")
+    ErrReplayed  = errors.New("telegram: init data already used")
+)
+
+// WebAppUser — пользователь из поля user (и receiver) initData
+type WebAppUser struct {
+    ID                    int64  `json:"id"`
+    IsBot                 bool   `json:"is_bot"`
+    FirstName             string `json:"first_name"`
+    LastName              string `json:"last_name"`
+    Username              string `json:"username"`
+    LanguageCode          string `json:"language_code"`
+    IsPremium             bool   `json:"is_premium"`
+    AddedToAttachmentMenu bool   `json:"added_to_attachment_menu"`
+    AllowsWriteToPm       bool   `json:"allows_write_to_pm"`
+    PhotoURL              string `json:"photo_url"`
 }
 
-func CheckAuthData(initData string, botToken string) (map[string]string, error) {
-    // ParseQuery сам сделает URL-decode каждой пары
-    
-    // Сначала разберем строку вручную, чтобы сохранить оригинальные значения
-    pairs := strings.Split(initData, "&")
-    originalVals := make(map[string]string)
-    for _, pair := range pairs {
-        if strings.Contains(pair, "=") {
-            parts := strings.SplitN(pair, "=", 2)
-            if len(parts) == 2 {
-                originalVals[parts[0]] = parts[1]
-            }
-        }
+// WebAppChat — чат из поля chat initData
+type WebAppChat struct {
+    ID       int64  `json:"id"`
+    Type     string `json:"type"`
+    Title    string `json:"title"`
+    Username string `json:"username"`
+    PhotoURL string `json:"photo_url"`
+}
+
+// InitData — проверенные данные запуска Mini App
+type InitData struct {
+    QueryID      string
+    User         *WebAppUser
+    Receiver     *WebAppUser
+    Chat         *WebAppChat
+    ChatType     string
+    ChatInstance string
+    StartParam   string
+    CanSendAfter int
+    AuthDate     time.Time
+    Hash         string
+    Signature    string
+}
+
+// ReplayCache запоминает уже использованные initData
+type ReplayCache interface {
+    // Remember сохраняет ключ на ttl и возвращает false, если он уже был
+    Remember(key string, ttl time.Duration) bool
+}
+
+// Options — настройки проверки initData
+type Options struct {
+    MaxAge    time.Duration    // максимальный возраст auth_date; 0 — не проверять
+    ClockSkew time.Duration    // допустимое расхождение часов с Telegram
+    Replay    ReplayCache      // если задан, каждый initData принимается один раз
+    Now       func() time.Time // источник времени; nil — time.Now
+}
+
+func (o Options) now() time.Time {
+    if o.Now != nil {
+        return o.Now()
     }
-    
-    vals, err := url.ParseQuery(initData)
+    return time.Now()
+}
+
+// CheckAuthData проверяет подпись initData ботом botToken, его свежесть
+// и (опционально) однократность использования
+func CheckAuthData(initData string, botToken string, opts Options) (*InitData, error) {
+    raw, vals, err := splitInitData(initData)
     if err != nil {
         return nil, err
     }
 
-    // Берём hash (а signature игнорируем по спекам)
-    receivedHash := strings.ToLower(vals.Get("hash")) // FIX: lower
+    receivedHash := strings.ToLower(vals.Get("hash"))
     if receivedHash == "" {
-        return nil, errors.New("missing hash in initData")
-    }
-
-    // Собираем пары без hash и signature
-    var keys []string
-    for k := range vals {
-        if k == "hash" || k == "signature" { // FIX: signature исключаем
-            continue
-        }
-        keys = append(keys, k)
+        return nil, fmt.Errorf("%w: missing hash", ErrMalformed)
     }
-    sort.Strings(keys)
 
+    // Собираем пары без hash и signature, используя оригинальные
+    // URL-encoded значения для hash calculation
     var dataStrings []string
-    for _, k := range keys {
-        // Используем оригинальные URL-encoded значения для hash calculation
-        value := originalVals[k]
-        dataStrings = append(dataStrings, fmt.Sprintf("%s=%s", k, value))
+    for _, k := range sortedKeys(vals, "hash", "signature") {
+        dataStrings = append(dataStrings, fmt.Sprintf("%s=%s", k, raw[k]))
     }
     dataCheckString := strings.Join(dataStrings, "\n")
-    
-
 
-    // FIX: secret_key = HMAC_SHA256(botToken, key="WebAppData")
+    // secret_key = HMAC_SHA256(botToken, key="WebAppData")
     secretMac := hmac.New(sha256.New, []byte("WebAppData"))
     secretMac.Write([]byte(botToken))
     secretKey := secretMac.Sum(nil)
@@ -73,39 +112,175 @@ func CheckAuthData(initData string, botToken string) (map[string]string, error)
     // calc_hash = HMAC_SHA256(data_check_string, key=secret_key)
     mac := hmac.New(sha256.New, secretKey)
     mac.Write([]byte(dataCheckString))
-    expectedHash := strings.ToLower(hex.EncodeToString(mac.Sum(nil))) // FIX: lower
+    expectedHash := hex.EncodeToString(mac.Sum(nil))
 
     if !hmac.Equal([]byte(expectedHash), []byte(receivedHash)) {
-        fmt.Printf("Debug info:\n")
-        fmt.Printf("  Data check string: %s\n", dataCheckString)
-        if len(botToken) > 10 {
-            fmt.Printf("  Bot token (first 10 chars): %s...\n", botToken[:10])
-        } else {
-            fmt.Printf("  Bot token: %s\n", botToken)
+        return nil, ErrBadHash
+    }
+
+    data, err := parseInitData(vals)
+    if err != nil {
+        return nil, err
+    }
+    if err := checkFreshness(data.AuthDate, replayKey(data), opts); err != nil {
+        return nil, err
+    }
+    return data, nil
+}
+
+// splitInitData разбирает initData на пары: raw — значения как пришли
+// (URL-encoded, по ним считается hash), vals — те же значения декодированными.
+// Повторяющийся ключ — ошибка: иначе подпись проверялась бы по одному
+// значению, а в данные попадало бы другое.
+func splitInitData(initData string) (map[string]string, url.Values, error) {
+    raw := make(map[string]string)
+    vals := make(url.Values)
+    for _, pair := range strings.Split(initData, "&") {
+        if pair == "" {
+            continue
+        }
+        rawKey, rawValue, _ := strings.Cut(pair, "=")
+        key, err := url.QueryUnescape(rawKey)
+        if err != nil {
+            return nil, nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+        }
+        value, err := url.QueryUnescape(rawValue)
+        if err != nil {
+            return nil, nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+        }
+        if _, dup := raw[key]; dup {
+            return nil, nil, fmt.Errorf("%w: duplicate key %q", ErrMalformed, key)
+        }
+        raw[key] = rawValue
+        vals.Set(key, value)
+    }
+    return raw, vals, nil
+}
+
+// replayKey — ключ initData для защиты от повторов
+func replayKey(data *InitData) string {
+    if data.QueryID != "" {
+        return data.QueryID
+    }
+    return data.Hash
+}
+
+// sortedKeys возвращает ключи vals по алфавиту, пропуская skip
+func sortedKeys(vals url.Values, skip ...string) []string {
+    var keys []string
+    for k := range vals {
+        excluded := false
+        for _, s := range skip {
+            if k == s {
+                excluded = true
+                break
+            }
+        }
+        if !excluded {
+            keys = append(keys, k)
+        }
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// parseInitData раскладывает уже проверенные поля по структуре
+func parseInitData(vals url.Values) (*InitData, error) {
+    data := &InitData{
+        QueryID:      vals.Get("query_id"),
+        ChatType:     vals.Get("chat_type"),
+        ChatInstance: vals.Get("chat_instance"),
+        StartParam:   vals.Get("start_param"),
+        Hash:         strings.ToLower(vals.Get("hash")),
+        Signature:    vals.Get("signature"),
+    }
+
+    authDate, err := strconv.ParseInt(vals.Get("auth_date"), 10, 64)
+    if err != nil {
+        return nil, fmt.Errorf("%w: invalid auth_date", ErrMalformed)
+    }
+    data.AuthDate = time.Unix(authDate, 0)
+
+    if raw := vals.Get("can_send_after"); raw != "" {
+        if data.CanSendAfter, err = strconv.Atoi(raw); err != nil {
+            return nil, fmt.Errorf("%w: invalid can_send_after", ErrMalformed)
+        }
+    }
+    if raw := vals.Get("user"); raw != "" {
+        data.User = new(WebAppUser)
+        if err := json.Unmarshal([]byte(raw), data.User); err != nil {
+            return nil, fmt.Errorf("%w: invalid user: %v", ErrMalformed, err)
+        }
+    }
+    if raw := vals.Get("receiver"); raw != "" {
+        data.Receiver = new(WebAppUser)
+        if err := json.Unmarshal([]byte(raw), data.Receiver); err != nil {
+            return nil, fmt.Errorf("%w: invalid receiver: %v", ErrMalformed, err)
         }
-        fmt.Printf("  Expected hash: %s\n", expectedHash)
-        fmt.Printf("  Received hash: %s\n", receivedHash)
-        return nil, fmt.Errorf("hash mismatch: expected %s, received %s", expectedHash, receivedHash)
-    }
-
-    // Готовим результат
-    result := make(map[string]string, len(keys)+2)
-    for _, k := range keys {
-        result[k] = vals.Get(k)
-    }
-
-    // FIX: распарсим user и положим user_id/username для GenerateJWT
-    if userRaw := vals.Get("user"); userRaw != "" {
-        var u tgUser
-        if err := json.Unmarshal([]byte(userRaw), &u); err == nil {
-            result["user_id"] = fmt.Sprint(u.ID)
-            if u.Username != "" {
-                result["username"] = u.Username
-            } else if fn := strings.TrimSpace(u.FirstName + " " + u.LastName); fn != "" {
-                result["username"] = fn
+    }
+    if raw := vals.Get("chat"); raw != "" {
+        data.Chat = new(WebAppChat)
+        if err := json.Unmarshal([]byte(raw), data.Chat); err != nil {
+            return nil, fmt.Errorf("%w: invalid chat: %v", ErrMalformed, err)
+        }
+    }
+    return data, nil
+}
+
+// checkFreshness проверяет возраст auth_date и повторное использование
+// данных с ключом key
+func checkFreshness(authDate time.Time, key string, opts Options) error {
+    now := opts.now()
+    if authDate.After(now.Add(opts.ClockSkew)) {
+        return fmt.Errorf("%w: auth_date is in the future", ErrMalformed)
+    }
+    if opts.MaxAge > 0 && now.Sub(authDate) > opts.MaxAge+opts.ClockSkew {
+        return ErrExpired
+    }
+
+    if opts.Replay != nil {
+        // дольше MaxAge хранить незачем: такие данные отсечёт проверка возраста
+        ttl := opts.MaxAge + opts.ClockSkew
+        if opts.MaxAge <= 0 {
+            ttl = 24 * time.Hour
+        }
+        if !opts.Replay.Remember(key, ttl) {
+            return ErrReplayed
+        }
+    }
+    return nil
+}
+
+// MemoryReplayCache — ReplayCache в памяти процесса (для одной реплики)
+type MemoryReplayCache struct {
+    mu      sync.Mutex
+    entries map[string]time.Time
+    lastGC  time.Time
+}
+
+// NewMemoryReplayCache создаёт пустой кэш
+func NewMemoryReplayCache() *MemoryReplayCache {
+    return &MemoryReplayCache{entries: make(map[string]time.Time)}
+}
+
+// Remember реализует ReplayCache
+func (m *MemoryReplayCache) Remember(key string, ttl time.Duration) bool {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    now := time.Now()
+    if now.Sub(m.lastGC) > time.Minute {
+        for k, exp := range m.entries {
+            if now.After(exp) {
+                delete(m.entries, k)
             }
         }
+        m.lastGC = now
     }
 
-    return result, nil
-}
\ No newline at end of file
+    if exp, ok := m.entries[key]; ok && now.Before(exp) {
+        return false
+    }
+    m.entries[key] = now.Add(ttl)
+    return true
+}
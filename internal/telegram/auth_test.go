@@ -0,0 +1,139 @@
+package telegram
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+)
+
+const testBotToken = "123456:TEST-token"
+
+var testNow = time.Unix(1_700_000_000, 0)
+
+// signInitData собирает initData из пар и подписывает его так же, как Telegram
+func signInitData(botToken string, fields map[string]string) string {
+    keys := make([]string, 0, len(fields))
+    for k := range fields {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    pairs := make([]string, len(keys))
+    for i, k := range keys {
+        pairs[i] = k + "=" + url.QueryEscape(fields[k])
+    }
+
+    secretMac := hmac.New(sha256.New, []byte("WebAppData"))
+    secretMac.Write([]byte(botToken))
+    mac := hmac.New(sha256.New, secretMac.Sum(nil))
+    mac.Write([]byte(strings.Join(pairs, "\n")))
+    return strings.Join(pairs, "&") + "&hash=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestCheckAuthDataRejectsDuplicateKeys(t *testing.T) {
+    // данные, честно подписанные для пользователя 1, но давно устаревшие
+    signed := signInitData(testBotToken, map[string]string{
+        "auth_date": strconv.FormatInt(testNow.Add(-30*24*time.Hour).Unix(), 10),
+        "query_id":  "AAE",
+        "user":      `{"id":1,"first_name":"Alice"}`,
+    })
+    fresh := strconv.FormatInt(testNow.Unix(), 10)
+    forged := []string{
+        "user=" + url.QueryEscape(`{"id":999}`) + "&auth_date=" + fresh + "&" + signed,
+        "user=" + url.QueryEscape(`{"id":999}`) + "&" + signed,
+        "auth_date=" + fresh + "&" + signed,
+        signed + "&user=" + url.QueryEscape(`{"id":999}`),
+    }
+    opts := Options{MaxAge: time.Hour, Now: func() time.Time { return testNow }}
+    for _, initData := range forged {
+        data, err := CheckAuthData(initData, testBotToken, opts)
+        if !errors.Is(err, ErrMalformed) {
+            t.Errorf("CheckAuthData(%q) = %+v, %v; want ErrMalformed", initData, data, err)
+        }
+    }
+}
+
+func TestCheckAuthData(t *testing.T) {
+    fields := func(authDate time.Time, queryID string) map[string]string {
+        return map[string]string{
+            "auth_date": strconv.FormatInt(authDate.Unix(), 10),
+            "query_id":  queryID,
+            "user":      `{"id":42,"first_name":"Иван","username":"ivan"}`,
+        }
+    }
+    valid := signInitData(testBotToken, fields(testNow.Add(-time.Minute), "q1"))
+    tests := []struct {
+        name     string
+        initData string
+        token    string
+        wantErr  error
+    }{
+        {"valid", valid, testBotToken, nil},
+        {"tampered", strings.Replace(valid, "ivan", "evil", 1), testBotToken, ErrBadHash},
+        {"other bot", valid, "654321:OTHER-token", ErrBadHash},
+        {"missing hash", valid[:strings.Index(valid, "&hash=")], testBotToken, ErrMalformed},
+        {"expired", signInitData(testBotToken, fields(testNow.Add(-2*time.Hour), "q2")), testBotToken, ErrExpired},
+        {"within skew", signInitData(testBotToken, fields(testNow.Add(20*time.Second), "q3")), testBotToken, nil},
+        {"future beyond skew", signInitData(testBotToken, fields(testNow.Add(5*time.Minute), "q4")), testBotToken, ErrMalformed},
+    }
+    opts := Options{MaxAge: time.Hour, ClockSkew: 30 * time.Second, Now: func() time.Time { return testNow }}
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            data, err := CheckAuthData(tt.initData, tt.token, opts)
+            if tt.wantErr != nil {
+                if !errors.Is(err, tt.wantErr) {
+                    t.Fatalf("err = %v, want %v", err, tt.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if data.User == nil || data.User.ID != 42 || data.User.FirstName != "Иван" {
+                t.Errorf("user = %+v", data.User)
+            }
+        })
+    }
+}
+
+func TestCheckAuthDataReplay(t *testing.T) {
+    opts := Options{
+        MaxAge: time.Hour,
+        Replay: NewMemoryReplayCache(),
+        Now:    func() time.Time { return testNow },
+    }
+    initData := signInitData(testBotToken, map[string]string{
+        "auth_date": strconv.FormatInt(testNow.Unix(), 10),
+        "query_id":  "AAHdF6IQAAAAAN0XohDhrOrc",
+        "user":      `{"id":42}`,
+    })
+    if _, err := CheckAuthData(initData, testBotToken, opts); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := CheckAuthData(initData, testBotToken, opts); !errors.Is(err, ErrReplayed) {
+        t.Errorf("second use: err = %v, want ErrReplayed", err)
+    }
+}
+
+func TestMemoryReplayCache(t *testing.T) {
+    cache := NewMemoryReplayCache()
+    if !cache.Remember("a", time.Hour) {
+        t.Fatal("first Remember must accept the key")
+    }
+    if cache.Remember("a", time.Hour) {
+        t.Error("second Remember must reject the key")
+    }
+    if !cache.Remember("b", time.Millisecond) {
+        t.Fatal("other key must be accepted")
+    }
+    time.Sleep(5 * time.Millisecond)
+    if !cache.Remember("b", time.Hour) {
+        t.Error("expired key must be accepted again")
+    }
+}
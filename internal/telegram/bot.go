@@ -22,6 +22,18 @@ func NewBot(token string) (*Bot, error) {
     return &Bot{api: api, chatID: api.Self.ID}, nil
 }
 
+// Username возвращает username бота (для ссылок t.me/<username>)
+func (b *Bot) Username() string {
+    return b.api.Self.UserName
+}
+
+// SendMessage отправляет сообщение в чат пользователя. Для личного чата
+// chatID совпадает с telegram_id; пользователь должен был запустить бота.
+func (b *Bot) SendMessage(chatID int64, text string) error {
+    _, err := b.api.Send(tgbot.NewMessage(chatID, text))
+    return err
+}
+
 // SendNotification шлёт текстовое уведомление в ваш бот
 func (b *Bot) SendNotification(text string) error {
     msg := tgbot.NewMessage(b.chatID, text)
@@ -0,0 +1,91 @@
+package telegram
+
+import (
+    "crypto/ed25519"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// Environment — окружение Telegram, которым подписан initData
+type Environment string
+
+const (
+    EnvProduction Environment = "production"
+    EnvTest       Environment = "test"
+)
+
+// Публичные ключи Telegram для проверки поля signature (третьими сторонами)
+var publicKeys = map[Environment]ed25519.PublicKey{
+    EnvProduction: mustPublicKey("e7bf03a2fa4602af4580703d88dda5bb59f32ed8b02a56c187fe7d34caed242d"),
+    EnvTest:       mustPublicKey("40055058a4ee38156a06562e52eece92a771bcd8346a8c4615cb7376eddf72ec"),
+}
+
+// ErrBadSignature — подпись Ed25519 не сходится
+var ErrBadSignature = errors.New("telegram: init data signature mismatch")
+
+func mustPublicKey(s string) ed25519.PublicKey {
+    key, err := hex.DecodeString(s)
+    if err != nil || len(key) != ed25519.PublicKeySize {
+        panic("telegram: invalid public key " + s)
+    }
+    return ed25519.PublicKey(key)
+}
+
+// BotIDFromToken возвращает id бота — числовую часть токена до двоеточия
+func BotIDFromToken(botToken string) (int64, error) {
+    idPart, _, found := strings.Cut(botToken, ":")
+    if !found {
+        return 0, errors.New("telegram: invalid bot token format")
+    }
+    return strconv.ParseInt(idPart, 10, 64)
+}
+
+// CheckSignature проверяет initData по полю signature без токена бота:
+// достаточно знать id бота и окружение Telegram. Свежесть и повторы
+// проверяются так же, как в CheckAuthData.
+func CheckSignature(initData string, botID int64, env Environment, opts Options) (*InitData, error) {
+    pub, ok := publicKeys[env]
+    if !ok {
+        return nil, fmt.Errorf("telegram: unknown environment %q", env)
+    }
+
+    _, vals, err := splitInitData(initData)
+    if err != nil {
+        return nil, err
+    }
+    rawSig := vals.Get("signature")
+    if rawSig == "" {
+        return nil, fmt.Errorf("%w: missing signature", ErrMalformed)
+    }
+    // Telegram присылает base64url без паддинга, но примем и с ним
+    sig, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(rawSig, "="))
+    if err != nil || len(sig) != ed25519.SignatureSize {
+        return nil, fmt.Errorf("%w: invalid signature encoding", ErrMalformed)
+    }
+
+    // data_check_string = "<bot_id>:WebAppData\n" + отсортированные пары
+    // key=value (уже декодированные) без hash и signature
+    var b strings.Builder
+    fmt.Fprintf(&b, "%d:WebAppData", botID)
+    for _, k := range sortedKeys(vals, "hash", "signature") {
+        b.WriteString("\n")
+        b.WriteString(k + "=" + vals.Get(k))
+    }
+
+    if !ed25519.Verify(pub, []byte(b.String()), sig) {
+        return nil, ErrBadSignature
+    }
+
+    data, err := parseInitData(vals)
+    if err != nil {
+        return nil, err
+    }
+    if err := checkFreshness(data.AuthDate, replayKey(data), opts); err != nil {
+        return nil, err
+    }
+    return data, nil
+}
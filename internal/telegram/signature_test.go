@@ -0,0 +1,128 @@
+package telegram
+
+import (
+    "crypto/ed25519"
+    "crypto/sha256"
+    "encoding/base64"
+    "errors"
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+)
+
+const testBotID = 123456
+
+// testSigningKey подменяет публичный ключ тестового окружения ключом из
+// фиксированного seed: закрытого ключа Telegram у нас нет
+func testSigningKey(t *testing.T) ed25519.PrivateKey {
+    t.Helper()
+    seed := sha256.Sum256([]byte("bbtg telegram signature test"))
+    priv := ed25519.NewKeyFromSeed(seed[:])
+    prev := publicKeys[EnvTest]
+    publicKeys[EnvTest] = priv.Public().(ed25519.PublicKey)
+    t.Cleanup(func() { publicKeys[EnvTest] = prev })
+    return priv
+}
+
+// signInitDataEd25519 подписывает пары так же, как Telegram: по строке
+// "<bot_id>:WebAppData\n" + отсортированные пары без hash и signature.
+// hash добавляется в initData, но в подпись не входит.
+func signInitDataEd25519(priv ed25519.PrivateKey, botID int64, fields map[string]string) string {
+    keys := make([]string, 0, len(fields))
+    for k := range fields {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    lines := []string{strconv.FormatInt(botID, 10) + ":WebAppData"}
+    pairs := make([]string, 0, len(keys))
+    for _, k := range keys {
+        pairs = append(pairs, k+"="+url.QueryEscape(fields[k]))
+        if k != "hash" {
+            lines = append(lines, k+"="+fields[k])
+        }
+    }
+    sig := ed25519.Sign(priv, []byte(strings.Join(lines, "\n")))
+    return strings.Join(pairs, "&") + "&signature=" + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestCheckSignature(t *testing.T) {
+    priv := testSigningKey(t)
+    fields := map[string]string{
+        "auth_date": strconv.FormatInt(testNow.Unix(), 10),
+        "hash":      "0123456789abcdef",
+        "query_id":  "AAE",
+        "user":      `{"id":1,"first_name":"Alice"}`,
+    }
+    valid := signInitDataEd25519(priv, testBotID, fields)
+    opts := Options{MaxAge: time.Hour, Now: func() time.Time { return testNow }}
+
+    data, err := CheckSignature(valid, testBotID, EnvTest, opts)
+    if err != nil {
+        t.Fatalf("valid: %v", err)
+    }
+    if data.User == nil || data.User.ID != 1 || data.QueryID != "AAE" {
+        t.Errorf("valid: parsed %+v", data)
+    }
+
+    // подпись с паддингом base64 тоже принимается
+    if _, err := CheckSignature(valid+"==", testBotID, EnvTest, opts); err != nil {
+        t.Errorf("padded signature: %v", err)
+    }
+
+    tests := []struct {
+        name     string
+        initData string
+        botID    int64
+        env      Environment
+        want     error
+    }{
+        {"tampered user", strings.Replace(valid, "Alice", "Mallory", 1), testBotID, EnvTest, ErrBadSignature},
+        // id бота входит в подписанную строку
+        {"other bot", valid, testBotID + 1, EnvTest, ErrBadSignature},
+        {"production key", valid, testBotID, EnvProduction, ErrBadSignature},
+        {"missing signature", valid[:strings.Index(valid, "&signature=")], testBotID, EnvTest, ErrMalformed},
+        {"bad encoding", strings.Replace(valid, "&signature=", "&signature=%21", 1), testBotID, EnvTest, ErrMalformed},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := CheckSignature(tt.initData, tt.botID, tt.env, opts)
+            if !errors.Is(err, tt.want) {
+                t.Errorf("err = %v, want %v", err, tt.want)
+            }
+        })
+    }
+}
+
+func TestCheckSignatureExcludesHash(t *testing.T) {
+    priv := testSigningKey(t)
+    fields := map[string]string{
+        "auth_date": strconv.FormatInt(testNow.Unix(), 10),
+        "hash":      "0123456789abcdef",
+        "user":      `{"id":1}`,
+    }
+    initData := signInitDataEd25519(priv, testBotID, fields)
+    initData = strings.Replace(initData, "hash=0123456789abcdef", "hash=fedcba9876543210", 1)
+    opts := Options{MaxAge: time.Hour, Now: func() time.Time { return testNow }}
+    if _, err := CheckSignature(initData, testBotID, EnvTest, opts); err != nil {
+        t.Errorf("hash must not be signed: %v", err)
+    }
+}
+
+func TestCheckSignatureUnknownEnvironment(t *testing.T) {
+    if _, err := CheckSignature("", testBotID, Environment("staging"), Options{}); err == nil {
+        t.Error("unknown environment accepted")
+    }
+}
+
+func TestBotIDFromToken(t *testing.T) {
+    id, err := BotIDFromToken(testBotToken)
+    if err != nil || id != testBotID {
+        t.Errorf("BotIDFromToken = %d, %v", id, err)
+    }
+    if _, err := BotIDFromToken("no-colon"); err == nil {
+        t.Error("token without colon accepted")
+    }
+}
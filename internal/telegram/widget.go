@@ -0,0 +1,78 @@
+package telegram
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// WidgetUser — пользователь, вошедший через Telegram Login Widget
+type WidgetUser struct {
+    ID        int64
+    FirstName string
+    LastName  string
+    Username  string
+    PhotoURL  string
+    AuthDate  time.Time
+    Hash      string
+}
+
+// CheckWidgetData проверяет данные Telegram Login Widget. В отличие от
+// initData Mini App, секретом служит SHA256(botToken), а поля приходят
+// плоским списком (id, first_name, username, photo_url, auth_date, hash).
+func CheckWidgetData(fields map[string]string, botToken string, opts Options) (*WidgetUser, error) {
+    receivedHash := strings.ToLower(fields["hash"])
+    if receivedHash == "" {
+        return nil, fmt.Errorf("%w: missing hash", ErrMalformed)
+    }
+
+    var keys []string
+    for k := range fields {
+        if k != "hash" {
+            keys = append(keys, k)
+        }
+    }
+    sort.Strings(keys)
+    pairs := make([]string, 0, len(keys))
+    for _, k := range keys {
+        pairs = append(pairs, k+"="+fields[k])
+    }
+
+    // secret_key = SHA256(botToken), hash = HMAC_SHA256(data_check_string, secret_key)
+    secretKey := sha256.Sum256([]byte(botToken))
+    mac := hmac.New(sha256.New, secretKey[:])
+    mac.Write([]byte(strings.Join(pairs, "\n")))
+    expectedHash := hex.EncodeToString(mac.Sum(nil))
+
+    if !hmac.Equal([]byte(expectedHash), []byte(receivedHash)) {
+        return nil, ErrBadHash
+    }
+
+    id, err := strconv.ParseInt(fields["id"], 10, 64)
+    if err != nil || id == 0 {
+        return nil, fmt.Errorf("%w: invalid id", ErrMalformed)
+    }
+    authDate, err := strconv.ParseInt(fields["auth_date"], 10, 64)
+    if err != nil {
+        return nil, fmt.Errorf("%w: invalid auth_date", ErrMalformed)
+    }
+
+    user := &WidgetUser{
+        ID:        id,
+        FirstName: fields["first_name"],
+        LastName:  fields["last_name"],
+        Username:  fields["username"],
+        PhotoURL:  fields["photo_url"],
+        AuthDate:  time.Unix(authDate, 0),
+        Hash:      receivedHash,
+    }
+    if err := checkFreshness(user.AuthDate, user.Hash, opts); err != nil {
+        return nil, err
+    }
+    return user, nil
+}
@@ -0,0 +1,84 @@
+package telegram
+
+import (
+    "errors"
+    "strings"
+    "testing"
+    "time"
+)
+
+// widgetFields — данные Login Widget для testBotToken; hash посчитан
+// независимо: HMAC_SHA256(data_check_string, SHA256(bot_token))
+func widgetFields() map[string]string {
+    return map[string]string{
+        "id":         "42",
+        "first_name": "Alice",
+        "username":   "alice",
+        "auth_date":  "1700000000",
+        "hash":       "3a7244639547f4f649dbcc0b867be3f4d0708082bf193b56bc195957782335de",
+    }
+}
+
+func TestCheckWidgetData(t *testing.T) {
+    opts := Options{MaxAge: time.Hour, Now: func() time.Time { return testNow }}
+
+    user, err := CheckWidgetData(widgetFields(), testBotToken, opts)
+    if err != nil {
+        t.Fatalf("valid: %v", err)
+    }
+    if user.ID != 42 || user.FirstName != "Alice" || user.Username != "alice" || !user.AuthDate.Equal(testNow) {
+        t.Errorf("valid: parsed %+v", user)
+    }
+
+    // hash в верхнем регистре тоже принимается
+    upper := widgetFields()
+    upper["hash"] = strings.ToUpper(upper["hash"])
+    if _, err := CheckWidgetData(upper, testBotToken, opts); err != nil {
+        t.Errorf("upper-case hash: %v", err)
+    }
+
+    tests := []struct {
+        name     string
+        modify   func(map[string]string)
+        botToken string
+        want     error
+    }{
+        {"tampered id", func(f map[string]string) { f["id"] = "1" }, testBotToken, ErrBadHash},
+        {"added field", func(f map[string]string) { f["last_name"] = "Smith" }, testBotToken, ErrBadHash},
+        {"other bot", func(map[string]string) {}, "654321:OTHER-token", ErrBadHash},
+        {"missing hash", func(f map[string]string) { delete(f, "hash") }, testBotToken, ErrMalformed},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            fields := widgetFields()
+            tt.modify(fields)
+            _, err := CheckWidgetData(fields, tt.botToken, opts)
+            if !errors.Is(err, tt.want) {
+                t.Errorf("err = %v, want %v", err, tt.want)
+            }
+        })
+    }
+
+    // подпись верна, но вход слишком старый
+    late := Options{MaxAge: time.Hour, Now: func() time.Time { return testNow.Add(2 * time.Hour) }}
+    if _, err := CheckWidgetData(widgetFields(), testBotToken, late); !errors.Is(err, ErrExpired) {
+        t.Errorf("expired: err = %v, want ErrExpired", err)
+    }
+}
+
+func TestCheckWidgetDataUsesWidgetSecret(t *testing.T) {
+    // hash, посчитанный по схеме Mini App (секрет HMAC("WebAppData", token)),
+    // для виджета не подходит
+    initData := signInitData(testBotToken, map[string]string{
+        "auth_date":  "1700000000",
+        "first_name": "Alice",
+        "id":         "42",
+        "username":   "alice",
+    })
+    fields := widgetFields()
+    fields["hash"] = initData[strings.Index(initData, "&hash=")+len("&hash="):]
+    opts := Options{MaxAge: time.Hour, Now: func() time.Time { return testNow }}
+    if _, err := CheckWidgetData(fields, testBotToken, opts); !errors.Is(err, ErrBadHash) {
+        t.Errorf("err = %v, want ErrBadHash", err)
+    }
+}
@@ -0,0 +1,16 @@
+-- migrations/008_landing_slugs.sql
+
+ALTER TABLE landings ADD COLUMN IF NOT EXISTS slug VARCHAR(64);
+UPDATE landings SET slug = 'page-' || id WHERE slug IS NULL;
+ALTER TABLE landings ALTER COLUMN slug SET NOT NULL;
+
+-- уникальность без учёта регистра
+CREATE UNIQUE INDEX IF NOT EXISTS landings_slug_lower_idx ON landings (LOWER(slug));
+
+-- старые slug'и продолжают редиректить на лендинг после переименования
+CREATE TABLE IF NOT EXISTS landing_slug_history (
+    slug VARCHAR(64) PRIMARY KEY,          -- всегда в нижнем регистре
+    landing_id INTEGER NOT NULL REFERENCES landings(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS landing_slug_history_landing_idx ON landing_slug_history (landing_id);
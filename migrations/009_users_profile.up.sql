@@ -0,0 +1,16 @@
+-- migrations/009_users_profile.sql
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS first_name VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS last_name VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS language_code VARCHAR(16),
+    ADD COLUMN IF NOT EXISTS is_premium BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS photo_url TEXT,
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+UPDATE users SET
+    username = COALESCE(username, ''),
+    first_name = COALESCE(first_name, ''),
+    last_name = COALESCE(last_name, ''),
+    language_code = COALESCE(language_code, ''),
+    photo_url = COALESCE(photo_url, '');
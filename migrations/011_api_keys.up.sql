@@ -0,0 +1,15 @@
+-- migrations/011_api_keys.sql
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(32) NOT NULL UNIQUE,    -- открытая часть ключа: поиск и отображение
+    key_hash VARCHAR(64) NOT NULL,         -- sha256 полного ключа
+    scopes TEXT[] NOT NULL DEFAULT '{}',   -- e.g. 'landings:read', 'links:write'
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS api_keys_user_idx ON api_keys (user_id);
@@ -0,0 +1,49 @@
+-- migrations/012_workspaces.sql
+
+CREATE TABLE IF NOT EXISTS workspaces (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    is_personal BOOLEAN NOT NULL DEFAULT FALSE,   -- личное пространство пользователя
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+-- у пользователя ровно одно личное пространство
+CREATE UNIQUE INDEX IF NOT EXISTS workspaces_personal_idx ON workspaces (created_by) WHERE is_personal;
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+    workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (workspace_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS workspace_members_user_idx ON workspace_members (user_id);
+
+CREATE TABLE IF NOT EXISTS workspace_invitations (
+    id SERIAL PRIMARY KEY,
+    workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    token VARCHAR(64) NOT NULL UNIQUE,     -- передаётся в deep link t.me/<bot>?startapp=inv_<token>
+    role VARCHAR(20) NOT NULL CHECK (role IN ('editor', 'viewer')),
+    invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    accepted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- лендинги переезжают в личные пространства своих владельцев
+INSERT INTO workspaces (name, is_personal, created_by)
+SELECT COALESCE(NULLIF(username, ''), 'Personal'), TRUE, id FROM users
+ON CONFLICT DO NOTHING;
+
+INSERT INTO workspace_members (workspace_id, user_id, role)
+SELECT id, created_by, 'owner' FROM workspaces WHERE is_personal
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE landings ADD COLUMN IF NOT EXISTS workspace_id INTEGER REFERENCES workspaces(id) ON DELETE CASCADE;
+UPDATE landings l SET workspace_id = w.id
+  FROM workspaces w
+ WHERE w.is_personal AND w.created_by = l.user_id AND l.workspace_id IS NULL;
+ALTER TABLE landings ALTER COLUMN workspace_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS landings_workspace_idx ON landings (workspace_id);
@@ -0,0 +1,4 @@
+-- migrations/013_landing_blocks.sql
+
+-- упорядоченный список типизированных блоков: [{"id": "...", "type": "text", "data": {...}}]
+ALTER TABLE landings ADD COLUMN IF NOT EXISTS blocks JSONB NOT NULL DEFAULT '[]';
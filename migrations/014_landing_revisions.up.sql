@@ -0,0 +1,40 @@
+-- migrations/014_landing_revisions.sql
+
+-- строка landings и её ссылки — это черновик; посетители видят опубликованную ревизию
+CREATE TABLE IF NOT EXISTS landing_revisions (
+    id SERIAL PRIMARY KEY,
+    landing_id INTEGER NOT NULL REFERENCES landings(id) ON DELETE CASCADE,
+    number INTEGER NOT NULL,                -- порядковый номер внутри лендинга
+    snapshot JSONB NOT NULL,                -- title, description, avatarUrl, blocks, links
+    note TEXT NOT NULL DEFAULT '',
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (landing_id, number)
+);
+
+ALTER TABLE landings
+    ADD COLUMN IF NOT EXISTS published_revision_id INTEGER REFERENCES landing_revisions(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS published_at TIMESTAMPTZ;
+
+-- существующие лендинги уже видны посетителям: публикуем их текущее состояние
+INSERT INTO landing_revisions (landing_id, number, snapshot, note, created_by)
+SELECT l.id, 1,
+       jsonb_build_object(
+           'title', l.title,
+           'description', COALESCE(l.description, ''),
+           'avatarUrl', COALESCE(l.avatar_url, ''),
+           'blocks', l.blocks,
+           'links', COALESCE((
+               SELECT jsonb_agg(jsonb_build_object(
+                          'id', k.id, 'landingId', k.landing_id, 'type', k.type, 'title', k.title,
+                          'url', k.url, 'position', k.position,
+                          'createdAt', k.created_at, 'updatedAt', k.updated_at)
+                      ORDER BY k.position, k.id)
+                 FROM links k WHERE k.landing_id = l.id), '[]'::jsonb)),
+       'initial', l.user_id
+  FROM landings l
+ WHERE NOT EXISTS (SELECT 1 FROM landing_revisions r WHERE r.landing_id = l.id);
+
+UPDATE landings l SET published_revision_id = r.id, published_at = r.created_at
+  FROM landing_revisions r
+ WHERE r.landing_id = l.id AND r.number = 1 AND l.published_revision_id IS NULL;
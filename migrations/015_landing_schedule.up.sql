@@ -0,0 +1,9 @@
+-- migrations/015_landing_schedule.sql
+
+-- отложенная публикация и снятие с публикации; обрабатывает планировщик в процессе сервера
+ALTER TABLE landings
+    ADD COLUMN IF NOT EXISTS publish_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS unpublish_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS landings_publish_at_idx ON landings (publish_at) WHERE publish_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS landings_unpublish_at_idx ON landings (unpublish_at) WHERE unpublish_at IS NOT NULL;
@@ -0,0 +1,53 @@
+-- migrations/016_landing_templates.sql
+
+-- шаблоны лендингов: системные (workspace_id IS NULL) и сохранённые пользователями
+CREATE TABLE IF NOT EXISTS landing_templates (
+    id SERIAL PRIMARY KEY,
+    workspace_id INTEGER REFERENCES workspaces(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    category VARCHAR(50) NOT NULL DEFAULT '',
+    blueprint JSONB NOT NULL,               -- тот же формат, что и снимок ревизии
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS landing_templates_workspace_idx ON landing_templates (workspace_id);
+
+INSERT INTO landing_templates (name, description, category, blueprint) VALUES
+('Визитка', 'Короткий рассказ о себе и ссылки на соцсети', 'personal', '{
+    "title": "Меня зовут …",
+    "description": "Пара слов о том, чем вы занимаетесь",
+    "avatarUrl": "",
+    "blocks": [
+        {"id": "hdr", "type": "header", "data": {"text": "Привет!", "level": 2, "align": "center"}},
+        {"id": "about", "type": "text", "data": {"text": "Расскажите о себе и о том, чем можете быть полезны.", "align": "center"}},
+        {"id": "social", "type": "social_row", "data": {"items": [{"network": "telegram", "url": "https://t.me/username"}]}}
+    ],
+    "links": [
+        {"type": "button", "title": "Написать в Telegram", "url": "https://t.me/username", "position": 0}
+    ]
+}'),
+('Промо-акция', 'Акция с обратным отсчётом и формой заявки', 'promo', '{
+    "title": "Скидка до конца недели",
+    "description": "Успейте оставить заявку",
+    "avatarUrl": "",
+    "blocks": [
+        {"id": "hdr", "type": "header", "data": {"text": "-30% на всё", "level": 1, "align": "center"}},
+        {"id": "timer", "type": "countdown", "data": {"title": "До конца акции", "endsAt": "2030-01-01T00:00:00Z", "expiredText": "Акция завершена"}},
+        {"id": "form", "type": "lead_form", "data": {"title": "Оставьте заявку", "fields": ["name", "phone"], "buttonText": "Хочу скидку", "successMessage": "Спасибо! Мы перезвоним."}}
+    ],
+    "links": []
+}'),
+('Мероприятие', 'Анонс события с картой и регистрацией', 'event', '{
+    "title": "Название мероприятия",
+    "description": "Дата и время",
+    "avatarUrl": "",
+    "blocks": [
+        {"id": "hdr", "type": "header", "data": {"text": "Приходите!", "level": 2, "align": "center"}},
+        {"id": "about", "type": "text", "data": {"text": "Программа, спикеры и всё, что нужно знать гостям.", "align": "left"}},
+        {"id": "place", "type": "map", "data": {"address": "Москва, Красная площадь", "lat": 55.7539, "lng": 37.6208, "zoom": 15}},
+        {"id": "form", "type": "lead_form", "data": {"title": "Регистрация", "fields": ["name", "email"], "buttonText": "Зарегистрироваться", "successMessage": "Вы зарегистрированы!"}}
+    ],
+    "links": []
+}');
@@ -0,0 +1,4 @@
+-- migrations/017_landing_theme.sql
+
+-- дизайн-токены: {"preset": "dark", "colors": {...}, "font": "Inter", ...}; пусто — пресет light
+ALTER TABLE landings ADD COLUMN IF NOT EXISTS theme JSONB NOT NULL DEFAULT '{}';
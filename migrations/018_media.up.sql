@@ -0,0 +1,21 @@
+-- migrations/018_media.sql
+
+-- загруженные файлы; object_key адресуется содержимым: media/<sha256>.<ext>
+CREATE TABLE IF NOT EXISTS media (
+    id SERIAL PRIMARY KEY,
+    workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    landing_id INTEGER REFERENCES landings(id) ON DELETE SET NULL,
+    uploaded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('avatar', 'image')),
+    object_key TEXT NOT NULL,
+    url TEXT NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size INTEGER NOT NULL,
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    sha256 VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+-- один и тот же файл в пространстве храним одной записью
+CREATE UNIQUE INDEX IF NOT EXISTS media_workspace_sha_idx ON media (workspace_id, sha256);
+CREATE INDEX IF NOT EXISTS media_object_key_idx ON media (object_key);
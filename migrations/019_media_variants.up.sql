@@ -0,0 +1,19 @@
+-- migrations/019_media_variants.sql
+
+-- хэш исходного файла: по нему повторная загрузка находится без обработки
+ALTER TABLE media ADD COLUMN IF NOT EXISTS source_sha256 VARCHAR(64) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS media_source_sha_idx ON media (workspace_id, source_sha256);
+
+-- уменьшенные копии для srcset
+CREATE TABLE IF NOT EXISTS media_variants (
+    id SERIAL PRIMARY KEY,
+    media_id INTEGER NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    format VARCHAR(10) NOT NULL CHECK (format IN ('jpeg', 'webp')),
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    object_key TEXT NOT NULL,
+    url TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (media_id, format, width)
+);
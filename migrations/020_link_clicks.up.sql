@@ -0,0 +1,14 @@
+-- migrations/020_link_clicks.sql
+
+-- клики по ссылкам пишет редирект /r/:linkId (event_type = 'click')
+ALTER TABLE analytics
+    ADD COLUMN IF NOT EXISTS link_id INTEGER REFERENCES links(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS referrer TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS utm_source VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS utm_medium VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS utm_campaign VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS utm_term VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS utm_content VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS analytics_landing_created_idx ON analytics (landing_id, created_at);
+CREATE INDEX IF NOT EXISTS analytics_link_created_idx ON analytics (link_id, created_at) WHERE link_id IS NOT NULL;
@@ -0,0 +1,13 @@
+-- migrations/021_link_visibility.sql
+
+-- окно показа и лимит кликов; после лимита ссылка ведёт на fallback_url
+ALTER TABLE links
+    ADD COLUMN IF NOT EXISTS visible_from TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS visible_until TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS max_clicks INTEGER CHECK (max_clicks > 0),
+    ADD COLUMN IF NOT EXISTS fallback_url TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS click_count INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE links DROP CONSTRAINT IF EXISTS links_visible_window_check;
+ALTER TABLE links ADD CONSTRAINT links_visible_window_check
+    CHECK (visible_from IS NULL OR visible_until IS NULL OR visible_until > visible_from);
@@ -0,0 +1,4 @@
+-- migrations/022_link_rules.sql
+
+-- упорядоченные правила выбора адреса по стране, ОС, браузеру, языку и Telegram
+ALTER TABLE links ADD COLUMN IF NOT EXISTS rules JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,12 @@
+-- migrations/023_link_positions.sql
+
+-- позиции ссылок лендинга идут подряд с нуля; повторы разводим по id
+UPDATE links k SET position = o.rn - 1
+FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY landing_id ORDER BY position, id) AS rn FROM links) o
+WHERE k.id = o.id AND k.position <> o.rn - 1;
+
+-- проверка в конце транзакции: сдвиг соседей при вставке и перемещении
+-- временно даёт совпадающие позиции
+ALTER TABLE links DROP CONSTRAINT IF EXISTS links_landing_position_key;
+ALTER TABLE links ADD CONSTRAINT links_landing_position_key
+    UNIQUE (landing_id, position) DEFERRABLE INITIALLY DEFERRED;
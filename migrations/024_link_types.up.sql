@@ -0,0 +1,36 @@
+-- migrations/024_link_types.sql
+
+-- типы ссылок теперь из реестра (см. linkTypes); старые button/social и
+-- прочие переводим по адресу, остальное — в обычную ссылку
+CREATE FUNCTION pg_temp.link_type_by_url(url TEXT) RETURNS TEXT AS $$
+    SELECT CASE
+        WHEN url ~* '^https?://(www\.)?(t|telegram)\.me/' THEN 'telegram'
+        WHEN url ~* '^https?://(wa\.me|api\.whatsapp\.com)/' THEN 'whatsapp'
+        WHEN url ~* '^https?://(www\.)?instagram\.com/' THEN 'instagram'
+        WHEN url ~* '^https?://(www\.|m\.)?(youtube\.com|youtu\.be)/' THEN 'youtube'
+        WHEN url ~* '^https?://(www\.)?tiktok\.com/' THEN 'tiktok'
+        WHEN url ~* '^https?://(www\.|m\.)?vk\.com/' THEN 'vk'
+        WHEN url ~* '^mailto:' THEN 'email'
+        WHEN url ~* '^tel:' THEN 'phone'
+        ELSE 'url'
+    END
+$$ LANGUAGE SQL IMMUTABLE;
+
+CREATE FUNCTION pg_temp.is_link_type(type TEXT) RETURNS BOOLEAN AS $$
+    SELECT COALESCE(type IN ('url', 'telegram', 'whatsapp', 'instagram', 'youtube', 'tiktok', 'vk', 'email', 'phone'), FALSE)
+$$ LANGUAGE SQL IMMUTABLE;
+
+UPDATE links SET type = pg_temp.link_type_by_url(url)
+WHERE NOT pg_temp.is_link_type(type);
+
+-- то же для ссылок в заготовках шаблонов (016 засевает их с type = button)
+UPDATE landing_templates t SET blueprint = jsonb_set(t.blueprint, '{links}', (
+        SELECT jsonb_agg(CASE
+                WHEN pg_temp.is_link_type(e.link->>'type') THEN e.link
+                ELSE jsonb_set(e.link, '{type}', to_jsonb(pg_temp.link_type_by_url(COALESCE(e.link->>'url', ''))))
+            END ORDER BY e.n)
+        FROM jsonb_array_elements(t.blueprint->'links') WITH ORDINALITY AS e(link, n)))
+WHERE EXISTS (
+    SELECT 1 FROM jsonb_array_elements(
+        CASE WHEN jsonb_typeof(t.blueprint->'links') = 'array' THEN t.blueprint->'links' END) l
+    WHERE NOT pg_temp.is_link_type(l->>'type'));
@@ -0,0 +1,5 @@
+-- migrations/025_published_links_idx.sql
+
+-- редирект /r/:linkId ищет ссылку в опубликованной ревизии по её id
+CREATE INDEX IF NOT EXISTS landing_revisions_links_idx
+    ON landing_revisions USING GIN ((snapshot->'links') jsonb_path_ops);
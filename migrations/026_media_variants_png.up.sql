@@ -0,0 +1,6 @@
+-- migrations/026_media_variants_png.sql
+
+-- у картинок с прозрачностью запасной вариант для srcset — PNG, а не JPEG
+ALTER TABLE media_variants DROP CONSTRAINT IF EXISTS media_variants_format_check;
+ALTER TABLE media_variants ADD CONSTRAINT media_variants_format_check
+    CHECK (format IN ('jpeg', 'png', 'webp'));